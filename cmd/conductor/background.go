@@ -22,24 +22,32 @@ import (
 )
 
 type CmdSpec struct {
-	Agent          string
-	Role           string
-	Model          string
-	Reasoning      string
-	Cmd            string
-	Args           []string
-	ReadyCmd       string
-	ReadyArgs      []string
-	ReadyTimeoutMs int
-	Env            map[string]string
-	Cwd            string
-	IdleTimeoutMs  int
-	Retry          int
-	RetryBackoffMs int
-	PromptHash     string
-	PromptLen      int
-	Prompt         string
-	LogPrompt      bool
+	Agent             string
+	Role              string
+	Model             string
+	Reasoning         string
+	Cmd               string
+	Args              []string
+	ReadyCmd          string
+	ReadyArgs         []string
+	ReadyTimeoutMs    int
+	Env               map[string]string
+	Cwd               string
+	IdleTimeoutMs     int
+	TimeoutMs         int       // wall-clock limit per attempt
+	Deadline          time.Time // batch deadline; no attempt starts or runs past it
+	KillGraceMs       int       // SIGTERM to SIGKILL delay for the process group
+	Retry             int
+	RetryBackoffMs    int
+	RetryOn           []string
+	RetryMaxBackoffMs int
+	PromptHash        string
+	PromptLen         int
+	Prompt            string
+	PromptPreview     string // shown in approval requests, never logged
+	LogPrompt         bool
+	Hops              []FallbackHop // failed earlier hops of a fallback chain
+	Fallback          []CmdSpec     // hops an async run moves on to, in order
 }
 
 type AsyncMeta struct {
@@ -55,6 +63,8 @@ type AsyncMeta struct {
 	Attempts        int      `json:"attempts"`
 	ExitCode        int      `json:"exit_code,omitempty"`
 	Error           string   `json:"error,omitempty"`
+	ErrorCode       string   `json:"error_code,omitempty"`
+	KillGraceMs     int      `json:"kill_grace_ms,omitempty"`
 	StartedAt       string   `json:"started_at,omitempty"`
 	EndedAt         string   `json:"ended_at,omitempty"`
 	PromptHash      string   `json:"prompt_hash,omitempty"`
@@ -62,6 +72,8 @@ type AsyncMeta struct {
 	ReadFiles       []string `json:"read_files,omitempty"`
 	ChangedFiles    []string `json:"changed_files,omitempty"`
 	CancelRequested bool     `json:"cancel_requested,omitempty"`
+
+	Hops []FallbackHop `json:"hops,omitempty"`
 }
 
 func buildSpecFromAgent(agent, prompt string, defaults Defaults, logPrompt bool) (CmdSpec, error) {
@@ -73,9 +85,14 @@ func buildSpecFromAgent(agent, prompt string, defaults Defaults, logPrompt bool)
 	}
 	if spec, ok := mapping[agent]; ok {
 		spec.IdleTimeoutMs = defaults.IdleTimeoutMs
+		spec.TimeoutMs = defaults.TimeoutMs
+		spec.KillGraceMs = defaults.KillGraceMs
 		spec.Retry = defaults.Retry
 		spec.RetryBackoffMs = defaults.RetryBackoffMs
+		spec.RetryOn = defaults.RetryOn
+		spec.RetryMaxBackoffMs = defaults.RetryMaxBackoffMs
 		spec.PromptHash, spec.PromptLen = promptMeta(prompt)
+		spec.PromptPreview = promptPreview(prompt)
 		if logPrompt {
 			spec.Prompt = prompt
 			spec.LogPrompt = true
@@ -90,6 +107,12 @@ func buildSpecFromRole(cfg Config, role, prompt, modelOverride, reasoningOverrid
 	if !ok {
 		return CmdSpec{}, fmt.Errorf("%s", unknownRoleMessage(cfg, role))
 	}
+	return buildSpecFromRoleConfig(cfg, role, roleCfg, prompt, modelOverride, reasoningOverride, logPrompt)
+}
+
+// buildSpecFromRoleConfig builds a spec for an already resolved role config,
+// letting callers such as MCP sessions substitute their own argv template.
+func buildSpecFromRoleConfig(cfg Config, role string, roleCfg RoleConfig, prompt, modelOverride, reasoningOverride string, logPrompt bool) (CmdSpec, error) {
 	roleCfg, err := normalizeRoleConfig(roleCfg)
 	if err != nil {
 		return CmdSpec{}, err
@@ -104,7 +127,13 @@ func buildSpecFromRole(cfg Config, role, prompt, modelOverride, reasoningOverrid
 	if reasoning == "" {
 		reasoning = roleCfg.Reasoning
 	}
-	args := append([]string{}, roleCfg.Args...)
+	// Apply the MCP policy to the template before the prompt is substituted,
+	// so prompt text is never mistaken for a flag.
+	args, err := enforceArgsPolicy(roleCfg.CLI, role, append([]string{}, roleCfg.Args...), roleCfg.Cwd)
+	if err != nil {
+		return CmdSpec{}, err
+	}
+	args = policyCapArgs(roleCfg.CLI, role, args)
 	promptIndex := indexOf(args, "{prompt}")
 	insertIndex := promptIndex
 	if insertIndex < 0 {
@@ -130,22 +159,30 @@ func buildSpecFromRole(cfg Config, role, prompt, modelOverride, reasoningOverrid
 		args = append(args, prompt)
 	}
 	spec := CmdSpec{
-		Agent:          roleCfg.CLI,
-		Role:           role,
-		Model:          model,
-		Reasoning:      reasoning,
-		Cmd:            roleCfg.CLI,
-		Args:           args,
-		ReadyCmd:       roleCfg.ReadyCmd,
-		ReadyArgs:      roleCfg.ReadyArgs,
-		ReadyTimeoutMs: roleCfg.ReadyTimeoutMs,
-		Env:            roleCfg.Env,
-		Cwd:            roleCfg.Cwd,
-		IdleTimeoutMs:  effectiveInt(roleCfg.IdleTimeoutMs, defaults.IdleTimeoutMs),
-		Retry:          effectiveInt(roleCfg.Retry, defaults.Retry),
-		RetryBackoffMs: effectiveInt(roleCfg.RetryBackoffMs, defaults.RetryBackoffMs),
+		Agent:             roleCfg.CLI,
+		Role:              role,
+		Model:             model,
+		Reasoning:         reasoning,
+		Cmd:               roleCfg.CLI,
+		Args:              args,
+		ReadyCmd:          roleCfg.ReadyCmd,
+		ReadyArgs:         roleCfg.ReadyArgs,
+		ReadyTimeoutMs:    roleCfg.ReadyTimeoutMs,
+		Env:               roleCfg.Env,
+		Cwd:               roleCfg.Cwd,
+		IdleTimeoutMs:     effectiveInt(roleCfg.IdleTimeoutMs, defaults.IdleTimeoutMs),
+		TimeoutMs:         effectiveInt(roleCfg.TimeoutMs, defaults.TimeoutMs),
+		KillGraceMs:       effectiveInt(roleCfg.KillGraceMs, defaults.KillGraceMs),
+		Retry:             effectiveInt(roleCfg.Retry, defaults.Retry),
+		RetryBackoffMs:    effectiveInt(roleCfg.RetryBackoffMs, defaults.RetryBackoffMs),
+		RetryOn:           roleCfg.RetryOn,
+		RetryMaxBackoffMs: effectiveInt(roleCfg.RetryMaxBackoffMs, defaults.RetryMaxBackoffMs),
+	}
+	if len(spec.RetryOn) == 0 {
+		spec.RetryOn = defaults.RetryOn
 	}
 	spec.PromptHash, spec.PromptLen = promptMeta(prompt)
+	spec.PromptPreview = promptPreview(prompt)
 	if logPrompt {
 		spec.Prompt = prompt
 		spec.LogPrompt = true
@@ -171,9 +208,13 @@ func promptMeta(prompt string) (string, int) {
 	return hex.EncodeToString(sum[:]), len(prompt)
 }
 
-func asyncRunDir(runID string) string {
+func asyncRunsDir() string {
 	baseDir := getenv("CONDUCTOR_HOME", filepath.Join(os.Getenv("HOME"), ".conductor-kit"))
-	return filepath.Join(baseDir, "runs", "async", runID)
+	return filepath.Join(baseDir, "runs", "async")
+}
+
+func asyncRunDir(runID string) string {
+	return filepath.Join(asyncRunsDir(), runID)
 }
 
 func asyncMetaPath(runID string) string {
@@ -214,6 +255,11 @@ func isRunning(pid int) bool {
 }
 
 func readTail(path string, bytes int) string {
+	return readTailSince(path, 0, bytes)
+}
+
+// readTailSince is readTail for the part of the file past offset from.
+func readTailSince(path string, from int64, bytes int) string {
 	file, err := os.Open(path)
 	if err != nil {
 		return ""
@@ -226,14 +272,42 @@ func readTail(path string, bytes int) string {
 	}
 	size := info.Size()
 	start := size - int64(bytes)
-	if start < 0 {
-		start = 0
+	if start < from {
+		start = from
 	}
 	_, _ = file.Seek(start, 0)
 	data, _ := io.ReadAll(file)
 	return string(data)
 }
 
+// errorTailBytes is how much of an async run's logs is read to classify its
+// failure.
+const errorTailBytes = 4096
+
+// logMark is where one hop of an async run starts in the run's logs, so a
+// fallback hop is not classified by what the hop before it printed.
+type logMark struct {
+	stdout int64
+	stderr int64
+}
+
+func markLogs(stdoutFile, stderrFile *os.File) logMark {
+	var mark logMark
+	if info, err := stdoutFile.Stat(); err == nil {
+		mark.stdout = info.Size()
+	}
+	if info, err := stderrFile.Stat(); err == nil {
+		mark.stderr = info.Size()
+	}
+	return mark
+}
+
+// asyncOutputTail returns the end of an async run's logs since mark for
+// runErrorCode.
+func asyncOutputTail(stdoutFile, stderrFile *os.File, mark logMark) string {
+	return readTailSince(stderrFile.Name(), mark.stderr, errorTailBytes) + "\n" + readTailSince(stdoutFile.Name(), mark.stdout, errorTailBytes)
+}
+
 type activityWriter struct {
 	w          io.Writer
 	activityCh chan struct{}
@@ -290,7 +364,7 @@ func statusFromErrorWithTimeout(ctx context.Context, err error, timedOut bool) (
 	case timedOut:
 		status = "timeout"
 	case errors.Is(ctx.Err(), context.DeadlineExceeded):
-		status = "timeout"
+		status = "deadline"
 	case errors.Is(ctx.Err(), context.Canceled):
 		status = "canceled"
 	}
@@ -409,21 +483,27 @@ func extractReadFiles(output string) []string {
 }
 
 func runCommand(spec CmdSpec) (map[string]interface{}, error) {
+	return runCommandContext(context.Background(), spec)
+}
+
+// runCommandContext is runCommand for callers that can give up on the run:
+// once ctx is done a running attempt is canceled and no attempt or retry
+// starts.
+func runCommandContext(ctx context.Context, spec CmdSpec) (map[string]interface{}, error) {
 	if !isCommandAvailable(spec.Cmd) {
-		return nil, fmt.Errorf("Missing CLI on PATH: %s", spec.Cmd)
+		return nil, withErrorCode(errCodeMissingCLI, fmt.Errorf("Missing CLI on PATH: %s", spec.Cmd))
 	}
 
-	attempts := spec.Retry + 1
-	if attempts < 1 {
-		attempts = 1
-	}
-	backoff := time.Duration(spec.RetryBackoffMs) * time.Millisecond
+	policy := spec.retryPolicy()
+	attempts := policy.attempts
 
-	if err := checkReady(spec); err != nil {
+	gateID := newRunID()
+	if status, err := gateRun(ctx, gateID, spec); err != nil {
 		now := time.Now().UTC()
+		errCode := runErrorCode(status, false, "")
 		payload := map[string]interface{}{
-			"run_id":      newRunID(),
-			"status":      "not_ready",
+			"run_id":      gateID,
+			"status":      status,
 			"agent":       firstNonEmpty(spec.Role, spec.Agent),
 			"role":        spec.Role,
 			"model":       spec.Model,
@@ -436,15 +516,19 @@ func runCommand(spec CmdSpec) (map[string]interface{}, error) {
 			"started_at":  now.Format(time.RFC3339),
 			"ended_at":    now.Format(time.RFC3339),
 			"error":       err.Error(),
+			"error_code":  errCode,
+		}
+		if len(spec.Hops) > 0 {
+			payload["hops"] = spec.Hops
 		}
 		record := RunRecord{
-			ID:         payload["run_id"].(string),
+			ID:         gateID,
 			Agent:      spec.Agent,
 			Role:       spec.Role,
 			Model:      spec.Model,
 			Cmd:        spec.Cmd,
 			Args:       spec.Args,
-			Status:     "not_ready",
+			Status:     status,
 			ExitCode:   1,
 			StartedAt:  payload["started_at"].(string),
 			EndedAt:    payload["ended_at"].(string),
@@ -453,6 +537,8 @@ func runCommand(spec CmdSpec) (map[string]interface{}, error) {
 			PromptLen:  spec.PromptLen,
 			Prompt:     spec.Prompt,
 			Error:      err.Error(),
+			ErrorCode:  errCode,
+			Hops:       spec.Hops,
 		}
 		_ = appendRunRecord(record, spec.LogPrompt)
 		return payload, nil
@@ -460,7 +546,7 @@ func runCommand(spec CmdSpec) (map[string]interface{}, error) {
 
 	var last map[string]interface{}
 	for i := 1; i <= attempts; i++ {
-		res, err := runCommandOnce(spec, i, attempts)
+		res, err := runCommandOnce(ctx, spec, i, attempts)
 		if err != nil {
 			return nil, err
 		}
@@ -468,8 +554,18 @@ func runCommand(spec CmdSpec) (map[string]interface{}, error) {
 		if res["status"] == "ok" {
 			return res, nil
 		}
-		if i < attempts && backoff > 0 {
-			time.Sleep(backoff)
+		code, _ := res["error_code"].(string)
+		if i == attempts || !policy.retries(code) || ctx.Err() != nil {
+			break
+		}
+		delay := policy.delay(i, payloadText(res))
+		if pastDeadline(spec.Deadline, delay) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return last, nil
+		case <-time.After(delay):
 		}
 	}
 	return last, nil
@@ -477,6 +573,26 @@ func runCommand(spec CmdSpec) (map[string]interface{}, error) {
 
 const defaultReadyTimeoutMs = 5000
 
+// gateRun runs the checks that must pass before a run starts and returns the
+// status to record when one fails: canceled (the caller gave up while the run
+// waited), deadline (the batch deadline passed while the run waited), refused
+// (nesting limits) or not_ready.
+func gateRun(ctx context.Context, runID string, spec CmdSpec) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "canceled", fmt.Errorf("canceled before the run started: %w", err)
+	}
+	if pastDeadline(spec.Deadline, 0) {
+		return "deadline", errDeadlinePassed
+	}
+	if err := admitRun(runID); err != nil {
+		return "refused", err
+	}
+	if err := checkReady(spec); err != nil {
+		return "not_ready", err
+	}
+	return "", nil
+}
+
 func checkReady(spec CmdSpec) error {
 	if spec.ReadyCmd == "" {
 		return nil
@@ -491,6 +607,7 @@ func checkReady(spec CmdSpec) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutMs)*time.Millisecond)
 	defer cancel()
 	cmd := exec.CommandContext(ctx, spec.ReadyCmd, spec.ReadyArgs...)
+	startInProcessGroup(cmd, killGrace(spec.KillGraceMs))
 	if spec.Cwd != "" {
 		cmd.Dir = spec.Cwd
 	}
@@ -506,7 +623,10 @@ func checkReady(spec CmdSpec) error {
 	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	err := cmd.Run()
+	err := cmd.Start()
+	if err == nil {
+		err = waitInProcessGroup(cmd)
+	}
 	if ctx.Err() == context.DeadlineExceeded {
 		return fmt.Errorf("ready check timed out after %dms", timeoutMs)
 	}
@@ -523,9 +643,9 @@ func checkReady(spec CmdSpec) error {
 	return nil
 }
 
-func runCommandOnce(spec CmdSpec, attempt, attempts int) (map[string]interface{}, error) {
+func runCommandOnce(ctx context.Context, spec CmdSpec, attempt, attempts int) (map[string]interface{}, error) {
 	idleTimeout := time.Duration(spec.IdleTimeoutMs) * time.Millisecond
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := withAttemptDeadline(ctx, spec.TimeoutMs, spec.Deadline)
 	defer cancel()
 
 	cwd := cwdForSpec(spec)
@@ -543,20 +663,17 @@ func runCommandOnce(spec CmdSpec, attempt, attempts int) (map[string]interface{}
 	defer stopIdle()
 
 	runID := newRunID()
+	spec.Env = lineageEnv(spec.Env, runID)
 	start := time.Now().UTC()
+	grace := killGrace(spec.KillGraceMs)
 	cmd := exec.CommandContext(ctx, spec.Cmd, spec.Args...)
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
-	}
+	startInProcessGroup(cmd, grace)
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
-	stdoutWriter := &activityWriter{w: &stdout, activityCh: activityCh}
-	stderrWriter := &activityWriter{w: &stderr, activityCh: activityCh}
+	stdoutLog, stderrLog := openRunLogs(runID)
+	defer closeRunLogs(stdoutLog, stderrLog)
+	cmd.Stdout = &activityWriter{w: teeRunLog(&stdout, stdoutLog), activityCh: activityCh}
+	cmd.Stderr = &activityWriter{w: teeRunLog(&stderr, stderrLog), activityCh: activityCh}
 	if spec.Cwd != "" {
 		cmd.Dir = spec.Cwd
 	}
@@ -571,18 +688,9 @@ func runCommandOnce(spec CmdSpec, attempt, attempts int) (map[string]interface{}
 	if err := cmd.Start(); err != nil {
 		return nil, err
 	}
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() {
-		_, _ = io.Copy(stdoutWriter, stdoutPipe)
-		wg.Done()
-	}()
-	go func() {
-		_, _ = io.Copy(stderrWriter, stderrPipe)
-		wg.Done()
-	}()
-	err = cmd.Wait()
-	wg.Wait()
+	releaseGroup := watchProcessGroup(cmd, runID, grace)
+	err := waitInProcessGroup(cmd)
+	releaseGroup()
 	end := time.Now().UTC()
 	duration := end.Sub(start).Milliseconds()
 
@@ -615,6 +723,13 @@ func runCommandOnce(spec CmdSpec, attempt, attempts int) (map[string]interface{}
 	if errMsg != "" {
 		payload["error"] = errMsg
 	}
+	errCode := runErrorCode(status, idleTimedOut.Load(), stdout.String()+"\n"+stderr.String()+"\n"+errMsg)
+	if errCode != "" {
+		payload["error_code"] = errCode
+	}
+	if len(spec.Hops) > 0 {
+		payload["hops"] = spec.Hops
+	}
 
 	record := RunRecord{
 		ID:           runID,
@@ -634,6 +749,8 @@ func runCommandOnce(spec CmdSpec, attempt, attempts int) (map[string]interface{}
 		ReadFiles:    readFiles,
 		ChangedFiles: changedFiles,
 		Error:        errMsg,
+		ErrorCode:    errCode,
+		Hops:         spec.Hops,
 	}
 	_ = appendRunRecord(record, spec.LogPrompt)
 	if status == "ok" {
@@ -649,32 +766,137 @@ func runCommandOnce(spec CmdSpec, attempt, attempts int) (map[string]interface{}
 	return payload, nil
 }
 
+// openRunLogs keeps a synchronous or session run's output under its run
+// directory so it can be read back through the conductor://runs/{id}/stdout|stderr
+// resources. Log files are best effort; nil files are skipped.
+func openRunLogs(runID string) (*os.File, *os.File) {
+	if runID == "" {
+		return nil, nil
+	}
+	maybePruneRunDirs()
+	runDir := asyncRunDir(runID)
+	if err := os.MkdirAll(runDir, 0o755); err != nil {
+		return nil, nil
+	}
+	stdoutLog, err := os.Create(filepath.Join(runDir, "stdout.log"))
+	if err != nil {
+		return nil, nil
+	}
+	stderrLog, err := os.Create(filepath.Join(runDir, "stderr.log"))
+	if err != nil {
+		_ = stdoutLog.Close()
+		return nil, nil
+	}
+	return stdoutLog, stderrLog
+}
+
+func closeRunLogs(files ...*os.File) {
+	for _, f := range files {
+		if f != nil {
+			_ = f.Close()
+		}
+	}
+}
+
+func teeRunLog(w io.Writer, log *os.File) io.Writer {
+	if log == nil {
+		return w
+	}
+	return io.MultiWriter(w, log)
+}
+
+// Run directories (logs and async metadata) and lineage counters are removed
+// once nothing has been written to them for runDirRetention. Pruning
+// piggybacks on new runs, at most once per runDirPruneInterval per process.
+const (
+	runDirRetention     = 7 * 24 * time.Hour
+	runDirPruneInterval = time.Hour
+)
+
+var lastRunDirPrune atomic.Int64
+
+func maybePruneRunDirs() {
+	now := time.Now()
+	last := lastRunDirPrune.Load()
+	if now.UnixNano()-last < int64(runDirPruneInterval) || !lastRunDirPrune.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	_, _ = pruneRunDirs(now.Add(-runDirRetention))
+	_, _ = pruneLineageCounters(now.Add(-runDirRetention))
+}
+
+// pruneRunDirs removes run directories last written before cutoff, keeping
+// async runs whose process is still alive. It returns the removed run IDs.
+func pruneRunDirs(cutoff time.Time) ([]string, error) {
+	entries, err := os.ReadDir(asyncRunsDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		runID := entry.Name()
+		if lastRunDirWrite(asyncRunDir(runID)).After(cutoff) {
+			continue
+		}
+		if meta, _, err := loadAsyncMeta(runID); err == nil && (meta.Status == "running" || meta.Status == "starting") && isRunning(meta.PID) {
+			continue
+		}
+		if err := os.RemoveAll(asyncRunDir(runID)); err == nil {
+			removed = append(removed, runID)
+		}
+	}
+	return removed, nil
+}
+
+// lastRunDirWrite is the newest modification time in a run directory.
+func lastRunDirWrite(dir string) time.Time {
+	var latest time.Time
+	if info, err := os.Stat(dir); err == nil {
+		latest = info.ModTime()
+	}
+	files, _ := os.ReadDir(dir)
+	for _, file := range files {
+		if info, err := file.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
 func startAsync(spec CmdSpec) (map[string]interface{}, error) {
 	return startAsyncWithID(newRunID(), spec)
 }
 
 func startAsyncWithID(runID string, spec CmdSpec) (map[string]interface{}, error) {
 	if !isCommandAvailable(spec.Cmd) {
-		return nil, fmt.Errorf("Missing CLI on PATH: %s", spec.Cmd)
+		return nil, withErrorCode(errCodeMissingCLI, fmt.Errorf("Missing CLI on PATH: %s", spec.Cmd))
 	}
-	if err := checkReady(spec); err != nil {
+	if status, err := gateRun(context.Background(), runID, spec); err != nil {
 		now := time.Now().UTC()
+		errCode := runErrorCode(status, false, "")
 		runDir := asyncRunDir(runID)
 		if err := os.MkdirAll(runDir, 0o755); err != nil {
 			return nil, err
 		}
 		meta := AsyncMeta{
 			ID:         runID,
-			Status:     "not_ready",
+			Status:     status,
 			Agent:      spec.Agent,
 			Role:       spec.Role,
 			Model:      spec.Model,
 			Cmd:        spec.Cmd,
 			Args:       spec.Args,
 			Attempt:    0,
-			Attempts:   spec.Retry + 1,
+			Attempts:   spec.retryPolicy().attempts,
 			ExitCode:   1,
 			Error:      err.Error(),
+			ErrorCode:  errCode,
 			StartedAt:  now.Format(time.RFC3339),
 			EndedAt:    now.Format(time.RFC3339),
 			PromptHash: spec.PromptHash,
@@ -688,7 +910,7 @@ func startAsyncWithID(runID string, spec CmdSpec) (map[string]interface{}, error
 			Model:      spec.Model,
 			Cmd:        spec.Cmd,
 			Args:       spec.Args,
-			Status:     "not_ready",
+			Status:     status,
 			ExitCode:   1,
 			StartedAt:  meta.StartedAt,
 			EndedAt:    meta.EndedAt,
@@ -697,16 +919,19 @@ func startAsyncWithID(runID string, spec CmdSpec) (map[string]interface{}, error
 			PromptLen:  spec.PromptLen,
 			Prompt:     spec.Prompt,
 			Error:      err.Error(),
+			ErrorCode:  errCode,
 		}
 		_ = appendRunRecord(record, spec.LogPrompt)
 		return map[string]interface{}{
-			"run_id": runID,
-			"status": "not_ready",
-			"agent":  firstNonEmpty(spec.Role, spec.Agent),
-			"error":  err.Error(),
+			"run_id":     runID,
+			"status":     status,
+			"agent":      firstNonEmpty(spec.Role, spec.Agent),
+			"error":      err.Error(),
+			"error_code": errCode,
 		}, nil
 	}
 
+	maybePruneRunDirs()
 	runDir := asyncRunDir(runID)
 	if err := os.MkdirAll(runDir, 0o755); err != nil {
 		return nil, err
@@ -724,10 +949,7 @@ func startAsyncWithID(runID string, spec CmdSpec) (map[string]interface{}, error
 		return nil, err
 	}
 
-	attempts := spec.Retry + 1
-	if attempts < 1 {
-		attempts = 1
-	}
+	attempts := spec.retryPolicy().attempts
 	meta := AsyncMeta{
 		ID:         runID,
 		Status:     "starting",
@@ -753,15 +975,30 @@ func startAsyncWithID(runID string, spec CmdSpec) (map[string]interface{}, error
 	}, nil
 }
 
+// runAsyncAttempts runs spec, then the hops of its fallback chain while a
+// hop fails with a quota, rate_limit or auth error. All hops share the run ID
+// and its logs; the failed ones are listed under hops.
 func runAsyncAttempts(runID string, spec CmdSpec, stdoutFile, stderrFile *os.File) {
 	defer stdoutFile.Close()
 	defer stderrFile.Close()
-
-	attempts := spec.Retry + 1
-	if attempts < 1 {
-		attempts = 1
+	for {
+		next, ok := runAsyncHop(runID, spec, stdoutFile, stderrFile)
+		if !ok {
+			return
+		}
+		spec = next
 	}
-	backoff := time.Duration(spec.RetryBackoffMs) * time.Millisecond
+}
+
+// runAsyncHop runs one spec with retries and records the outcome. When the
+// run should fall back instead, it records nothing and returns the next hop.
+func runAsyncHop(runID string, spec CmdSpec, stdoutFile, stderrFile *os.File) (CmdSpec, bool) {
+	spec.Env = lineageEnv(spec.Env, runID)
+	mark := markLogs(stdoutFile, stderrFile)
+
+	policy := spec.retryPolicy()
+	attempts := policy.attempts
+	grace := killGrace(spec.KillGraceMs)
 
 	cwd := cwdForSpec(spec)
 	var beforeStatus map[string]string
@@ -774,12 +1011,13 @@ func runAsyncAttempts(runID string, spec CmdSpec, stdoutFile, stderrFile *os.Fil
 	var status string
 	var exitCode int
 	var errMsg string
+	var errCode string
 	lastAttempt := 0
 	var changedFiles []string
 
 	for attempt := 1; attempt <= attempts; attempt++ {
 		lastAttempt = attempt
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := withAttemptDeadline(context.Background(), spec.TimeoutMs, spec.Deadline)
 		activityCh := make(chan struct{}, 1)
 		var idleTimedOut atomic.Bool
 		stopIdle := startIdleTimer(ctx, time.Duration(spec.IdleTimeoutMs)*time.Millisecond, activityCh, func() {
@@ -788,8 +1026,9 @@ func runAsyncAttempts(runID string, spec CmdSpec, stdoutFile, stderrFile *os.Fil
 		})
 
 		cmd := exec.CommandContext(ctx, spec.Cmd, spec.Args...)
-		cmd.Stdout = &activityWriter{w: stdoutFile, activityCh: activityCh}
-		cmd.Stderr = &activityWriter{w: stderrFile, activityCh: activityCh}
+		startInProcessGroup(cmd, grace)
+		cmd.Stdout = &activityWriter{w: &resourceUpdateWriter{w: stdoutFile, uri: runStdoutURI(runID)}, activityCh: activityCh}
+		cmd.Stderr = &activityWriter{w: &resourceUpdateWriter{w: stderrFile, uri: runStderrURI(runID)}, activityCh: activityCh}
 		if spec.Cwd != "" {
 			cmd.Dir = spec.Cwd
 		}
@@ -809,6 +1048,7 @@ func runAsyncAttempts(runID string, spec CmdSpec, stdoutFile, stderrFile *os.Fil
 			status = "error"
 			exitCode = 1
 			errMsg = err.Error()
+			errCode = errCodeMissingCLI
 			endedAt = time.Now().UTC()
 			cancel()
 			stopIdle()
@@ -816,27 +1056,33 @@ func runAsyncAttempts(runID string, spec CmdSpec, stdoutFile, stderrFile *os.Fil
 		}
 
 		meta := AsyncMeta{
-			ID:         runID,
-			Status:     "running",
-			Agent:      spec.Agent,
-			Role:       spec.Role,
-			Model:      spec.Model,
-			Cmd:        spec.Cmd,
-			Args:       spec.Args,
-			PID:        cmd.Process.Pid,
-			Attempt:    attempt,
-			Attempts:   attempts,
-			StartedAt:  startedAt.Format(time.RFC3339),
-			PromptHash: spec.PromptHash,
-			PromptLen:  spec.PromptLen,
+			ID:          runID,
+			Status:      "running",
+			Agent:       spec.Agent,
+			Role:        spec.Role,
+			Model:       spec.Model,
+			Cmd:         spec.Cmd,
+			Args:        spec.Args,
+			PID:         cmd.Process.Pid,
+			Attempt:     attempt,
+			Attempts:    attempts,
+			KillGraceMs: int(grace / time.Millisecond),
+			StartedAt:   startedAt.Format(time.RFC3339),
+			PromptHash:  spec.PromptHash,
+			PromptLen:   spec.PromptLen,
+			Hops:        spec.Hops,
 		}
 		_ = writeAsyncMeta(meta)
+		releaseGroup := watchProcessGroup(cmd, runID, grace)
 
-		err := cmd.Wait()
+		err := waitInProcessGroup(cmd)
+		releaseGroup()
+		// Classify before cancel, which would make every failure look canceled.
+		status, exitCode, errMsg = statusFromErrorWithTimeout(ctx, err, idleTimedOut.Load())
 		cancel()
 		stopIdle()
 		endedAt = time.Now().UTC()
-		status, exitCode, errMsg = statusFromErrorWithTimeout(ctx, err, idleTimedOut.Load())
+		errCode = runErrorCode(status, idleTimedOut.Load(), asyncOutputTail(stdoutFile, stderrFile, mark)+"\n"+errMsg)
 		if snapshot, err := gitStatusSnapshot(cwd); err == nil {
 			changedFiles = diffGitStatus(beforeStatus, snapshot)
 		}
@@ -847,14 +1093,31 @@ func runAsyncAttempts(runID string, spec CmdSpec, stdoutFile, stderrFile *os.Fil
 		}
 		if cancelRequested && status != "ok" {
 			status = "canceled"
+			errCode = errCodeCanceled
+			break
 		}
 
-		if status == "ok" {
+		if status == "ok" || attempt == attempts || !policy.retries(errCode) {
 			break
 		}
-		if attempt < attempts && backoff > 0 {
-			time.Sleep(backoff)
+		delay := policy.delay(attempt, asyncOutputTail(stdoutFile, stderrFile, mark))
+		if pastDeadline(spec.Deadline, delay) {
+			break
 		}
+		time.Sleep(delay)
+	}
+
+	if status != "ok" && status != "canceled" && isFallbackErrorCode(errCode) && len(spec.Fallback) > 0 {
+		next := spec.Fallback[0]
+		next.Fallback = spec.Fallback[1:]
+		next.Hops = append(append([]FallbackHop{}, spec.Hops...), FallbackHop{
+			Role:         spec.Role,
+			Model:        spec.Model,
+			Status:       status,
+			FailureClass: errCode,
+			Error:        extractConciseError(asyncOutputTail(stdoutFile, stderrFile, mark)+"\n"+errMsg, nil),
+		})
+		return next, true
 	}
 
 	finalMeta := AsyncMeta{
@@ -869,16 +1132,19 @@ func runAsyncAttempts(runID string, spec CmdSpec, stdoutFile, stderrFile *os.Fil
 		Attempts:     attempts,
 		ExitCode:     exitCode,
 		Error:        errMsg,
+		ErrorCode:    errCode,
 		StartedAt:    startedAt.Format(time.RFC3339),
 		EndedAt:      endedAt.Format(time.RFC3339),
 		PromptHash:   spec.PromptHash,
 		PromptLen:    spec.PromptLen,
 		ChangedFiles: changedFiles,
+		Hops:         spec.Hops,
 	}
 	if current, _, err := loadAsyncMeta(runID); err == nil {
 		finalMeta.CancelRequested = current.CancelRequested
 		if finalMeta.CancelRequested && finalMeta.Status != "ok" {
 			finalMeta.Status = "canceled"
+			finalMeta.ErrorCode = errCodeCanceled
 		}
 	}
 	_ = writeAsyncMeta(finalMeta)
@@ -900,11 +1166,15 @@ func runAsyncAttempts(runID string, spec CmdSpec, stdoutFile, stderrFile *os.Fil
 		Prompt:       spec.Prompt,
 		ChangedFiles: changedFiles,
 		Error:        errMsg,
+		ErrorCode:    finalMeta.ErrorCode,
+		Hops:         spec.Hops,
 	}
 	if finalMeta.Status == "ok" {
 		record.Error = ""
 	}
 	_ = appendRunRecord(record, spec.LogPrompt)
+	notifyResourceUpdated(runStdoutURI(runID))
+	notifyResourceUpdated(runStderrURI(runID))
 	if finalMeta.Status == "ok" {
 		_ = stdoutFile.Sync()
 		_ = stderrFile.Sync()
@@ -916,6 +1186,7 @@ func runAsyncAttempts(runID string, spec CmdSpec, stdoutFile, stderrFile *os.Fil
 			rememberSharedMemory(spec.Agent, spec.Role, strings.TrimSpace(mcpExtractText(output)))
 		}
 	}
+	return CmdSpec{}, false
 }
 
 func getRunStatus(runID string, tailBytes int) (map[string]interface{}, error) {
@@ -930,7 +1201,7 @@ func getRunStatus(runID string, tailBytes int) (map[string]interface{}, error) {
 	}
 	stdout := readTail(filepath.Join(dir, "stdout.log"), tailBytes)
 	stderr := readTail(filepath.Join(dir, "stderr.log"), tailBytes)
-	return map[string]interface{}{
+	payload := map[string]interface{}{
 		"run_id":        runID,
 		"status":        status,
 		"agent":         firstNonEmpty(meta.Role, meta.Agent),
@@ -943,11 +1214,16 @@ func getRunStatus(runID string, tailBytes int) (map[string]interface{}, error) {
 		"stdout":        strings.TrimSpace(stdout),
 		"stderr":        strings.TrimSpace(stderr),
 		"error":         meta.Error,
+		"error_code":    meta.ErrorCode,
 		"started_at":    meta.StartedAt,
 		"ended_at":      meta.EndedAt,
 		"read_files":    meta.ReadFiles,
 		"changed_files": meta.ChangedFiles,
-	}, nil
+	}
+	if len(meta.Hops) > 0 {
+		payload["hops"] = meta.Hops
+	}
+	return payload, nil
 }
 
 func waitRun(runID string, timeout time.Duration, tailBytes int) (map[string]interface{}, error) {
@@ -957,7 +1233,7 @@ func waitRun(runID string, timeout time.Duration, tailBytes int) (map[string]int
 		if err != nil {
 			return nil, err
 		}
-		if res["status"] != "running" || time.Now().After(deadline) {
+		if status := res["status"]; (status != "running" && status != "starting") || time.Now().After(deadline) {
 			return res, nil
 		}
 		time.Sleep(1 * time.Second)
@@ -975,12 +1251,15 @@ func cancelRun(runID string, force bool) (map[string]interface{}, error) {
 	meta.CancelRequested = true
 	_ = writeAsyncMeta(meta)
 	status := "cancelled"
-	if err := syscall.Kill(meta.PID, syscall.SIGTERM); err != nil {
-		status = "not_running"
-	}
+	// force skips the grace period; otherwise the group gets SIGTERM first and
+	// SIGKILL only if something is left after kill_grace_ms.
 	if force {
-		time.Sleep(500 * time.Millisecond)
-		_ = syscall.Kill(meta.PID, syscall.SIGKILL)
+		err = killProcessGroup(meta.PID, syscall.SIGKILL)
+	} else {
+		err = terminateProcessGroup(meta.PID, killGrace(meta.KillGraceMs))
+	}
+	if err != nil {
+		status = "not_running"
 	}
 	return map[string]interface{}{"run_id": runID, "status": status}, nil
 }
@@ -1023,7 +1302,16 @@ func reportRunLabel(report progressReporter, spec CmdSpec, prefix string) {
 	report(fmt.Sprintf("%s (%s)", prefix, label), 0, 1)
 }
 
-func runBatch(prompt, roles, configPath, modelOverride, reasoningOverride string, timeoutMs, idleTimeoutMs int, report progressReporter) (map[string]interface{}, error) {
+// errorResult is the batch result of an agent whose run could not start.
+func errorResult(agent string, err error) map[string]interface{} {
+	res := map[string]interface{}{"agent": agent, "status": "error", "error": err.Error()}
+	if code := errorCode(err); code != "" {
+		res["error_code"] = code
+	}
+	return res
+}
+
+func runBatch(ctx context.Context, prompt, roles, configPath, modelOverride, reasoningOverride string, timeoutMs, deadlineMs, idleTimeoutMs int, report progressReporter) (map[string]interface{}, error) {
 	if prompt == "" {
 		return nil, errors.New("Missing prompt")
 	}
@@ -1031,6 +1319,7 @@ func runBatch(prompt, roles, configPath, modelOverride, reasoningOverride string
 		return nil, errors.New("Missing roles")
 	}
 	configPath = resolveConfigPath(configPath)
+	deadline := batchDeadline(deadlineMs)
 
 	results := []map[string]interface{}{}
 	agentList := []string{}
@@ -1038,6 +1327,7 @@ func runBatch(prompt, roles, configPath, modelOverride, reasoningOverride string
 	type specEntry struct {
 		agent string
 		spec  CmdSpec
+		chain []CmdSpec // fallback hops, run in order instead of spec
 	}
 	entries := []specEntry{}
 
@@ -1082,23 +1372,39 @@ func runBatch(prompt, roles, configPath, modelOverride, reasoningOverride string
 		if roleCfg.MaxParallel > 0 && roleCfg.MaxParallel < maxParallel {
 			maxParallel = roleCfg.MaxParallel
 		}
-		models := expandModelEntries(roleCfg, modelOverride, reasoningOverride)
-		if len(models) == 0 {
-			models = []ModelEntry{{Name: roleCfg.Model, ReasoningEffort: roleCfg.Reasoning}}
-		}
 		taskPrompt := strings.TrimSpace(task.Prompt)
 		if taskPrompt == "" {
 			taskPrompt = prompt
 		}
+		if modelOverride == "" && roleCfg.ModelsMode == modelsModeFallback {
+			chain, err := buildFallbackSpecs(cfg, role, taskPrompt, reasoningOverride, logPrompt)
+			if err != nil {
+				results = append(results, errorResult(role, err))
+				continue
+			}
+			for i := range chain {
+				if idleTimeoutMs > 0 {
+					chain[i].IdleTimeoutMs = idleTimeoutMs
+				}
+				applyBatchTimeouts(&chain[i], timeoutMs, deadline)
+			}
+			entries = append(entries, specEntry{agent: role, spec: chain[0], chain: chain})
+			continue
+		}
+		models := expandModelEntries(roleCfg, modelOverride, reasoningOverride)
+		if len(models) == 0 {
+			models = []ModelEntry{{Name: roleCfg.Model, ReasoningEffort: roleCfg.Reasoning}}
+		}
 		for _, entry := range models {
 			spec, err := buildSpecFromRole(cfg, role, taskPrompt, entry.Name, entry.ReasoningEffort, logPrompt)
 			if err != nil {
-				results = append(results, map[string]interface{}{"agent": role, "status": "error", "error": err.Error()})
+				results = append(results, errorResult(role, err))
 				continue
 			}
 			if idleTimeoutMs > 0 {
 				spec.IdleTimeoutMs = idleTimeoutMs
 			}
+			applyBatchTimeouts(&spec, timeoutMs, deadline)
 			entries = append(entries, specEntry{agent: role, spec: spec})
 		}
 	}
@@ -1119,15 +1425,25 @@ func runBatch(prompt, roles, configPath, modelOverride, reasoningOverride string
 		wg.Add(1)
 		go func(e specEntry) {
 			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				// Canceled while queued: the run is recorded without starting.
+			}
 			reportRunLabel(report, e.spec, "starting")
-			res, err := runCommand(e.spec)
+			var res map[string]interface{}
+			var err error
+			if len(e.chain) > 0 {
+				res, err = runFallbackChain(ctx, e.chain, report)
+			} else {
+				res, err = runCommandContext(ctx, e.spec)
+			}
 			mu.Lock()
 			defer mu.Unlock()
 			label := formatRunLabel(e.spec)
 			if err != nil {
-				results = append(results, map[string]interface{}{"agent": e.agent, "status": "error", "error": err.Error()})
+				results = append(results, errorResult(e.agent, err))
 				if report != nil {
 					done := atomic.AddInt64(&completed, 1)
 					report(fmt.Sprintf("finished %s (error)", label), float64(done), float64(total))
@@ -1169,7 +1485,7 @@ func runBatch(prompt, roles, configPath, modelOverride, reasoningOverride string
 	}, nil
 }
 
-func runBatchAsync(prompt, roles, configPath, modelOverride, reasoningOverride string, timeoutMs, idleTimeoutMs int, report progressReporter) (map[string]interface{}, error) {
+func runBatchAsync(prompt, roles, configPath, modelOverride, reasoningOverride string, timeoutMs, deadlineMs, idleTimeoutMs int, report progressReporter) (map[string]interface{}, error) {
 	if prompt == "" {
 		return nil, errors.New("Missing prompt")
 	}
@@ -1177,6 +1493,7 @@ func runBatchAsync(prompt, roles, configPath, modelOverride, reasoningOverride s
 		return nil, errors.New("Missing roles")
 	}
 	configPath = resolveConfigPath(configPath)
+	deadline := batchDeadline(deadlineMs)
 
 	results := []map[string]interface{}{}
 	agentList := []string{}
@@ -1235,12 +1552,13 @@ func runBatchAsync(prompt, roles, configPath, modelOverride, reasoningOverride s
 		for _, entry := range models {
 			spec, err := buildSpecFromRole(cfg, role, taskPrompt, entry.Name, entry.ReasoningEffort, logPrompt)
 			if err != nil {
-				results = append(results, map[string]interface{}{"agent": role, "status": "error", "error": err.Error()})
+				results = append(results, errorResult(role, err))
 				continue
 			}
 			if idleTimeoutMs > 0 {
 				spec.IdleTimeoutMs = idleTimeoutMs
 			}
+			applyBatchTimeouts(&spec, timeoutMs, deadline)
 			entries = append(entries, specEntry{agent: role, spec: spec})
 		}
 	}
@@ -1254,7 +1572,7 @@ func runBatchAsync(prompt, roles, configPath, modelOverride, reasoningOverride s
 		res, err := startAsync(entry.spec)
 		label := formatRunLabel(entry.spec)
 		if err != nil {
-			results = append(results, map[string]interface{}{"agent": entry.agent, "status": "error", "error": err.Error()})
+			results = append(results, errorResult(entry.agent, err))
 			if report != nil {
 				started++
 				report(fmt.Sprintf("failed %s", label), float64(started), float64(total))
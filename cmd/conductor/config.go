@@ -12,17 +12,25 @@ type Config struct {
 	Defaults Defaults              `json:"defaults"`
 	Roles    map[string]RoleConfig `json:"roles"`
 	Runtime  RuntimeConfig         `json:"runtime"`
+	MCP      MCPConfig             `json:"mcp"`
+	Policy   PolicyConfig          `json:"policy"`
 	Disabled bool                  `json:"disabled,omitempty"`
 }
 
 // Defaults contains global default settings for all roles.
 type Defaults struct {
-	IdleTimeoutMs  int  `json:"idle_timeout_ms"`
-	MaxParallel    int  `json:"max_parallel"`
-	Retry          int  `json:"retry"`
-	RetryBackoffMs int  `json:"retry_backoff_ms"`
-	LogPrompt      bool `json:"log_prompt"`
-	SummaryOnly    bool `json:"summary_only"`
+	IdleTimeoutMs     int      `json:"idle_timeout_ms"`
+	TimeoutMs         int      `json:"timeout_ms"`    // wall-clock limit per attempt; 0 means none
+	KillGraceMs       int      `json:"kill_grace_ms"` // SIGTERM to SIGKILL delay when a run is stopped
+	MaxParallel       int      `json:"max_parallel"`
+	Retry             int      `json:"retry"`
+	RetryBackoffMs    int      `json:"retry_backoff_ms"`
+	RetryOn           []string `json:"retry_on"` // error codes to retry; rate_limit, quota and idle_timeout when empty
+	RetryMaxBackoffMs int      `json:"retry_max_backoff_ms"`
+	LogPrompt         bool     `json:"log_prompt"`
+	SummaryOnly       bool     `json:"summary_only"`
+	MaxDepth          int      `json:"max_depth"`       // deepest allowed nested conductor call
+	MaxDescendants    int      `json:"max_descendants"` // runs allowed below one top-level run
 }
 
 // RuntimeConfig controls queue and approval behavior for async runs.
@@ -39,31 +47,75 @@ type RuntimeQueueConfig struct {
 
 // RuntimeApprovalConfig defines which roles/agents require approval before execution.
 type RuntimeApprovalConfig struct {
-	Required bool     `json:"required"`
-	Roles    []string `json:"roles"`
-	Agents   []string `json:"agents"`
+	Required  bool     `json:"required"`
+	Roles     []string `json:"roles"`
+	Agents    []string `json:"agents"`
+	TimeoutMs int      `json:"timeout_ms"` // unanswered approvals expire after this; 0 waits forever
+}
+
+// MCPConfig controls what the `conductor mcp` server exposes.
+type MCPConfig struct {
+	Groups       []string `json:"groups"`
+	SessionTTLMs int      `json:"session_ttl_ms"`
+	MaxSessions  int      `json:"max_sessions"`
+	Transcripts  bool     `json:"transcripts"`
+	Token        string   `json:"token"`        // bearer token required by HTTP clients (--listen/--socket)
+	CommandsDir  string   `json:"commands_dir"` // slash command markdown served as prompts
+	Tools        []string `json:"tools"`        // tool names/patterns to expose (default: all)
+	Roles        []string `json:"roles"`        // roles the role-routing tools may run (default: all)
+	// SamplingFallback answers a role through the host's model when its CLI is
+	// missing or not ready.
+	SamplingFallback bool `json:"sampling_fallback"`
+	// Bundles from mcp-bundles.json whose enabled servers are proxied, with
+	// their tools exported as <server>.<tool>.
+	Bundles       []string `json:"bundles"`
+	BundlesConfig string   `json:"bundles_config"` // default: $CONDUCTOR_HOME/mcp-bundles.json, then ./config/mcp-bundles.json
+}
+
+// PolicyConfig caps the permissions MCP callers may request, per CLI and per
+// role. When both apply, the stricter limit wins.
+type PolicyConfig struct {
+	Mode  string                  `json:"mode"` // clamp (default) or reject
+	CLI   map[string]PolicyLimits `json:"cli"`
+	Roles map[string]PolicyLimits `json:"roles"`
+}
+
+// PolicyLimits is the most permissive setting allowed; empty fields are not capped.
+type PolicyLimits struct {
+	Sandbox        string   `json:"sandbox"`         // codex: read-only < workspace-write < danger-full-access
+	ApprovalPolicy string   `json:"approval_policy"` // codex: untrusted < on-request < on-failure < never
+	PermissionMode string   `json:"permission_mode"` // claude: plan < default/dontAsk < acceptEdits < bypassPermissions
+	Yolo           *bool    `json:"yolo"`            // gemini: false drops --yolo and approval-mode yolo
+	ApprovalMode   string   `json:"approval_mode"`   // gemini: default < auto_edit < yolo
+	CwdRoots       []string `json:"cwd_roots"`       // cwd and extra directories must be inside one of these
 }
 
 // RoleConfig defines a single role's CLI, model, and execution settings.
 type RoleConfig struct {
-	CLI            string            `json:"cli"`
-	Args           []string          `json:"args"`
-	ModelFlag      string            `json:"model_flag"`
-	Model          string            `json:"model"`
-	Models         []ModelEntry      `json:"models"`
-	ReasoningFlag  string            `json:"reasoning_flag"`
-	ReasoningKey   string            `json:"reasoning_key"`
-	Reasoning      string            `json:"reasoning"`
-	Description    string            `json:"description"`
-	ReadyCmd       string            `json:"ready_cmd"`
-	ReadyArgs      []string          `json:"ready_args"`
-	ReadyTimeoutMs int               `json:"ready_timeout_ms"`
-	Env            map[string]string `json:"env"`
-	Cwd            string            `json:"cwd"`
-	IdleTimeoutMs  int               `json:"idle_timeout_ms"`
-	MaxParallel    int               `json:"max_parallel"`
-	Retry          int               `json:"retry"`
-	RetryBackoffMs int               `json:"retry_backoff_ms"`
+	CLI               string            `json:"cli"`
+	Args              []string          `json:"args"`
+	ModelFlag         string            `json:"model_flag"`
+	Model             string            `json:"model"`
+	Models            []ModelEntry      `json:"models"`
+	ModelsMode        string            `json:"models_mode"` // parallel (default) or fallback
+	FallbackRole      string            `json:"fallback_role"`
+	ReasoningFlag     string            `json:"reasoning_flag"`
+	ReasoningKey      string            `json:"reasoning_key"`
+	Reasoning         string            `json:"reasoning"`
+	Description       string            `json:"description"`
+	ReadyCmd          string            `json:"ready_cmd"`
+	ReadyArgs         []string          `json:"ready_args"`
+	ReadyTimeoutMs    int               `json:"ready_timeout_ms"`
+	Env               map[string]string `json:"env"`
+	Cwd               string            `json:"cwd"`
+	IdleTimeoutMs     int               `json:"idle_timeout_ms"`
+	TimeoutMs         int               `json:"timeout_ms"`
+	KillGraceMs       int               `json:"kill_grace_ms"`
+	MaxParallel       int               `json:"max_parallel"`
+	Retry             int               `json:"retry"`
+	RetryBackoffMs    int               `json:"retry_backoff_ms"`
+	RetryOn           []string          `json:"retry_on"`
+	RetryMaxBackoffMs int               `json:"retry_max_backoff_ms"`
 }
 
 // ModelEntry represents a model configuration with optional reasoning effort.
@@ -164,7 +216,7 @@ func loadConfig(path string) (Config, error) {
 		return cfg, err
 	}
 	if err := json.Unmarshal(data, &cfg); err != nil {
-		return cfg, err
+		return cfg, withErrorCode(errCodeParseError, err)
 	}
 	return cfg, nil
 }
@@ -202,6 +254,12 @@ func normalizeDefaults(d Defaults) Defaults {
 	if d.RetryBackoffMs < 0 {
 		d.RetryBackoffMs = defaultRetryBackoffMs
 	}
+	if d.MaxDepth <= 0 {
+		d.MaxDepth = defaultMaxDepth
+	}
+	if d.MaxDescendants <= 0 {
+		d.MaxDescendants = defaultMaxDescendants
+	}
 	return d
 }
 
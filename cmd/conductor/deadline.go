@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errDeadlinePassed is returned by gateRun when a batch deadline passed while
+// the run was still waiting for a slot.
+var errDeadlinePassed = errors.New("batch deadline passed before the run started")
+
+// attemptDeadline is when an attempt starting at start must end: timeout_ms
+// after it starts or the batch deadline, whichever comes first. It is zero
+// when neither is set.
+func attemptDeadline(start time.Time, timeoutMs int, deadline time.Time) time.Time {
+	if timeoutMs > 0 {
+		if d := start.Add(time.Duration(timeoutMs) * time.Millisecond); deadline.IsZero() || d.Before(deadline) {
+			return d
+		}
+	}
+	return deadline
+}
+
+// withAttemptDeadline derives the context of one attempt. The idle timer
+// cancels it separately, so an expired deadline is the only way it ends with
+// context.DeadlineExceeded.
+func withAttemptDeadline(parent context.Context, timeoutMs int, deadline time.Time) (context.Context, context.CancelFunc) {
+	if d := attemptDeadline(time.Now(), timeoutMs, deadline); !d.IsZero() {
+		return context.WithDeadline(parent, d)
+	}
+	return context.WithCancel(parent)
+}
+
+// pastDeadline reports whether the batch deadline passes within wait, so a
+// retry or a queued run would not get to finish.
+func pastDeadline(deadline time.Time, wait time.Duration) bool {
+	return !deadline.IsZero() && !time.Now().Add(wait).Before(deadline)
+}
+
+// batchDeadline turns a batch's deadline_ms into an absolute deadline.
+func batchDeadline(deadlineMs int) time.Time {
+	if deadlineMs <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(deadlineMs) * time.Millisecond)
+}
+
+// applyBatchTimeouts applies a batch's timeout_ms to one of its runs and caps
+// the run at the batch deadline.
+func applyBatchTimeouts(spec *CmdSpec, timeoutMs int, deadline time.Time) {
+	applyTimeout(spec, timeoutMs)
+	spec.Deadline = deadline
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeChattyCLI never goes idle: it prints every 50ms until killed, with a
+// grandchild that must die with it.
+const fakeChattyCLI = `sleep 30 &
+echo $! > "$GRANDCHILD_PID_FILE"
+while true; do echo tick; sleep 0.05; done
+`
+
+func installChattyCLI(t *testing.T, name string) string {
+	t.Helper()
+	installFakeCLI(t, name, fakeChattyCLI)
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	t.Setenv("GRANDCHILD_PID_FILE", pidFile)
+	return pidFile
+}
+
+func TestRunCommandTimeoutOutlivesChattyCLI(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	pidFile := installChattyCLI(t, "gemini")
+
+	start := time.Now()
+	res, err := runCommand(CmdSpec{Agent: "gemini", Cmd: "gemini", IdleTimeoutMs: 200, TimeoutMs: 500})
+	if err != nil {
+		t.Fatalf("run command: %v", err)
+	}
+	if res["status"] != "deadline" || res["error_code"] != errCodeDeadline {
+		t.Fatalf("expected deadline, got %v", res)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("run took %v", elapsed)
+	}
+	assertProcessGone(t, waitForPIDFile(t, pidFile))
+	if record, ok, _ := findRunRecord(res["run_id"].(string)); !ok || record.Status != "deadline" || record.ErrorCode != errCodeDeadline {
+		t.Fatalf("expected deadline in run history, got %+v", record)
+	}
+
+	installFakeCLI(t, "gemini", "sleep 5\n")
+	res, err = runCommand(CmdSpec{Agent: "gemini", Cmd: "gemini", IdleTimeoutMs: 200, TimeoutMs: 10000})
+	if err != nil || res["status"] != "timeout" || res["error_code"] != errCodeIdleTimeout {
+		t.Fatalf("expected the idle timeout to fire first, got %v (%v)", res, err)
+	}
+}
+
+func TestAsyncRunTimeout(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	pidFile := installChattyCLI(t, "gemini")
+
+	started, err := startAsync(CmdSpec{Agent: "gemini", Cmd: "gemini", TimeoutMs: 500})
+	if err != nil {
+		t.Fatalf("start async: %v", err)
+	}
+	status, err := waitRun(started["run_id"].(string), 10*time.Second, 0)
+	if err != nil || status["status"] != "deadline" || status["error_code"] != errCodeDeadline {
+		t.Fatalf("expected deadline, got %v (%v)", status, err)
+	}
+	assertProcessGone(t, waitForPIDFile(t, pidFile))
+}
+
+func TestRunBatchDeadline(t *testing.T) {
+	withIsolatedMemoryStore(t, func() {})
+	content := `{
+  "defaults": { "max_parallel": 1 },
+  "roles": {
+    "scout": { "cli": "gemini" },
+    "sage": { "cli": "gemini" }
+  }
+}`
+	configPath := filepath.Join(t.TempDir(), "conductor.json")
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	installChattyCLI(t, "gemini")
+
+	res, err := runBatch(context.Background(), "map the repo", "scout,sage", configPath, "", "", 0, 500, 0, nil)
+	if err != nil {
+		t.Fatalf("run batch: %v", err)
+	}
+	results := res["results"].([]map[string]interface{})
+	if res["status"] != "partial" || len(results) != 2 {
+		t.Fatalf("expected two failed runs, got %v", res)
+	}
+	for _, r := range results {
+		if r["status"] != "deadline" || r["error_code"] != errCodeDeadline {
+			t.Fatalf("expected every run to hit the batch deadline, got %v", r)
+		}
+	}
+	if results[1]["attempt"] != 0 {
+		t.Fatalf("expected the queued run not to start, got %v", results[1])
+	}
+}
+
+func TestRunBatchStopsWhenCanceled(t *testing.T) {
+	withIsolatedMemoryStore(t, func() {})
+	content := `{
+  "defaults": { "max_parallel": 1 },
+  "roles": {
+    "scout": { "cli": "gemini" },
+    "sage": { "cli": "gemini" }
+  }
+}`
+	configPath := filepath.Join(t.TempDir(), "conductor.json")
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	pidFile := installChattyCLI(t, "gemini")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// Cancel once the first run is going; the second is still queued.
+		for {
+			if _, err := os.Stat(pidFile); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+	start := time.Now()
+	res, err := runBatch(ctx, "map the repo", "scout,sage", configPath, "", "", 0, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("run batch: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("batch ran on for %v after cancel", elapsed)
+	}
+	results := res["results"].([]map[string]interface{})
+	if len(results) != 2 {
+		t.Fatalf("expected two runs, got %v", res)
+	}
+	started := 0
+	for _, r := range results {
+		if r["status"] != "canceled" || r["error_code"] != errCodeCanceled {
+			t.Fatalf("expected every run canceled, got %v", r)
+		}
+		if r["attempt"] != 0 {
+			started++
+		}
+	}
+	if started != 1 {
+		t.Fatalf("expected only the first run to start, got %v", results)
+	}
+	assertProcessGone(t, waitForPIDFile(t, pidFile))
+}
+
+func TestCLIAdapterTimeout(t *testing.T) {
+	pidFile := installChattyCLI(t, "codex")
+	_, err := mcpCodexAdapter.Run(context.Background(), CLIRunOptions{TimeoutMs: 500})
+	if errorCode(err) != errCodeDeadline {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+	assertProcessGone(t, waitForPIDFile(t, pidFile))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"io"
@@ -41,6 +42,7 @@ func runDoctor(args []string) int {
 	fs.SetOutput(io.Discard)
 	configPath := fs.String("config", resolveConfigPath(""), "config path")
 	jsonOut := fs.Bool("json", false, "output JSON")
+	reap := fs.Bool("reap", false, "stop processes left running by conductor processes that exited")
 	if err := fs.Parse(args); err != nil {
 		fmt.Println("Invalid flags.")
 		return 1
@@ -54,13 +56,13 @@ func runDoctor(args []string) int {
 	errors := validateConfig(cfg)
 
 	if *jsonOut || !isTerminal(os.Stdout) {
-		return runDoctorPlain(cfg, errors)
+		return runDoctorPlain(cfg, errors, *reap)
 	}
 
-	return runDoctorPretty(cfg, *configPath, errors)
+	return runDoctorPretty(cfg, *configPath, errors, *reap)
 }
 
-func runDoctorPlain(cfg Config, errors []string) int {
+func runDoctorPlain(cfg Config, errors []string, reap bool) int {
 	if len(errors) > 0 {
 		for _, msg := range errors {
 			fmt.Println("Error:", msg)
@@ -102,13 +104,43 @@ func runDoctorPlain(cfg Config, errors []string) int {
 			}
 		}
 	}
+	orphans, err := findOrphanedProcessGroups()
+	if err != nil {
+		fmt.Println("Orphans: error:", err.Error())
+		missing = true
+	}
+	for _, orphan := range orphans {
+		if !reap {
+			fmt.Printf("Orphan: %s\n", describeOrphan(orphan))
+			missing = true
+			continue
+		}
+		if err := reapProcessGroup(orphan); err != nil {
+			fmt.Printf("Orphan: %s (reap failed: %s)\n", describeOrphan(orphan), err.Error())
+			missing = true
+			continue
+		}
+		fmt.Printf("Orphan: %s (reaped)\n", describeOrphan(orphan))
+	}
+	if len(orphans) > 0 && !reap {
+		fmt.Println("Run `conductor doctor --reap` to stop orphaned processes.")
+	}
 	if missing {
 		return 1
 	}
 	return 0
 }
 
-func runDoctorPretty(cfg Config, configPath string, errors []string) int {
+// describeOrphan is one line about an orphaned process group for doctor.
+func describeOrphan(orphan ProcessGroupRecord) string {
+	desc := fmt.Sprintf("pgid %d %s", orphan.PGID, orphan.Cmd)
+	if orphan.RunID != "" {
+		desc += " run " + orphan.RunID
+	}
+	return desc + " started " + orphan.StartedAt
+}
+
+func runDoctorPretty(cfg Config, configPath string, errors []string, reap bool) int {
 	var sb strings.Builder
 
 	title := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("99")).Render("🩺 Conductor Doctor")
@@ -181,6 +213,40 @@ func runDoctorPretty(cfg Config, configPath string, errors []string) int {
 		sb.WriteString("\n")
 	}
 
+	orphans, err := findOrphanedProcessGroups()
+	if err != nil {
+		sb.WriteString(iconError + " " + statusErrorStyle.Render("orphan check failed: "+err.Error()) + "\n\n")
+		hasIssues = true
+	}
+	if len(orphans) > 0 {
+		sb.WriteString(sectionStyle.Render("Orphaned Processes") + "\n\n")
+		for _, orphan := range orphans {
+			sb.WriteString("  " + iconWarn + " " + valueStyle.Render(describeOrphan(orphan)) + "\n")
+		}
+		sb.WriteString("\n")
+		if !reap && isTerminal(os.Stdin) {
+			fmt.Print(sb.String())
+			sb.Reset()
+			answer := promptLine(bufio.NewReader(os.Stdin), fmt.Sprintf("Stop %d orphaned process group(s)? (y/N)", len(orphans)), "")
+			reap = strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+		}
+		for _, orphan := range orphans {
+			if !reap {
+				hasIssues = true
+				continue
+			}
+			if err := reapProcessGroup(orphan); err != nil {
+				sb.WriteString("  " + iconError + " " + statusErrorStyle.Render(fmt.Sprintf("pgid %d: %s", orphan.PGID, err.Error())) + "\n")
+				hasIssues = true
+				continue
+			}
+			sb.WriteString("  " + iconOK + " " + statusOKStyle.Render(fmt.Sprintf("pgid %d stopped", orphan.PGID)) + "\n")
+		}
+		if reap {
+			sb.WriteString("\n")
+		}
+	}
+
 	if hasIssues {
 		summary := statusWarnStyle.Render("Some issues need attention")
 		sb.WriteString(summary + "\n")
@@ -205,12 +271,21 @@ func validateConfig(cfg Config) []string {
 	if cfg.Defaults.IdleTimeoutMs < 0 {
 		errors = append(errors, "defaults.idle_timeout_ms must be >= 0")
 	}
+	if cfg.Defaults.TimeoutMs < 0 {
+		errors = append(errors, "defaults.timeout_ms must be >= 0")
+	}
+	if cfg.Defaults.KillGraceMs < 0 {
+		errors = append(errors, "defaults.kill_grace_ms must be >= 0")
+	}
 	if cfg.Defaults.Retry < 0 {
 		errors = append(errors, "defaults.retry must be >= 0")
 	}
 	if cfg.Defaults.RetryBackoffMs < 0 {
 		errors = append(errors, "defaults.retry_backoff_ms must be >= 0")
 	}
+	if err := validateRetryOn(cfg.Defaults.RetryOn); err != nil {
+		errors = append(errors, "defaults.retry_on: "+err.Error())
+	}
 	for name, role := range cfg.Roles {
 		if _, err := normalizeRoleConfig(role); err != nil {
 			errors = append(errors, fmt.Sprintf("roles.%s.%s", name, err.Error()))
@@ -221,12 +296,24 @@ func validateConfig(cfg Config) []string {
 		if role.IdleTimeoutMs < 0 {
 			errors = append(errors, fmt.Sprintf("roles.%s.idle_timeout_ms must be >= 0", name))
 		}
+		if role.TimeoutMs < 0 {
+			errors = append(errors, fmt.Sprintf("roles.%s.timeout_ms must be >= 0", name))
+		}
+		if role.KillGraceMs < 0 {
+			errors = append(errors, fmt.Sprintf("roles.%s.kill_grace_ms must be >= 0", name))
+		}
 		if role.Retry < 0 {
 			errors = append(errors, fmt.Sprintf("roles.%s.retry must be >= 0", name))
 		}
 		if role.RetryBackoffMs < 0 {
 			errors = append(errors, fmt.Sprintf("roles.%s.retry_backoff_ms must be >= 0", name))
 		}
+		if err := validateRetryOn(role.RetryOn); err != nil {
+			errors = append(errors, fmt.Sprintf("roles.%s.retry_on: %s", name, err.Error()))
+		}
+	}
+	if _, err := resolveMCPPolicy(cfg.Policy); err != nil {
+		errors = append(errors, err.Error())
 	}
 	return errors
 }
@@ -24,6 +24,16 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErrors: 0,
 		},
+		{
+			name: "invalid policy",
+			cfg: Config{
+				Policy: PolicyConfig{CLI: map[string]PolicyLimits{"claude": {PermissionMode: "everything"}}},
+				Roles: map[string]RoleConfig{
+					"oracle": {CLI: "codex"},
+				},
+			},
+			wantErrors: 1,
+		},
 		{
 			name: "negative max_parallel",
 			cfg: Config{
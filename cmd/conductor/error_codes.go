@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Error codes reported as error_code on failed runs (payloads, async meta,
+// run history) and on MCP tool errors, so callers can branch on a stable
+// value instead of the error text.
+const (
+	errCodeMissingCLI  = "missing_cli"
+	errCodeNotReady    = "not_ready"
+	errCodeAuth        = "auth"
+	errCodeQuota       = "quota"
+	errCodeRateLimit   = "rate_limit"
+	errCodeIdleTimeout = "idle_timeout"
+	errCodeDeadline    = "deadline"
+	errCodeCanceled    = "canceled"
+	errCodeCLIExit     = "cli_exit"
+	errCodeParseError  = "parse_error"
+)
+
+// codedError attaches an error code to an error.
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+func withErrorCode(code string, err error) error {
+	if err == nil || code == "" {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// errorCode returns the code attached to err, or "".
+func errorCode(err error) string {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	return ""
+}
+
+// runErrorCode classifies a finished run from its status and the text it
+// printed (stdout, stderr and error). It returns "" unless the run failed.
+func runErrorCode(status string, idleTimedOut bool, output string) string {
+	switch status {
+	case "ok", "starting", "running", "queued", "awaiting_approval":
+		return ""
+	case "not_ready":
+		return errCodeNotReady
+	case "canceled", "rejected", "expired", "refused":
+		return errCodeCanceled
+	case "idle_timeout":
+		return errCodeIdleTimeout
+	case "timeout", "deadline":
+		if idleTimedOut {
+			return errCodeIdleTimeout
+		}
+		return errCodeDeadline
+	}
+	if class := failureClass(output); class != "" {
+		return class
+	}
+	return errCodeCLIExit
+}
+
+type toolErrorCodeKey struct{}
+
+// toolErrorCode carries the code of a failed tool call from the handler
+// back to errorCodeMiddleware.
+type toolErrorCode struct {
+	mu   sync.Mutex
+	code string
+}
+
+// noteToolErrorCode records the code of err for the current tool call.
+func noteToolErrorCode(ctx context.Context, err error) {
+	holder, ok := ctx.Value(toolErrorCodeKey{}).(*toolErrorCode)
+	if !ok {
+		return
+	}
+	if code := errorCode(err); code != "" {
+		holder.mu.Lock()
+		holder.code = code
+		holder.mu.Unlock()
+	}
+}
+
+// errorCodeMiddleware adds the error code of a failed tool call to the error
+// result, as _meta.error_code and at the end of the message.
+func errorCodeMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if method != "tools/call" {
+			return next(ctx, method, req)
+		}
+		holder := &toolErrorCode{}
+		res, err := next(context.WithValue(ctx, toolErrorCodeKey{}, holder), method, req)
+		result, ok := res.(*mcp.CallToolResult)
+		if err != nil || !ok || !result.IsError {
+			return res, err
+		}
+		holder.mu.Lock()
+		code := holder.code
+		holder.mu.Unlock()
+		if code == "" {
+			return res, err
+		}
+		if result.Meta == nil {
+			result.Meta = mcp.Meta{}
+		}
+		result.Meta["error_code"] = code
+		if len(result.Content) > 0 {
+			if text, ok := result.Content[0].(*mcp.TextContent); ok {
+				text.Text = fmt.Sprintf("%s (error_code: %s)", text.Text, code)
+			}
+		}
+		return res, err
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestRunErrorCode(t *testing.T) {
+	cases := []struct {
+		status string
+		idle   bool
+		output string
+		want   string
+	}{
+		{"ok", false, "quota", ""},
+		{"refused", false, "", errCodeCanceled},
+		{"not_ready", false, "", errCodeNotReady},
+		{"rejected", false, "", errCodeCanceled},
+		{"timeout", true, "", errCodeIdleTimeout},
+		{"timeout", false, "", errCodeDeadline},
+		{"error", false, "Error: 429 Too Many Requests", errCodeRateLimit},
+		{"error", false, "exit status 2", errCodeCLIExit},
+	}
+	for _, c := range cases {
+		if got := runErrorCode(c.status, c.idle, c.output); got != c.want {
+			t.Errorf("runErrorCode(%q, %t, %q) = %q, want %q", c.status, c.idle, c.output, got, c.want)
+		}
+	}
+	if code := errorCode(withErrorCode(errCodeAuth, errors.New("denied"))); code != errCodeAuth {
+		t.Fatalf("expected wrapped code, got %q", code)
+	}
+}
+
+func TestRunPayloadsCarryErrorCode(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	installFakeCLI(t, "gemini", "echo 'RESOURCE_EXHAUSTED: quota exceeded' >&2\nexit 1\n")
+
+	payload, err := runCommand(CmdSpec{Agent: "gemini", Cmd: "gemini"})
+	if err != nil {
+		t.Fatalf("run command: %v", err)
+	}
+	if payload["error_code"] != errCodeQuota {
+		t.Fatalf("expected quota code, got %v", payload)
+	}
+	record, ok, _ := findRunRecord(payload["run_id"].(string))
+	if !ok || record.ErrorCode != errCodeQuota {
+		t.Fatalf("expected quota code in history, got %+v", record)
+	}
+
+	started, err := startAsync(CmdSpec{Agent: "gemini", Cmd: "gemini"})
+	if err != nil {
+		t.Fatalf("start async: %v", err)
+	}
+	runID := started["run_id"].(string)
+	status, err := waitRun(runID, 10*time.Second, 0)
+	if err != nil || status["error_code"] != errCodeQuota {
+		t.Fatalf("expected quota code in run status, got %v (%v)", status, err)
+	}
+	if meta, _, _ := loadAsyncMeta(runID); meta.ErrorCode != errCodeQuota {
+		t.Fatalf("expected quota code in async meta, got %+v", meta)
+	}
+
+	if _, err := runCommand(CmdSpec{Agent: "ghost", Cmd: "conductor-missing-cli"}); errorCode(err) != errCodeMissingCLI {
+		t.Fatalf("expected missing_cli, got %v", err)
+	}
+}
+
+func TestSessionMalformedJSONIsParseError(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeSessionRuntimeConfig(t, "")
+	installFakeCLI(t, "codex", "echo 'Reading prompt from stdin...' >&2\necho '{\"type\":\"thread.st'\n")
+
+	_, err := mcpRunSessionWithConfig(context.Background(), "codex", "", "", "hi", "hi", []string{"exec", "--json", "hi"}, 0, MCPSessionConfig{})
+	if errorCode(err) != errCodeParseError {
+		t.Fatalf("expected parse_error, got %v", err)
+	}
+	runs := mcpRuntimeSnapshot().listRuns("error", 0)
+	if len(runs) != 1 || runs[0]["error_code"] != errCodeParseError || runs[0]["exit_code"] != 0 {
+		t.Fatalf("expected one parse_error run, got %v", runs)
+	}
+
+	// Without a JSON format flag, plain text is the answer.
+	if _, err := mcpRunSessionWithConfig(context.Background(), "codex", "", "", "hi", "hi", []string{"exec", "hi"}, 0, MCPSessionConfig{}); err != nil {
+		t.Fatalf("expected plain output to pass, got %v", err)
+	}
+}
+
+func TestContainsJSONOutput(t *testing.T) {
+	cases := map[string]bool{
+		"warning: slow\n{\"type\":\"result\",\"result\":\"hi\"}":   true,
+		"{\n  \"response\": \"hi\"\n}\nLoaded cached credentials.": true,
+		"plain text":                   false,
+		"{\"type\":\"thread.started\"": false,
+	}
+	for output, want := range cases {
+		if got := containsJSONOutput(output); got != want {
+			t.Errorf("containsJSONOutput(%q) = %v, want %v", output, got, want)
+		}
+	}
+}
+
+func TestToolErrorResultCarriesErrorCode(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, samplingConfig(false))
+	t.Setenv("PATH", t.TempDir())
+	var sampled *mcp.CreateMessageParams
+	_, session := connectTestClient(t, sampleWithText(&sampled))
+
+	res, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "conductor", Arguments: map[string]any{"role": "oracle", "prompt": "review this"}})
+	if err != nil {
+		t.Fatalf("call conductor: %v", err)
+	}
+	if !res.IsError || res.Meta["error_code"] != errCodeMissingCLI {
+		t.Fatalf("expected missing_cli error result, got %+v", res)
+	}
+	if text := res.Content[0].(*mcp.TextContent).Text; !strings.HasSuffix(text, "(error_code: missing_cli)") {
+		t.Fatalf("expected code in error text, got %q", text)
+	}
+}
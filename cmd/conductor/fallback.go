@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const modelsModeFallback = "fallback"
+
+// fallbackCandidate is one hop of a fallback chain: a role and one of its
+// models ("" runs the role's default model).
+type fallbackCandidate struct {
+	Role  string
+	Cfg   RoleConfig
+	Model ModelEntry
+}
+
+// fallbackChain lists the hops of a role in models_mode "fallback": its
+// models in order, then those of its fallback_role, following fallback roles
+// until one repeats or is outside the MCP role allowlist. It returns nil for
+// roles that fan models out in parallel.
+func fallbackChain(cfg Config, role string) ([]fallbackCandidate, error) {
+	if cfg.Roles[role].ModelsMode != modelsModeFallback {
+		return nil, nil
+	}
+	var chain []fallbackCandidate
+	seen := map[string]bool{}
+	for role != "" && !seen[role] {
+		if checkRoleAllowed(role) != nil {
+			break
+		}
+		seen[role] = true
+		roleCfg, ok := cfg.Roles[role]
+		if !ok {
+			return nil, fmt.Errorf("unknown fallback_role: %s", role)
+		}
+		models := roleCfg.Models
+		if len(models) == 0 {
+			models = []ModelEntry{{}}
+		}
+		for _, model := range models {
+			chain = append(chain, fallbackCandidate{Role: role, Cfg: roleCfg, Model: model})
+		}
+		role = roleCfg.FallbackRole
+	}
+	return chain, nil
+}
+
+// buildFallbackSpecs builds one spec per hop of a fallback-mode role, or nil
+// when the role is not in fallback mode.
+func buildFallbackSpecs(cfg Config, role, prompt, reasoningOverride string, logPrompt bool) ([]CmdSpec, error) {
+	chain, err := fallbackChain(cfg, role)
+	if err != nil || len(chain) == 0 {
+		return nil, err
+	}
+	specs := make([]CmdSpec, 0, len(chain))
+	for _, hop := range chain {
+		reasoning := firstNonEmpty(reasoningOverride, hop.Model.ReasoningEffort)
+		spec, err := buildSpecFromRoleConfig(cfg, hop.Role, hop.Cfg, prompt, hop.Model.Name, reasoning, logPrompt)
+		if err != nil {
+			return nil, fmt.Errorf("role %s: %w", hop.Role, err)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// runFallbackChain runs specs in order and returns the first run that does
+// not fail with a quota, rate_limit or auth error. Each run carries the hops
+// that failed before it, so the last payload and record list the whole chain.
+func runFallbackChain(ctx context.Context, specs []CmdSpec, report progressReporter) (map[string]interface{}, error) {
+	var hops []FallbackHop
+	for i, spec := range specs {
+		spec.Hops = hops
+		if i > 0 {
+			reportRunLabel(report, spec, "fallback")
+		}
+		res, err := runCommandContext(ctx, spec)
+		if err != nil || res["status"] == "ok" || i == len(specs)-1 {
+			return res, err
+		}
+		class, _ := res["error_code"].(string)
+		if !isFallbackErrorCode(class) {
+			return res, nil
+		}
+		runID, _ := res["run_id"].(string)
+		status, _ := res["status"].(string)
+		hops = append(hops, FallbackHop{
+			RunID:        runID,
+			Role:         spec.Role,
+			Model:        spec.Model,
+			Status:       status,
+			FailureClass: class,
+			Error:        extractConciseError(payloadText(res), nil),
+		})
+	}
+	return nil, fmt.Errorf("empty fallback chain")
+}
+
+// hopsError is the failure of the last hop of a fallback chain, carrying the
+// hops that failed before it.
+type hopsError struct {
+	err  error
+	hops []FallbackHop
+}
+
+func (e *hopsError) Error() string {
+	tried := make([]string, 0, len(e.hops))
+	for _, hop := range e.hops {
+		tried = append(tried, fmt.Sprintf("%s/%s: %s", hop.Role, firstNonEmpty(hop.Model, "default"), hop.FailureClass))
+	}
+	return fmt.Sprintf("%v (after %s)", e.err, strings.Join(tried, ", "))
+}
+
+func (e *hopsError) Unwrap() error { return e.err }
+
+// withHops attaches the failed hops before err to it; without hops it
+// returns err unchanged.
+func withHops(err error, hops []FallbackHop) error {
+	if err == nil || len(hops) == 0 {
+		return err
+	}
+	return &hopsError{err: err, hops: hops}
+}
+
+// isFallbackErrorCode reports whether a failure moves a chain to its next hop.
+func isFallbackErrorCode(code string) bool {
+	return code == errCodeQuota || code == errCodeRateLimit || code == errCodeAuth
+}
+
+func payloadText(res map[string]interface{}) string {
+	parts := []string{}
+	for _, key := range []string{"stderr", "stdout", "error"} {
+		if s, ok := res[key].(string); ok && s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
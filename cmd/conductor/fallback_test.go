@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// fakeGeminiLimited fails every model: pro is rate limited and flash is out
+// of quota, unless the prompt asks for a plain crash.
+const fakeGeminiLimited = `case "$*" in
+*crash*) echo "segmentation fault" >&2; exit 2 ;;
+*"--model pro"*) echo "429 Too Many Requests" >&2; exit 1 ;;
+*) echo "RESOURCE_EXHAUSTED: quota exceeded" >&2; exit 1 ;;
+esac
+`
+
+const fallbackConfig = `{
+  "runtime": { "max_parallel": 1 },
+  "roles": {
+    "scout": { "cli": "gemini", "models": ["pro", "flash"], "models_mode": "fallback", "fallback_role": "sage" },
+    "sage": { "cli": "codex", "model": "o3", "fallback_role": "scout" }
+  }
+}`
+
+func TestFailureClass(t *testing.T) {
+	cases := map[string]string{
+		"RESOURCE_EXHAUSTED":                  errCodeQuota,
+		"HTTP 429 Too Many Requests":          errCodeRateLimit,
+		"error: 401 Unauthorized":             errCodeAuth,
+		"invalid api key":                     errCodeAuth,
+		"run run-1760401290000-4291 rejected": "",
+		"exit status 1":                       "",
+	}
+	for text, want := range cases {
+		if got := failureClass(text); got != want {
+			t.Errorf("failureClass(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestFallbackChainFollowsFallbackRoles(t *testing.T) {
+	cfg, err := loadConfig(writeTestConfig(t, fallbackConfig))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	chain, err := fallbackChain(cfg, "scout")
+	if err != nil {
+		t.Fatalf("fallback chain: %v", err)
+	}
+	var hops []string
+	for _, hop := range chain {
+		hops = append(hops, hop.Role+"/"+hop.Model.Name)
+	}
+	if len(hops) != 3 || hops[0] != "scout/pro" || hops[1] != "scout/flash" || hops[2] != "sage/" {
+		t.Fatalf("unexpected chain %v", hops)
+	}
+	if chain, _ := fallbackChain(cfg, "sage"); chain != nil {
+		t.Fatalf("expected no chain for a parallel role, got %+v", chain)
+	}
+	withAllowlist(t, mcpAllowlist{Roles: []string{"scout"}})
+	specs, err := buildFallbackSpecs(cfg, "scout", "hi", "", false)
+	if err != nil {
+		t.Fatalf("fallback specs: %v", err)
+	}
+	for _, spec := range specs {
+		if spec.Role != "scout" {
+			t.Fatalf("expected the chain to stop before a role outside the allowlist, got %s", spec.Role)
+		}
+	}
+	if len(specs) != 2 {
+		t.Fatalf("expected both scout hops, got %d", len(specs))
+	}
+}
+
+// runToolAndWait queues prompt for role through conductor.run and waits for
+// it with conductor.run_wait.
+func runToolAndWait(t *testing.T, session *mcp.ClientSession, role, prompt string) *RunResult {
+	t.Helper()
+	ctx := context.Background()
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "conductor.run", Arguments: map[string]any{"role": role, "prompt": prompt}})
+	if err != nil || res.IsError {
+		t.Fatalf("call conductor.run: %v %+v", err, res)
+	}
+	queued, err := toolResult[RunResult](res.StructuredContent.(map[string]any))
+	if err != nil {
+		t.Fatalf("decode run: %v", err)
+	}
+	res, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "conductor.run_wait", Arguments: map[string]any{"run_id": queued.RunID, "timeout_ms": 10000}})
+	if err != nil || res.IsError {
+		t.Fatalf("call conductor.run_wait: %v %+v", err, res)
+	}
+	done, err := toolResult[RunResult](res.StructuredContent.(map[string]any))
+	if err != nil {
+		t.Fatalf("decode wait: %v", err)
+	}
+	return done
+}
+
+func TestRunToolFallsBackOnQuotaAndRateLimit(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, fallbackConfig)
+	installFakeCLI(t, "gemini", fakeGeminiLimited)
+	installFakeCLI(t, "codex", "echo done\n")
+	_, session := connectTestClient(t, mcp.ClientOptions{})
+
+	result := runToolAndWait(t, session, "scout", "map the repo")
+	if result.Status != "ok" || result.Role != "sage" {
+		t.Fatalf("expected the fallback role to answer, got %+v", result)
+	}
+	hops := result.Hops
+	if len(hops) != 2 || hops[0].Model != "pro" || hops[0].FailureClass != errCodeRateLimit || hops[1].Model != "flash" || hops[1].FailureClass != errCodeQuota {
+		t.Fatalf("unexpected hops %+v", hops)
+	}
+	record, ok, _ := findRunRecord(result.RunID)
+	if !ok || record.Role != "sage" || len(record.Hops) != 2 {
+		t.Fatalf("expected hops in run history, got %+v", record)
+	}
+}
+
+func TestRunToolStopsFallbackOnOtherFailures(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, fallbackConfig)
+	installFakeCLI(t, "gemini", fakeGeminiLimited)
+	installFakeCLI(t, "codex", "echo done\n")
+	_, session := connectTestClient(t, mcp.ClientOptions{})
+
+	result := runToolAndWait(t, session, "scout", "crash")
+	if result.Status == "ok" || result.Model != "pro" || len(result.Hops) != 0 {
+		t.Fatalf("expected the chain to stop at the first hop, got %+v", result)
+	}
+}
+
+func TestRunBatchFallbackChain(t *testing.T) {
+	withIsolatedMemoryStore(t, func() {})
+	configPath := writeTestConfig(t, fallbackConfig)
+	installFakeCLI(t, "gemini", fakeGeminiLimited)
+	installFakeCLI(t, "codex", "echo done\n")
+
+	res, err := runBatch(context.Background(), "map the repo", "scout", configPath, "", "", 0, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("run batch: %v", err)
+	}
+	results := res["results"].([]map[string]interface{})
+	if res["status"] != "ok" || len(results) != 1 || results[0]["role"] != "sage" {
+		t.Fatalf("expected one fallback result, got %v", res)
+	}
+}
+
+func TestRoleSessionFallsBack(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, fallbackConfig)
+	installFakeCLI(t, "gemini", fakeGeminiLimited)
+	installFakeCLI(t, "codex", fakeCodexSession)
+
+	res, err := mcpRunRoleSession(context.Background(), MCPConductorInput{Role: "scout", Prompt: "map the repo"})
+	if err != nil {
+		t.Fatalf("role session: %v", err)
+	}
+	structured := res.StructuredContent
+	if structured.Role != "sage" || structured.CLI != "codex" || len(structured.Hops) != 2 || structured.Hops[1].FailureClass != errCodeQuota {
+		t.Fatalf("unexpected session result %+v", structured)
+	}
+	for _, hop := range structured.Hops {
+		if record, ok, _ := findRunRecord(hop.RunID); !ok || record.Role != "scout" {
+			t.Fatalf("expected hop %+v to link its run, got %+v", hop, record)
+		}
+	}
+	runs := mcpRuntimeSnapshot().listRuns("ok", 0)
+	if len(runs) != 1 {
+		t.Fatalf("expected one completed run, got %v", runs)
+	}
+	record, ok, _ := findRunRecord(runs[0]["run_id"].(string))
+	if !ok || len(record.Hops) != 2 {
+		t.Fatalf("expected hops in run history, got %+v", record)
+	}
+}
+
+func TestRoleSessionFallbackSkipsDisallowedRoles(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, fallbackConfig)
+	withAllowlist(t, mcpAllowlist{Roles: []string{"scout"}})
+	installFakeCLI(t, "gemini", fakeGeminiLimited)
+	installFakeCLI(t, "codex", fakeCodexSession)
+
+	_, err := mcpRunRoleSession(context.Background(), MCPConductorInput{Role: "scout", Prompt: "map the repo"})
+	if errorCode(err) != errCodeQuota {
+		t.Fatalf("expected the last allowed hop to fail with quota, got %v", err)
+	}
+	var failed *hopsError
+	if !errors.As(err, &failed) || len(failed.hops) != 1 || failed.hops[0].FailureClass != errCodeRateLimit || !strings.Contains(err.Error(), "scout/pro: rate_limit") {
+		t.Fatalf("expected the earlier hop with the error, got %v", err)
+	}
+	if runs := mcpRuntimeSnapshot().listRuns("", 0); len(runs) != 2 {
+		t.Fatalf("expected only the two scout hops to run, got %v", runs)
+	}
+}
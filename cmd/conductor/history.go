@@ -10,23 +10,39 @@ import (
 )
 
 type RunRecord struct {
-	ID           string   `json:"id"`
-	Agent        string   `json:"agent,omitempty"`
-	Role         string   `json:"role,omitempty"`
-	Model        string   `json:"model,omitempty"`
-	Cmd          string   `json:"cmd"`
-	Args         []string `json:"args,omitempty"`
-	Status       string   `json:"status"`
-	ExitCode     int      `json:"exit_code"`
-	StartedAt    string   `json:"started_at"`
-	EndedAt      string   `json:"ended_at"`
-	DurationMs   int64    `json:"duration_ms"`
-	PromptHash   string   `json:"prompt_hash,omitempty"`
-	PromptLen    int      `json:"prompt_len,omitempty"`
-	Prompt       string   `json:"prompt,omitempty"`
-	ReadFiles    []string `json:"read_files,omitempty"`
-	ChangedFiles []string `json:"changed_files,omitempty"`
-	Error        string   `json:"error,omitempty"`
+	ID           string        `json:"id"`
+	Agent        string        `json:"agent,omitempty"`
+	Role         string        `json:"role,omitempty"`
+	Model        string        `json:"model,omitempty"`
+	Cmd          string        `json:"cmd"`
+	Args         []string      `json:"args,omitempty"`
+	Status       string        `json:"status"`
+	ExitCode     int           `json:"exit_code"`
+	StartedAt    string        `json:"started_at"`
+	EndedAt      string        `json:"ended_at"`
+	DurationMs   int64         `json:"duration_ms"`
+	PromptHash   string        `json:"prompt_hash,omitempty"`
+	PromptLen    int           `json:"prompt_len,omitempty"`
+	Prompt       string        `json:"prompt,omitempty"`
+	ReadFiles    []string      `json:"read_files,omitempty"`
+	ChangedFiles []string      `json:"changed_files,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	ErrorCode    string        `json:"error_code,omitempty"`
+	Depth        int           `json:"depth,omitempty"`
+	RootRun      string        `json:"root_run,omitempty"`
+	ParentRun    string        `json:"parent_run,omitempty"`
+	Hops         []FallbackHop `json:"hops,omitempty"`
+}
+
+// FallbackHop is an earlier attempt of a fallback chain that failed with a
+// quota, rate_limit or auth error before the recorded run.
+type FallbackHop struct {
+	RunID        string `json:"run_id,omitempty"`
+	Role         string `json:"role"`
+	Model        string `json:"model,omitempty"`
+	Status       string `json:"status"`
+	FailureClass string `json:"failure_class"`
+	Error        string `json:"error,omitempty"`
 }
 
 var runLogMu sync.Mutex
@@ -40,6 +56,10 @@ func appendRunRecord(record RunRecord, logPrompt bool) error {
 	if !logPrompt {
 		record.Prompt = ""
 	}
+	if record.Depth == 0 {
+		lineage := lineageForRun(record.ID)
+		record.Depth, record.RootRun, record.ParentRun = lineage.Depth, lineage.RootRun, lineage.ParentRun
+	}
 	line, err := json.Marshal(record)
 	if err != nil {
 		return err
@@ -57,8 +77,12 @@ func appendRunRecord(record RunRecord, logPrompt bool) error {
 		return err
 	}
 	defer f.Close()
-	_, err = f.Write(append(line, '\n'))
-	return err
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	notifyResourceUpdated(historyResourceURI)
+	notifyResourceUpdated(runResourceURI(record.ID))
+	return nil
 }
 
 func readRunHistory(limit int, status, role, agent string) ([]RunRecord, error) {
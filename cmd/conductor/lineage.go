@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Nested conductor calls (a delegated CLI calling the conductor MCP tool,
+// which delegates again) are tracked through the environment of every
+// delegate process.
+const (
+	envConductorDepth     = "CONDUCTOR_DEPTH"      // depth of the delegate (1 = started by a top-level conductor)
+	envConductorRootRun   = "CONDUCTOR_ROOT_RUN"   // run ID at the top of the chain
+	envConductorParentRun = "CONDUCTOR_PARENT_RUN" // run ID of the delegate that started this conductor
+
+	defaultMaxDepth       = 3
+	defaultMaxDescendants = 32
+)
+
+// runLineage places a run in its chain of nested conductor calls.
+type runLineage struct {
+	Depth     int
+	RootRun   string
+	ParentRun string
+}
+
+// processDepth is the depth of the delegate this conductor runs inside; 0
+// for a top-level conductor.
+func processDepth() int {
+	depth, err := strconv.Atoi(strings.TrimSpace(os.Getenv(envConductorDepth)))
+	if err != nil || depth < 0 {
+		return 0
+	}
+	return depth
+}
+
+// lineageForRun returns the lineage of a run started by this process. A
+// top-level run is the root of its own chain.
+func lineageForRun(runID string) runLineage {
+	root := strings.TrimSpace(os.Getenv(envConductorRootRun))
+	if root == "" {
+		root = runID
+	}
+	return runLineage{
+		Depth:     processDepth() + 1,
+		RootRun:   root,
+		ParentRun: strings.TrimSpace(os.Getenv(envConductorParentRun)),
+	}
+}
+
+// lineageEnv returns env plus the variables a delegate of runID inherits.
+func lineageEnv(env map[string]string, runID string) map[string]string {
+	lineage := lineageForRun(runID)
+	out := make(map[string]string, len(env)+3)
+	for k, v := range env {
+		out[k] = v
+	}
+	out[envConductorDepth] = strconv.Itoa(lineage.Depth)
+	out[envConductorRootRun] = lineage.RootRun
+	out[envConductorParentRun] = runID
+	return out
+}
+
+// admitRun refuses a run when this conductor is nested deeper than
+// defaults.max_depth allows or when the chain has used up its
+// defaults.max_descendants budget. Top-level runs are always admitted.
+func admitRun(runID string) error {
+	depth := processDepth()
+	if depth == 0 {
+		return nil
+	}
+	cfg, _ := loadConfigOrEmpty(resolveConfigPath(""))
+	defaults := normalizeDefaults(cfg.Defaults)
+	lineage := lineageForRun(runID)
+	if lineage.Depth > defaults.MaxDepth {
+		return fmt.Errorf("refused: nesting depth %d exceeds max_depth %d (root run %s)", lineage.Depth, defaults.MaxDepth, lineage.RootRun)
+	}
+	count, err := countDescendant(lineage.RootRun)
+	if err != nil {
+		return err
+	}
+	if count > defaults.MaxDescendants {
+		return fmt.Errorf("refused: root run %s exceeded max_descendants %d", lineage.RootRun, defaults.MaxDescendants)
+	}
+	return nil
+}
+
+func lineageDir() string {
+	baseDir := getenv("CONDUCTOR_HOME", filepath.Join(os.Getenv("HOME"), ".conductor-kit"))
+	return filepath.Join(baseDir, "lineage")
+}
+
+func lineageCounterPath(rootRun string) string {
+	return filepath.Join(lineageDir(), filepath.Base(rootRun)+".count")
+}
+
+// pruneLineageCounters removes counters of chains that admitted no run since
+// cutoff. Every admission writes to its counter, so a live chain is kept.
+func pruneLineageCounters(cutoff time.Time) ([]string, error) {
+	entries, err := os.ReadDir(lineageDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !strings.HasSuffix(entry.Name(), ".count") || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(lineageDir(), entry.Name())); err == nil {
+			removed = append(removed, strings.TrimSuffix(entry.Name(), ".count"))
+		}
+	}
+	return removed, nil
+}
+
+// countDescendant records one more descendant of rootRun and returns its
+// ordinal. Each admission appends one byte; with O_APPEND the offset after
+// the write is this run's position, so concurrent conductor processes in the
+// same chain never share an ordinal.
+func countDescendant(rootRun string) (int, error) {
+	path := lineageCounterPath(rootRun)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte{'.'}); err != nil {
+		return 0, err
+	}
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return int(pos), nil
+}
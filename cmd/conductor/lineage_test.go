@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func lineageConfig(maxDepth, maxDescendants int) string {
+	return fmt.Sprintf(`{"defaults": {"max_depth": %d, "max_descendants": %d}, "roles": {}}`, maxDepth, maxDescendants)
+}
+
+func TestLineagePropagatesToChildren(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	t.Setenv(envConductorDepth, "")
+	t.Setenv(envConductorRootRun, "")
+	t.Setenv(envConductorParentRun, "")
+	withIsolatedMemoryStore(t, func() {})
+	installFakeCLI(t, "codex", `echo "depth=$CONDUCTOR_DEPTH root=$CONDUCTOR_ROOT_RUN parent=$CONDUCTOR_PARENT_RUN"`+"\n")
+
+	payload, err := runCommand(CmdSpec{Agent: "codex", Cmd: "codex"})
+	if err != nil {
+		t.Fatalf("run command: %v", err)
+	}
+	runID := payload["run_id"].(string)
+	want := "depth=1 root=" + runID + " parent=" + runID
+	if !strings.Contains(payload["stdout"].(string), want) {
+		t.Fatalf("expected child env %q, got %q", want, payload["stdout"])
+	}
+	record, ok, err := findRunRecord(runID)
+	if err != nil || !ok {
+		t.Fatalf("find record: %v %v", ok, err)
+	}
+	if record.Depth != 1 || record.RootRun != runID || record.ParentRun != "" {
+		t.Fatalf("unexpected lineage in history: %+v", record)
+	}
+}
+
+func TestNestedRunRecordsChain(t *testing.T) {
+	t.Setenv(envConductorDepth, "1")
+	t.Setenv(envConductorRootRun, "run-root")
+	t.Setenv(envConductorParentRun, "run-parent")
+	writeTestConfig(t, lineageConfig(3, 10))
+	withIsolatedMemoryStore(t, func() {})
+	installFakeCLI(t, "codex", `echo "depth=$CONDUCTOR_DEPTH root=$CONDUCTOR_ROOT_RUN"`+"\n")
+
+	payload, err := runCommand(CmdSpec{Agent: "codex", Cmd: "codex"})
+	if err != nil {
+		t.Fatalf("run command: %v", err)
+	}
+	if !strings.Contains(payload["stdout"].(string), "depth=2 root=run-root") {
+		t.Fatalf("unexpected child env: %v", payload)
+	}
+	record, _, _ := findRunRecord(payload["run_id"].(string))
+	if record.Depth != 2 || record.RootRun != "run-root" || record.ParentRun != "run-parent" {
+		t.Fatalf("unexpected lineage in history: %+v", record)
+	}
+}
+
+func TestAdmitRunRefusesTooDeep(t *testing.T) {
+	t.Setenv(envConductorDepth, "2")
+	t.Setenv(envConductorRootRun, "run-root")
+	writeTestConfig(t, lineageConfig(2, 10))
+	withIsolatedMemoryStore(t, func() {})
+	installFakeCLI(t, "codex", "echo should-not-run\n")
+
+	payload, err := runCommand(CmdSpec{Agent: "codex", Cmd: "codex"})
+	if err != nil {
+		t.Fatalf("run command: %v", err)
+	}
+	if payload["status"] != "refused" || payload["error_code"] != errCodeCanceled || !strings.Contains(payload["error"].(string), "max_depth 2") {
+		t.Fatalf("expected refusal, got %v", payload)
+	}
+	record, ok, _ := findRunRecord(payload["run_id"].(string))
+	if !ok || record.Status != "refused" || record.Depth != 3 {
+		t.Fatalf("expected refused run in history, got %+v", record)
+	}
+
+	started, err := startAsync(CmdSpec{Agent: "codex", Cmd: "codex"})
+	if err != nil {
+		t.Fatalf("start async: %v", err)
+	}
+	if started["status"] != "refused" {
+		t.Fatalf("expected async refusal, got %v", started)
+	}
+}
+
+func TestAdmitRunDescendantBudget(t *testing.T) {
+	t.Setenv(envConductorDepth, "1")
+	t.Setenv(envConductorRootRun, "run-budget")
+	writeTestConfig(t, lineageConfig(5, 3))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	admitted := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if admitRun(newRunID()) == nil {
+				mu.Lock()
+				admitted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if admitted != 3 {
+		t.Fatalf("expected exactly 3 admitted runs, got %d", admitted)
+	}
+	if err := admitRun(newRunID()); err == nil || !strings.Contains(err.Error(), "max_descendants 3") {
+		t.Fatalf("expected budget refusal, got %v", err)
+	}
+}
+
+func TestPruneLineageCounters(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	for _, root := range []string{"run-old", "run-live"} {
+		if _, err := countDescendant(root); err != nil {
+			t.Fatalf("count %s: %v", root, err)
+		}
+	}
+	info, err := os.Stat(lineageCounterPath("run-live"))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected private counter file, got %v (%v)", info, err)
+	}
+	old := time.Now().Add(-2 * runDirRetention)
+	if err := os.Chtimes(lineageCounterPath("run-old"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := pruneLineageCounters(time.Now().Add(-runDirRetention))
+	if err != nil || len(removed) != 1 || removed[0] != "run-old" {
+		t.Fatalf("expected only the stale counter removed, got %v (%v)", removed, err)
+	}
+	if !pathExists(lineageCounterPath("run-live")) {
+		t.Fatal("expected live counter to be kept")
+	}
+}
+
+func TestAdmitRunTopLevelAlwaysAllowed(t *testing.T) {
+	t.Setenv(envConductorDepth, "")
+	writeTestConfig(t, lineageConfig(1, 1))
+	for i := 0; i < 3; i++ {
+		if err := admitRun(newRunID()); err != nil {
+			t.Fatalf("expected top-level run to be admitted: %v", err)
+		}
+	}
+}
@@ -44,6 +44,10 @@ func main() {
 		os.Exit(runMCPBundle(rest))
 	case "mcp":
 		os.Exit(runMCPServer(rest))
+	case "sessions":
+		os.Exit(runSessions(rest))
+	case "transcript":
+		os.Exit(runTranscript(rest))
 
 	default:
 		printHelp()
@@ -64,6 +68,8 @@ func resolveCommand(args []string) (string, []string) {
 		"doctor":          true,
 		"mcp-bundle":      true,
 		"mcp":             true,
+		"sessions":        true,
+		"transcript":      true,
 	}
 
 	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
@@ -85,6 +91,7 @@ func resolveCommand(args []string) (string, []string) {
 		"conductor-doctor":          "doctor",
 		"conductor-mcp-bundle":      "mcp-bundle",
 		"conductor-mcp":             "mcp",
+		"conductor-sessions":        "sessions",
 
 		"conductor-config-validate.exe": "config-validate",
 		"conductor-doctor.exe":          "doctor",
@@ -95,6 +102,7 @@ func resolveCommand(args []string) (string, []string) {
 		"conductor-mcp-bundle.exe":      "mcp-bundle",
 		"conductor-mcp.exe":             "mcp",
 		"conductor-status.exe":          "status",
+		"conductor-sessions.exe":        "sessions",
 	}
 
 	exe := filepath.Base(os.Args[0])
@@ -123,9 +131,11 @@ Commands:
   status               Check CLI availability and readiness
   roles                List role -> CLI/model mappings
   config-validate      Validate conductor config JSON
-  doctor               Check config and CLI availability
+  doctor               Check config, CLI availability and orphaned processes
   mcp-bundle           Render MCP bundle templates for hosts
   mcp                  Run unified MCP server (codex/claude/gemini + conductor)
+  sessions             List, inspect, or delete MCP sessions (threadIds)
+  transcript           Export a recorded thread transcript (Markdown/JSON)
   version              Show version information
 
 	Aliases:
@@ -133,6 +143,6 @@ Commands:
 	  conductor-disable, conductor-enable, conductor-uninstall
 	  conductor-settings, conductor-status
   conductor-config-validate, conductor-doctor
-  conductor-mcp-bundle, conductor-mcp
+  conductor-mcp-bundle, conductor-mcp, conductor-sessions
 `, Version)
 }
@@ -3,111 +3,89 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
-	"os"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-func runMCP(args []string) int {
-	_ = args
-	server := mcp.NewServer(&mcp.Implementation{
-		Name:    "conductor-kit",
-		Version: "0.1.0",
-	}, &mcp.ServerOptions{
-		HasResources: true,
-		SubscribeHandler: func(ctx context.Context, req *mcp.SubscribeRequest) error {
-			if req.Params.URI != runtimeQueueResourceURI {
-				return fmt.Errorf("unknown resource: %s", req.Params.URI)
-			}
-			return nil
-		},
-		UnsubscribeHandler: func(ctx context.Context, req *mcp.UnsubscribeRequest) error {
-			if req.Params.URI != runtimeQueueResourceURI {
-				return fmt.Errorf("unknown resource: %s", req.Params.URI)
-			}
-			return nil
-		},
-	})
-
+// registerRuntimeTools adds the async run, queue and approval tools plus the
+// runtime queue resource.
+func registerRuntimeTools(server *mcp.Server) {
 	server.AddResource(&mcp.Resource{
 		URI:         runtimeQueueResourceURI,
 		Name:        "Runtime queue",
 		Description: "Live runtime queue state for conductor.",
 		MIMEType:    "application/json",
 	}, runtimeQueueResourceHandler)
-	setRuntimeNotify(func(ctx context.Context) error {
-		return server.ResourceUpdated(ctx, &mcp.ResourceUpdatedNotificationParams{
-			URI: runtimeQueueResourceURI,
-		})
-	})
-	defer setRuntimeNotify(nil)
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.run",
 		Description: "Run a single role/agent asynchronously and return run_id(s).",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input RunInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input RunInput) (*mcp.CallToolResult, *RunResult, error) {
 		report := progressReporterForRequest(ctx, req)
 		if report != nil {
 			report("started", 0, 1)
 		}
-		payload, err := runAsyncTool(input, report)
+		payload, err := runAsyncTool(ctx, input, report)
 		if err != nil {
 			return nil, nil, err
 		}
 		if report != nil {
 			report("completed", 1, 1)
 		}
-		return nil, payload, nil
+		result, err := toolResult[RunResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.run_batch",
 		Description: "Run multiple roles/agents in parallel and return outputs.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input BatchInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input BatchInput) (*mcp.CallToolResult, *BatchResult, error) {
 		report := progressReporterForRequest(ctx, req)
-		payload, err := runBatchTool(input, report)
+		payload, err := runBatchTool(ctx, input, report)
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, payload, nil
+		result, err := toolResult[BatchResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.run_async",
 		Description: "Run a single role/agent asynchronously and return run_id.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input RunInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input RunInput) (*mcp.CallToolResult, *RunResult, error) {
 		report := progressReporterForRequest(ctx, req)
 		if report != nil {
 			report("starting", 0, 1)
 		}
-		payload, err := runAsyncTool(input, report)
+		payload, err := runAsyncTool(ctx, input, report)
 		if err != nil {
 			return nil, nil, err
 		}
 		if report != nil {
 			report("started", 1, 1)
 		}
-		return nil, payload, nil
+		result, err := toolResult[RunResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.run_batch_async",
 		Description: "Run multiple roles/agents asynchronously and return run_ids.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input BatchInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input BatchInput) (*mcp.CallToolResult, *BatchResult, error) {
 		report := progressReporterForRequest(ctx, req)
-		payload, err := runBatchAsyncTool(input, report)
+		payload, err := runBatchAsyncTool(ctx, input, report)
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, payload, nil
+		result, err := toolResult[BatchResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.run_status",
 		Description: "Get status and output tail for an async run.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input StatusInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input StatusInput) (*mcp.CallToolResult, *RunResult, error) {
 		if input.RunID == "" {
 			return nil, nil, errors.New("Missing run_id")
 		}
@@ -115,13 +93,14 @@ func runMCP(args []string) int {
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, payload, nil
+		result, err := toolResult[RunResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.run_wait",
 		Description: "Block until an async run completes or timeout is reached.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input WaitInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input WaitInput) (*mcp.CallToolResult, *RunResult, error) {
 		if input.RunID == "" {
 			return nil, nil, errors.New("Missing run_id")
 		}
@@ -136,13 +115,14 @@ func runMCP(args []string) int {
 		if report != nil {
 			report("done", 1, 1)
 		}
-		return nil, payload, nil
+		result, err := toolResult[RunResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.run_cancel",
 		Description: "Cancel an async run.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input CancelInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input CancelInput) (*mcp.CallToolResult, *RunResult, error) {
 		if input.RunID == "" {
 			return nil, nil, errors.New("Missing run_id")
 		}
@@ -150,13 +130,14 @@ func runMCP(args []string) int {
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, payload, nil
+		result, err := toolResult[RunResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.run_history",
 		Description: "List recent run records. Default limit is 20 to prevent token explosion.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input HistoryInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input HistoryInput) (*mcp.CallToolResult, *HistoryResult, error) {
 		limit := input.Limit
 		if limit <= 0 {
 			limit = 20 // Default limit to prevent returning massive history
@@ -165,13 +146,13 @@ func runMCP(args []string) int {
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, map[string]interface{}{"count": len(records), "runs": records}, nil
+		return nil, &HistoryResult{Count: len(records), Runs: records}, nil
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.run_info",
 		Description: "Get a single run record by run_id.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input InfoInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input InfoInput) (*mcp.CallToolResult, *RunInfoResult, error) {
 		if input.RunID == "" {
 			return nil, nil, errors.New("Missing run_id")
 		}
@@ -179,60 +160,64 @@ func runMCP(args []string) int {
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, map[string]interface{}{"found": ok, "run": record}, nil
+		return nil, &RunInfoResult{Found: ok, Run: record}, nil
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.roles",
 		Description: "List available roles from the config.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input RolesInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input RolesInput) (*mcp.CallToolResult, *RolesResult, error) {
 		configPath := resolveConfigPath(input.Config)
 		cfg, err := loadConfig(configPath)
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, listRolesPayload(cfg, configPath), nil
+		result, err := toolResult[RolesResult](listRolesPayload(cfg, configPath))
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.status",
 		Description: "Check CLI availability and readiness for configured roles.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input RolesInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input RolesInput) (*mcp.CallToolResult, *RolesResult, error) {
 		configPath := resolveConfigPath(input.Config)
 		cfg, err := loadConfig(configPath)
 		if err != nil {
 			return nil, nil, err
 		}
 		payload, _ := statusPayload(cfg, configPath)
-		return nil, payload, nil
+		result, err := toolResult[RolesResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.queue_list",
 		Description: "List queued/running/completed runs.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input QueueListInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input QueueListInput) (*mcp.CallToolResult, *QueueResult, error) {
 		payload, err := queueListTool(input)
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, payload, nil
+		result, err := toolResult[QueueResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.approval_list",
 		Description: "List runs awaiting approval.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input QueueListInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input QueueListInput) (*mcp.CallToolResult, *QueueResult, error) {
 		payload, err := approvalListTool(input)
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, payload, nil
+		result, err := toolResult[QueueResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.approval_approve",
 		Description: "Approve a queued run.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input ApprovalInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input ApprovalInput) (*mcp.CallToolResult, *RunResult, error) {
 		if input.RunID == "" {
 			return nil, nil, errors.New("Missing run_id")
 		}
@@ -240,13 +225,14 @@ func runMCP(args []string) int {
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, payload, nil
+		result, err := toolResult[RunResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.approval_reject",
 		Description: "Reject a queued run.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input ApprovalInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input ApprovalInput) (*mcp.CallToolResult, *RunResult, error) {
 		if input.RunID == "" {
 			return nil, nil, errors.New("Missing run_id")
 		}
@@ -254,31 +240,21 @@ func runMCP(args []string) int {
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, payload, nil
+		result, err := toolResult[RunResult](payload)
+		return nil, result, err
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "conductor.runtime_status",
 		Description: "Get queue/approval runtime status.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input QueueListInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input QueueListInput) (*mcp.CallToolResult, *RuntimeStatusResult, error) {
 		payload, err := runtimeStatusTool()
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, payload, nil
+		result, err := toolResult[RuntimeStatusResult](payload)
+		return nil, result, err
 	})
-
-	transport := mcp.NewStdioTransport()
-	session, err := server.Connect(context.Background(), transport, nil)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		return 1
-	}
-	if err := session.Wait(); err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		return 1
-	}
-	return 0
 }
 
 func progressReporterForRequest(ctx context.Context, req *mcp.CallToolRequest) progressReporter {
@@ -321,6 +297,14 @@ func applyIdleTimeout(spec *CmdSpec, idleTimeoutMs int) {
 	spec.IdleTimeoutMs = idleTimeoutMs
 }
 
+// applyTimeout overrides the role's timeout_ms with a per-call one.
+func applyTimeout(spec *CmdSpec, timeoutMs int) {
+	if timeoutMs <= 0 {
+		return
+	}
+	spec.TimeoutMs = timeoutMs
+}
+
 func resolveSummaryOnly(input *bool, cfg Config) bool {
 	if input != nil {
 		return *input
@@ -343,8 +327,10 @@ func summarizePayload(payload map[string]interface{}) map[string]interface{} {
 		"started_at",
 		"ended_at",
 		"error",
+		"error_code",
 		"read_files",
 		"changed_files",
+		"hops",
 	}
 	out := map[string]interface{}{}
 	for _, key := range keep {
@@ -352,6 +338,9 @@ func summarizePayload(payload map[string]interface{}) map[string]interface{} {
 			out[key] = val
 		}
 	}
+	if runID, ok := payload["run_id"].(string); ok {
+		addRunLogLinks(out, runID)
+	}
 	return out
 }
 
@@ -376,8 +365,11 @@ func summarizeBatchPayload(payload map[string]interface{}) map[string]interface{
 	return out
 }
 
-func runBatchTool(input BatchInput, report progressReporter) (map[string]interface{}, error) {
-	payload, err := runBatch(input.Prompt, input.Roles, input.Config, input.Model, input.Reasoning, 0, input.IdleTimeoutMs, report)
+func runBatchTool(ctx context.Context, input BatchInput, report progressReporter) (map[string]interface{}, error) {
+	if err := checkRolesAllowed(input.Roles); err != nil {
+		return nil, err
+	}
+	payload, err := runBatch(ctx, input.Prompt, input.Roles, input.Config, input.Model, input.Reasoning, input.TimeoutMs, input.DeadlineMs, input.IdleTimeoutMs, report)
 	if err != nil {
 		return payload, err
 	}
@@ -388,60 +380,26 @@ func runBatchTool(input BatchInput, report progressReporter) (map[string]interfa
 	return payload, nil
 }
 
-func runBatchAsyncTool(input BatchInput, report progressReporter) (map[string]interface{}, error) {
+func runBatchAsyncTool(ctx context.Context, input BatchInput, report progressReporter) (map[string]interface{}, error) {
+	if err := checkRolesAllowed(input.Roles); err != nil {
+		return nil, err
+	}
 	if !input.NoRuntime {
-		return mcpRuntimeRunBatch(input)
+		return mcpRuntimeRunBatch(ctx, input)
 	}
-	return runBatchAsync(input.Prompt, input.Roles, input.Config, input.Model, input.Reasoning, 0, input.IdleTimeoutMs, report)
+	return runBatchAsync(input.Prompt, input.Roles, input.Config, input.Model, input.Reasoning, input.TimeoutMs, input.DeadlineMs, input.IdleTimeoutMs, report)
 }
 
-func runTool(input RunInput, report progressReporter) (map[string]interface{}, error) {
+func runAsyncTool(ctx context.Context, input RunInput, report progressReporter) (map[string]interface{}, error) {
 	if input.Prompt == "" {
 		return nil, errors.New("Missing prompt")
 	}
 	if input.Role == "" {
 		return nil, errors.New("Missing role")
 	}
-	configPath := resolveConfigPath(input.Config)
-
-	var cfg Config
-	var err error
-	cfg, err = loadConfig(configPath)
-	if err != nil {
-		return nil, err
-	}
-	if _, ok := cfg.Roles[input.Role]; !ok {
-		return unknownRolePayload(cfg, input.Role, configPath), nil
-	}
-	defaults := normalizeDefaults(cfg.Defaults)
-	logPrompt := defaults.LogPrompt
-
-	var spec CmdSpec
-	spec, err = buildSpecFromRole(cfg, input.Role, input.Prompt, input.Model, input.Reasoning, logPrompt)
-	if err != nil {
+	if err := checkRoleAllowed(input.Role); err != nil {
 		return nil, err
 	}
-	applyIdleTimeout(&spec, input.IdleTimeoutMs)
-	reportRunLabel(report, spec, "starting")
-	payload, err := runCommand(spec)
-	if err != nil {
-		reportRunLabel(report, spec, "failed")
-		return payload, err
-	}
-	reportRunLabel(report, spec, "completed")
-	if resolveSummaryOnly(input.SummaryOnly, cfg) {
-		return summarizePayload(payload), nil
-	}
-	return payload, nil
-}
-
-func runAsyncTool(input RunInput, report progressReporter) (map[string]interface{}, error) {
-	if input.Prompt == "" {
-		return nil, errors.New("Missing prompt")
-	}
-	if input.Role == "" {
-		return nil, errors.New("Missing role")
-	}
 	configPath := resolveConfigPath(input.Config)
 	cfg, err := loadConfig(configPath)
 	if err != nil {
@@ -453,17 +411,31 @@ func runAsyncTool(input RunInput, report progressReporter) (map[string]interface
 	defaults := normalizeDefaults(cfg.Defaults)
 	logPrompt := defaults.LogPrompt
 
-	var spec CmdSpec
-	spec, err = buildSpecFromRole(cfg, input.Role, input.Prompt, input.Model, input.Reasoning, logPrompt)
-	if err != nil {
-		return nil, err
+	// A role in models_mode "fallback" runs its chain in one async run;
+	// an explicit model pins the run to that model.
+	var chain []CmdSpec
+	if input.Model == "" {
+		chain, err = buildFallbackSpecs(cfg, input.Role, input.Prompt, input.Reasoning, logPrompt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(chain) == 0 {
+		spec, err := buildSpecFromRole(cfg, input.Role, input.Prompt, input.Model, input.Reasoning, logPrompt)
+		if err != nil {
+			return nil, err
+		}
+		chain = []CmdSpec{spec}
 	}
-	if input.IdleTimeoutMs > 0 {
-		spec.IdleTimeoutMs = input.IdleTimeoutMs
+	for i := range chain {
+		applyIdleTimeout(&chain[i], input.IdleTimeoutMs)
+		applyTimeout(&chain[i], input.TimeoutMs)
 	}
+	spec := chain[0]
+	spec.Fallback = chain[1:]
 	if !input.NoRuntime {
 		reportRunLabel(report, spec, "queued")
-		return mcpRuntimeRun(input, spec)
+		return mcpRuntimeRun(ctx, input, spec)
 	}
 
 	reportRunLabel(report, spec, "starting")
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// mcpAllowlist restricts which tools are registered and which roles the
+// role-routing tools may run. Empty lists allow everything. Tool entries are
+// names or path.Match patterns (e.g. "conductor.run*", "*-reply").
+type mcpAllowlist struct {
+	Tools []string `json:"tools"`
+	Roles []string `json:"roles"`
+}
+
+// mcpActiveAllowlist is applied by newMCPServer. Set by runMCPServer.
+var mcpActiveAllowlist mcpAllowlist
+
+// resolveMCPAllowlist builds the allowlist from config; the --tools flag
+// overrides mcp.tools.
+func resolveMCPAllowlist(toolsFlag string, cfg MCPConfig) (mcpAllowlist, error) {
+	allow := mcpAllowlist{Tools: splitList(toolsFlag), Roles: cfg.Roles}
+	if len(allow.Tools) == 0 {
+		allow.Tools = cfg.Tools
+	}
+	for _, pattern := range allow.Tools {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return mcpAllowlist{}, fmt.Errorf("invalid tool pattern %q: %v", pattern, err)
+		}
+	}
+	return allow, nil
+}
+
+func (a mcpAllowlist) allowsTool(name string) bool {
+	if len(a.Tools) == 0 {
+		return true
+	}
+	for _, pattern := range a.Tools {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (a mcpAllowlist) allowsRole(role string) bool {
+	if len(a.Roles) == 0 {
+		return true
+	}
+	for _, allowed := range a.Roles {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// checkToolAllowed rejects tools hidden by the allowlist. Handoff uses it so a
+// hidden raw CLI tool cannot be reached through another tool.
+func checkToolAllowed(name string) error {
+	if mcpActiveAllowlist.allowsTool(name) {
+		return nil
+	}
+	return fmt.Errorf("tool %q is not allowed on this MCP server (allowed tools: %s)", name, strings.Join(mcpActiveAllowlist.Tools, ", "))
+}
+
+// checkRoleAllowed rejects roles outside mcp.roles.
+func checkRoleAllowed(role string) error {
+	if mcpActiveAllowlist.allowsRole(role) {
+		return nil
+	}
+	return fmt.Errorf("role %q is not allowed on this MCP server (allowed roles: %s)", role, strings.Join(mcpActiveAllowlist.Roles, ", "))
+}
+
+// checkRolesAllowed applies checkRoleAllowed to a comma-separated role list.
+func checkRolesAllowed(roles string) error {
+	for _, role := range splitList(roles) {
+		if err := checkRoleAllowed(role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTool registers a tool unless the active allowlist hides it. Error codes
+// of failed calls are passed on to errorCodeMiddleware.
+func addTool[In, Out any](server *mcp.Server, tool *mcp.Tool, handler mcp.ToolHandlerFor[In, Out]) {
+	if !mcpActiveAllowlist.allowsTool(tool.Name) {
+		return
+	}
+	mcp.AddTool(server, tool, func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, Out, error) {
+		res, out, err := handler(ctx, req, in)
+		if err != nil {
+			noteToolErrorCode(ctx, err)
+		}
+		return res, out, err
+	})
+}
+
+// allowlistMiddleware turns calls to hidden tools into an explicit error
+// instead of the generic unknown-tool response.
+func allowlistMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if method == "tools/call" {
+			if call, ok := req.(*mcp.CallToolRequest); ok && call.Params != nil {
+				if err := checkToolAllowed(call.Params.Name); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// allowlistStatus reports the active allowlist for the status tool.
+func allowlistStatus() map[string]interface{} {
+	tools := mcpActiveAllowlist.Tools
+	if len(tools) == 0 {
+		tools = []string{"*"}
+	}
+	roles := mcpActiveAllowlist.Roles
+	if len(roles) == 0 {
+		roles = []string{"*"}
+	}
+	return map[string]interface{}{"tools": tools, "roles": roles}
+}
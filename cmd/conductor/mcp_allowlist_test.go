@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func withAllowlist(t *testing.T, allow mcpAllowlist) {
+	t.Helper()
+	prev := mcpActiveAllowlist
+	mcpActiveAllowlist = allow
+	t.Cleanup(func() { mcpActiveAllowlist = prev })
+}
+
+func TestResolveMCPAllowlist(t *testing.T) {
+	cfg := MCPConfig{Tools: []string{"conductor", "memory"}, Roles: []string{"oracle"}}
+	allow, err := resolveMCPAllowlist("", cfg)
+	if err != nil {
+		t.Fatalf("resolve config: %v", err)
+	}
+	if strings.Join(allow.Tools, ",") != "conductor,memory" || strings.Join(allow.Roles, ",") != "oracle" {
+		t.Fatalf("unexpected allowlist from config: %+v", allow)
+	}
+	allow, err = resolveMCPAllowlist("status, conductor.run*", cfg)
+	if err != nil {
+		t.Fatalf("resolve flag: %v", err)
+	}
+	if strings.Join(allow.Tools, ",") != "status,conductor.run*" {
+		t.Fatalf("expected --tools to override config, got %+v", allow.Tools)
+	}
+	if !allow.allowsTool("conductor.run_batch") || allow.allowsTool("codex") {
+		t.Fatalf("unexpected pattern matching for %+v", allow.Tools)
+	}
+	if _, err := resolveMCPAllowlist("conductor[", cfg); err == nil {
+		t.Fatal("expected invalid pattern to be rejected")
+	}
+	if !(mcpAllowlist{}).allowsTool("codex") || !(mcpAllowlist{}).allowsRole("any") {
+		t.Fatal("expected empty allowlist to allow everything")
+	}
+}
+
+func TestAllowlistHidesAndRejectsTools(t *testing.T) {
+	withAllowlist(t, mcpAllowlist{Tools: []string{"conductor", "memory", "status"}, Roles: []string{"oracle"}})
+	ctx := context.Background()
+	server := newMCPServer(map[string]bool{mcpGroupSession: true, mcpGroupRuntime: true})
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	defer serverSession.Close()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "0.0.0"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	defer session.Close()
+
+	res, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	var names []string
+	for _, tool := range res.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "conductor,memory,status" {
+		t.Fatalf("expected only allowlisted tools, got %v", names)
+	}
+
+	_, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "codex", Arguments: map[string]any{"prompt": "hi", "sandbox": "danger-full-access"}})
+	if err == nil || !strings.Contains(err.Error(), `tool "codex" is not allowed`) {
+		t.Fatalf("expected allowlist error for codex, got %v", err)
+	}
+
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "conductor", Arguments: map[string]any{"role": "builder", "prompt": "hi"}})
+	if err != nil {
+		t.Fatalf("call conductor: %v", err)
+	}
+	if !result.IsError || !strings.Contains(result.Content[0].(*mcp.TextContent).Text, `role "builder" is not allowed`) {
+		t.Fatalf("expected role rejection, got %+v", result.Content)
+	}
+
+	status, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "status", Arguments: map[string]any{}})
+	if err != nil {
+		t.Fatalf("call status: %v", err)
+	}
+	typed, err := toolResult[StatusResult](status.StructuredContent.(map[string]any))
+	if err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if strings.Join(typed.Allowlist.Tools, ",") != "conductor,memory,status" || strings.Join(typed.Allowlist.Roles, ",") != "oracle" {
+		t.Fatalf("expected status to report allowlist, got %+v", typed.Allowlist)
+	}
+}
+
+func TestAllowlistRejectsBatchRoles(t *testing.T) {
+	withAllowlist(t, mcpAllowlist{Roles: []string{"oracle"}})
+	if _, err := runBatchTool(context.Background(), BatchInput{Prompt: "hi", Roles: "oracle,builder"}, nil); err == nil || !strings.Contains(err.Error(), `role "builder"`) {
+		t.Fatalf("expected batch role rejection, got %v", err)
+	}
+	if _, err := runAsyncTool(context.Background(), RunInput{Prompt: "hi", Role: "builder"}, nil); err == nil {
+		t.Fatal("expected run role rejection")
+	}
+}
+
+func TestAllowlistRejectsHandoffToHiddenCLI(t *testing.T) {
+	resetMCPSessions(t)
+	withAllowlist(t, mcpAllowlist{Tools: []string{"handoff", "conductor"}})
+	mcpStoreSession(&MCPSession{ID: "t-1", CLI: "gemini", LastStatus: "ok", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	_, err := mcpRunHandoff(context.Background(), MCPHandoffInput{ThreadID: "t-1", CLI: "codex"})
+	if err == nil || !strings.Contains(err.Error(), `tool "codex" is not allowed`) {
+		t.Fatalf("expected handoff to hidden CLI to be rejected, got %v", err)
+	}
+}
+
+func TestAllowlistRejectsReplyToHiddenCLI(t *testing.T) {
+	resetMCPSessions(t)
+	withAllowlist(t, mcpAllowlist{Tools: []string{"conductor", "conductor-reply", "gemini-reply"}})
+	mcpStoreSession(&MCPSession{ID: "t-1", CLI: "codex", LastStatus: "ok", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+
+	_, err := mcpRunReply(context.Background(), "", MCPReplyInput{Prompt: "hi", ThreadID: "t-1"})
+	if err == nil || !strings.Contains(err.Error(), `tool "codex" is not allowed`) {
+		t.Fatalf("expected reply to a hidden CLI's session to be rejected, got %v", err)
+	}
+	_, err = mcpRunReply(context.Background(), "gemini", MCPReplyInput{Prompt: "hi", ThreadID: "t-1"})
+	if err == nil || !strings.Contains(err.Error(), "continue it with codex-reply") {
+		t.Fatalf("expected gemini-reply to refuse a codex thread, got %v", err)
+	}
+}
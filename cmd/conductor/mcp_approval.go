@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Prompt previews in approval requests are cut here.
+const approvalPromptPreviewChars = 400
+
+// approvalElicitParams builds the question shown to the human for item.
+func approvalElicitParams(item *RunItem) *mcp.ElicitParams {
+	spec := item.Spec
+	cwd := spec.Cwd
+	if cwd == "" {
+		cwd = "(conductor working directory)"
+	}
+	lines := []string{
+		fmt.Sprintf("Approve conductor run %s?", item.ID),
+		"role: " + firstNonEmpty(spec.Role, "(none)"),
+		"agent: " + spec.Agent,
+		"model: " + firstNonEmpty(spec.Model, "(default)"),
+		"cwd: " + cwd,
+		fmt.Sprintf("prompt (%d chars): %s", spec.PromptLen, spec.PromptPreview),
+	}
+	return &mcp.ElicitParams{
+		Message: strings.Join(lines, "\n"),
+		RequestedSchema: &jsonschema.Schema{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				"approve": {
+					Type:        "boolean",
+					Title:       "Approve",
+					Description: "Run now (true) or reject the run (false).",
+				},
+			},
+			Required: []string{"approve"},
+		},
+	}
+}
+
+// promptPreview returns the start of prompt on one line for approval requests.
+func promptPreview(prompt string) string {
+	return truncateRunes(strings.Join(strings.Fields(prompt), " "), approvalPromptPreviewChars)
+}
+
+// requestApproval asks the human to approve item through elicitation on the
+// session that queued it. An accepted answer approves or rejects the run; a
+// declined one rejects it. When that client does not support elicitation, or
+// the human dismisses the question, the run stays in awaiting_approval for
+// the approval tools.
+func (d *Runtime) requestApproval(item *RunItem) {
+	if caps := clientCapabilities(item.requester); caps == nil || caps.Elicitation == nil {
+		return
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout := d.cfg.Approval.TimeoutMs; timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(timeout)*time.Millisecond)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+	d.mu.Lock()
+	if item.Status != "awaiting_approval" {
+		d.mu.Unlock()
+		return
+	}
+	item.stopElicit = cancel
+	params := approvalElicitParams(item)
+	d.mu.Unlock()
+
+	res, err := item.requester.Elicit(ctx, params)
+	if err != nil || res == nil {
+		return
+	}
+	switch res.Action {
+	case "accept":
+		if approved, _ := res.Content["approve"].(bool); approved {
+			d.approve(item.ID)
+		} else {
+			d.reject(item.ID)
+		}
+	case "decline":
+		d.reject(item.ID)
+	}
+}
+
+// expireApprovals ends runs that waited longer than approval.timeout_ms.
+func (d *Runtime) expireApprovals() {
+	timeout := time.Duration(d.cfg.Approval.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		return
+	}
+	now := time.Now().UTC()
+	var records []RunRecord
+	var logPrompt []bool
+	d.mu.Lock()
+	kept := d.queue[:0]
+	for _, item := range d.queue {
+		if item.Status != "awaiting_approval" || now.Sub(item.CreatedAt) < timeout {
+			kept = append(kept, item)
+			continue
+		}
+		item.Status = "expired"
+		item.Error = fmt.Sprintf("approval timed out after %s", timeout)
+		item.EndedAt = now
+		item.settleApproval()
+		d.appendCompletedLocked(item)
+		records = append(records, runRecordForItem(item))
+		logPrompt = append(logPrompt, item.Spec.LogPrompt)
+	}
+	d.queue = kept
+	d.mu.Unlock()
+	for i, record := range records {
+		_ = appendRunRecord(record, logPrompt[i])
+	}
+	if len(records) > 0 {
+		notifyRuntimeChanged()
+	}
+}
+
+// settleApproval withdraws a pending elicitation once the item leaves
+// awaiting_approval. Callers hold the runtime lock.
+func (r *RunItem) settleApproval() {
+	if r.stopElicit != nil {
+		r.stopElicit()
+		r.stopElicit = nil
+	}
+}
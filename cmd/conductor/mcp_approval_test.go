@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestApprovalElicitationApproves(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeSessionRuntimeConfig(t, `"codex"`)
+	installFakeCLI(t, "codex", fakeCodexSession)
+	asked := make(chan string, 1)
+	serverSession, _ := connectTestClient(t, mcp.ClientOptions{
+		ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			asked <- req.Params.Message
+			return &mcp.ElicitResult{Action: "accept", Content: map[string]any{"approve": true}}, nil
+		},
+	})
+	ctx := context.WithValue(context.Background(), callerSessionKey{}, serverSession)
+
+	cwd := t.TempDir()
+	spec := CmdSpec{Agent: "codex", Role: "oracle", Model: "o3", Cmd: "codex", Args: []string{"exec"}, Cwd: cwd, PromptLen: 12, PromptPreview: "review the diff"}
+	_, output, err := mcpRuntimeRunSession(ctx, spec, mcpCodexAdapter)
+	if err != nil {
+		t.Fatalf("session run: %v", err)
+	}
+	if output == "" {
+		t.Fatal("expected output after elicited approval")
+	}
+	message := <-asked
+	for _, want := range []string{"role: oracle", "model: o3", "cwd: " + cwd, "prompt (12 chars): review the diff"} {
+		if !strings.Contains(message, want) {
+			t.Fatalf("expected %q in approval message, got %q", want, message)
+		}
+	}
+}
+
+func TestApprovalElicitationDeclineRejects(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeSessionRuntimeConfig(t, `"codex"`)
+	installFakeCLI(t, "codex", fakeCodexSession)
+	serverSession, _ := connectTestClient(t, mcp.ClientOptions{
+		ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			return &mcp.ElicitResult{Action: "decline"}, nil
+		},
+	})
+	ctx := context.WithValue(context.Background(), callerSessionKey{}, serverSession)
+
+	_, _, err := mcpRuntimeRunSession(ctx, CmdSpec{Agent: "codex", Cmd: "codex"}, mcpCodexAdapter)
+	if err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("expected declined approval to reject the run, got %v", err)
+	}
+}
+
+func TestApprovalWithoutElicitationExpires(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeTestConfig(t, `{
+  "runtime": { "approval": { "agents": ["codex"], "timeout_ms": 300 } },
+  "roles": {}
+}`)
+	installFakeCLI(t, "codex", fakeCodexSession)
+	serverSession, _ := connectTestClient(t, mcp.ClientOptions{})
+	ctx := context.WithValue(context.Background(), callerSessionKey{}, serverSession)
+
+	start := time.Now()
+	_, _, err := mcpRuntimeRunSession(ctx, CmdSpec{Agent: "codex", Cmd: "codex"}, mcpCodexAdapter)
+	if err == nil || !strings.Contains(err.Error(), "approval timed out after 300ms") {
+		t.Fatalf("expected approval to expire, got %v", err)
+	}
+	if time.Since(start) < 300*time.Millisecond {
+		t.Fatal("approval expired before timeout_ms")
+	}
+	runs := mcpRuntimeSnapshot().listRuns("expired", 0)
+	if len(runs) != 1 {
+		t.Fatalf("expected one expired run, got %v", runs)
+	}
+	record, ok, _ := findRunRecord(runs[0]["run_id"].(string))
+	if !ok || record.Status != "expired" {
+		t.Fatalf("expected expired run in history, got %+v", record)
+	}
+}
+
+func TestPromptPreview(t *testing.T) {
+	if got := promptPreview("fix\n\tthe   bug"); got != "fix the bug" {
+		t.Fatalf("unexpected preview %q", got)
+	}
+	long := promptPreview(strings.Repeat("x", approvalPromptPreviewChars+10))
+	if len(long) != approvalPromptPreviewChars+len("…") {
+		t.Fatalf("expected preview to be cut, got %d bytes", len(long))
+	}
+	if wide := promptPreview(strings.Repeat("日", approvalPromptPreviewChars)); !utf8.ValidString(wide) {
+		t.Fatalf("expected preview cut on a rune boundary, got %q", wide)
+	}
+}
+
+func TestApprovalElicitsOnQueuingSession(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeSessionRuntimeConfig(t, `"codex"`)
+	installFakeCLI(t, "codex", fakeCodexSession)
+	ctx := context.Background()
+	server := newMCPServer(map[string]bool{mcpGroupSession: true})
+	asked := make(chan string, 2)
+	var queuing *mcp.ServerSession
+	for _, name := range []string{"bystander", "requester"} {
+		serverTransport, clientTransport := mcp.NewInMemoryTransports()
+		serverSession, err := server.Connect(ctx, serverTransport, nil)
+		if err != nil {
+			t.Fatalf("server connect: %v", err)
+		}
+		t.Cleanup(func() { serverSession.Close() })
+		client := mcp.NewClient(&mcp.Implementation{Name: name, Version: "0.0.0"}, &mcp.ClientOptions{
+			ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+				asked <- name
+				return &mcp.ElicitResult{Action: "accept", Content: map[string]any{"approve": true}}, nil
+			},
+		})
+		session, err := client.Connect(ctx, clientTransport, nil)
+		if err != nil {
+			t.Fatalf("client connect: %v", err)
+		}
+		t.Cleanup(func() { session.Close() })
+		queuing = serverSession
+	}
+
+	runCtx := context.WithValue(ctx, callerSessionKey{}, queuing)
+	if _, _, err := mcpRuntimeRunSession(runCtx, CmdSpec{Agent: "codex", Cmd: "codex"}, mcpCodexAdapter); err != nil {
+		t.Fatalf("session run: %v", err)
+	}
+	if got := <-asked; got != "requester" || len(asked) != 0 {
+		t.Fatalf("expected only the queuing session to be asked, got %q (+%d)", got, len(asked))
+	}
+}
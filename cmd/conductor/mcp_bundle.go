@@ -45,13 +45,7 @@ func runMCPBundle(args []string) int {
 		*repoRoot = cwd
 	}
 	if *configPath == "" {
-		baseDir := getenv("CONDUCTOR_HOME", filepath.Join(os.Getenv("HOME"), ".conductor-kit"))
-		homePath := filepath.Join(baseDir, "mcp-bundles.json")
-		if pathExists(homePath) {
-			*configPath = homePath
-		} else {
-			*configPath = filepath.Join(*repoRoot, "config", "mcp-bundles.json")
-		}
+		*configPath = defaultBundleConfigPath(*repoRoot)
 	}
 
 	cfg, err := loadBundleConfig(*configPath)
@@ -100,6 +94,17 @@ Usage:
 `
 }
 
+// defaultBundleConfigPath prefers $CONDUCTOR_HOME/mcp-bundles.json and falls
+// back to config/mcp-bundles.json under repoRoot.
+func defaultBundleConfigPath(repoRoot string) string {
+	baseDir := getenv("CONDUCTOR_HOME", filepath.Join(os.Getenv("HOME"), ".conductor-kit"))
+	homePath := filepath.Join(baseDir, "mcp-bundles.json")
+	if pathExists(homePath) {
+		return homePath
+	}
+	return filepath.Join(repoRoot, "config", "mcp-bundles.json")
+}
+
 func loadBundleConfig(path string) (BundleConfig, error) {
 	var cfg BundleConfig
 	data, err := os.ReadFile(path)
@@ -3,9 +3,11 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
@@ -23,19 +25,77 @@ type CLIAdapter struct {
 
 // CLIRunOptions contains options for running a CLI command.
 type CLIRunOptions struct {
-	Args          []string
-	IdleTimeoutMs int
+	// RunID, when set, names the run directory the output is logged to and
+	// the process group record.
+	RunID             string
+	Args              []string
+	IdleTimeoutMs     int
+	TimeoutMs         int       // wall-clock limit per attempt
+	Deadline          time.Time // no attempt starts or runs past it
+	KillGraceMs       int       // SIGTERM to SIGKILL delay for the process group
+	Env               map[string]string
+	Cwd               string
+	Retry             int
+	RetryBackoffMs    int
+	RetryOn           []string
+	RetryMaxBackoffMs int
+	// Progress, when set, receives a message for each meaningful JSON event
+	// the CLI prints on stdout while it runs.
+	Progress progressReporter
 }
 
-// Run executes a CLI command with idle timeout support.
-// The idle timer resets whenever output is received.
+// cliRunOptionsForSpec maps a CmdSpec onto adapter options.
+func cliRunOptionsForSpec(spec CmdSpec) CLIRunOptions {
+	return CLIRunOptions{
+		Args:              spec.Args,
+		IdleTimeoutMs:     spec.IdleTimeoutMs,
+		TimeoutMs:         spec.TimeoutMs,
+		Deadline:          spec.Deadline,
+		KillGraceMs:       spec.KillGraceMs,
+		Env:               spec.Env,
+		Cwd:               spec.Cwd,
+		Retry:             spec.Retry,
+		RetryBackoffMs:    spec.RetryBackoffMs,
+		RetryOn:           spec.RetryOn,
+		RetryMaxBackoffMs: spec.RetryMaxBackoffMs,
+	}
+}
+
+// Run executes a CLI command with idle timeout support, retrying failed
+// attempts up to opts.Retry times. The idle timer resets whenever output is received.
 func (a *CLIAdapter) Run(ctx context.Context, opts CLIRunOptions) (string, error) {
 	if !isCommandAvailable(a.Cmd) {
-		return "", fmt.Errorf("%s CLI not found", a.Name)
+		return "", withErrorCode(errCodeMissingCLI, fmt.Errorf("%s CLI not found", a.Name))
 	}
 
-	// Setup cancellable context
-	ctx, cancel := context.WithCancel(ctx)
+	policy := opts.retryPolicy()
+	events := newCLIEventReporter(a.Cmd, opts.Progress)
+	var output string
+	var err error
+	for attempt := 1; attempt <= policy.attempts; attempt++ {
+		output, err = a.runOnce(ctx, opts, events)
+		if err == nil || ctx.Err() != nil {
+			return output, err
+		}
+		if attempt == policy.attempts || !policy.retries(errorCode(err)) {
+			break
+		}
+		delay := policy.delay(attempt, output)
+		if pastDeadline(opts.Deadline, delay) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(delay):
+		}
+	}
+	return "", err
+}
+
+func (a *CLIAdapter) runOnce(ctx context.Context, opts CLIRunOptions, events *cliEventReporter) (string, error) {
+	// Setup cancellable context, ended early by timeout_ms or the deadline
+	ctx, cancel := withAttemptDeadline(ctx, opts.TimeoutMs, opts.Deadline)
 	defer cancel()
 
 	// Setup idle timeout
@@ -51,72 +111,172 @@ func (a *CLIAdapter) Run(ctx context.Context, opts CLIRunOptions) (string, error
 	})
 	defer stopIdle()
 
+	grace := killGrace(opts.KillGraceMs)
 	cmd := exec.CommandContext(ctx, a.Cmd, opts.Args...)
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		return "", err
+	startInProcessGroup(cmd, grace)
+	if opts.Cwd != "" {
+		cmd.Dir = opts.Cwd
 	}
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		return "", err
+	if len(opts.Env) > 0 {
+		env := append([]string{}, os.Environ()...)
+		for k, v := range opts.Env {
+			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		}
+		cmd.Env = env
 	}
-
 	var output bytes.Buffer
-	outputWriter := &activityWriter{w: &output, activityCh: activityCh}
+	outputWriter := &activityWriter{w: &lockedWriter{w: &output}, activityCh: activityCh}
+	stdoutLog, stderrLog := openRunLogs(opts.RunID)
+	defer closeRunLogs(stdoutLog, stderrLog)
+	stdoutWriter := teeRunLog(outputWriter, stdoutLog)
+	stderrWriter := teeRunLog(outputWriter, stderrLog)
+	var stdoutLines *lineWriter
+	if events != nil {
+		stdoutLines = &lineWriter{fn: events.line}
+		stdoutWriter = io.MultiWriter(stdoutWriter, stdoutLines)
+	}
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
 
 	if err := cmd.Start(); err != nil {
 		return "", err
 	}
-
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() {
-		_, _ = io.Copy(outputWriter, stdoutPipe)
-		wg.Done()
-	}()
-	go func() {
-		_, _ = io.Copy(outputWriter, stderrPipe)
-		wg.Done()
-	}()
-
-	err = cmd.Wait()
-	wg.Wait()
+	releaseGroup := watchProcessGroup(cmd, opts.RunID, grace)
+	err := waitInProcessGroup(cmd)
+	releaseGroup()
+	if stdoutLines != nil {
+		stdoutLines.Flush()
+	}
 
 	if idleTimedOut.Load() {
-		return "", fmt.Errorf("%s CLI idle timed out (no output for %v)", a.Name, idleTimeout)
+		return "", withErrorCode(errCodeIdleTimeout, fmt.Errorf("%s CLI idle timed out (no output for %v)", a.Name, idleTimeout))
 	}
 	if ctx.Err() == context.DeadlineExceeded {
-		return "", fmt.Errorf("%s CLI timed out", a.Name)
+		return "", withErrorCode(errCodeDeadline, fmt.Errorf("%s CLI timed out (deadline exceeded)", a.Name))
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return "", withErrorCode(errCodeCanceled, fmt.Errorf("%s CLI canceled", a.Name))
 	}
 	if err != nil {
 		outputStr := strings.TrimSpace(output.String())
 		// Extract concise error - avoid dumping entire output to prevent token explosion
 		errMsg := extractConciseError(outputStr, err)
-		return "", fmt.Errorf("%s CLI failed: %s", a.Name, errMsg)
+		return outputStr, withErrorCode(runErrorCode("error", false, outputStr), fmt.Errorf("%s CLI failed: %s", a.Name, errMsg))
 	}
 	return strings.TrimSpace(output.String()), nil
 }
 
-// extractConciseError extracts a concise error message from CLI output.
-// Avoids including full output to prevent token explosion on retries.
-func extractConciseError(output string, err error) string {
+// lockedWriter serializes writes from the stdout and stderr copiers.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// failureClass classifies CLI output or an error message as a quota,
+// rate_limit or auth failure, or returns "". Fallback chains move to the next
+// model or role only on these.
+func failureClass(output string) string {
 	lowerOutput := strings.ToLower(output)
 
 	// Check for quota errors (Google/Gemini specific)
 	if strings.Contains(lowerOutput, "quota") || strings.Contains(lowerOutput, "quotaerror") ||
 		strings.Contains(lowerOutput, "resource_exhausted") || strings.Contains(lowerOutput, "resourceexhausted") {
-		return "quota exceeded - please wait or check your Google API quota"
+		return errCodeQuota
 	}
 
 	// Check for rate limit errors
 	if strings.Contains(lowerOutput, "rate limit") || strings.Contains(lowerOutput, "rate_limit") ||
-		strings.Contains(lowerOutput, "too many requests") || strings.Contains(lowerOutput, "429") {
-		return "rate limit exceeded - please wait before retrying"
+		strings.Contains(lowerOutput, "too many requests") || containsStatusCode(lowerOutput, "429") {
+		return errCodeRateLimit
 	}
 
 	// Check for auth errors
 	if strings.Contains(lowerOutput, "unauthorized") || strings.Contains(lowerOutput, "authentication") ||
-		strings.Contains(lowerOutput, "api key") || strings.Contains(lowerOutput, "401") {
+		strings.Contains(lowerOutput, "api key") || containsStatusCode(lowerOutput, "401") {
+		return errCodeAuth
+	}
+	return ""
+}
+
+// containsStatusCode reports whether code appears in s as a number of its
+// own, so run IDs and timestamps that contain the digits do not match.
+func containsStatusCode(s, code string) bool {
+	for i := strings.Index(s, code); i >= 0; {
+		end := i + len(code)
+		if (i == 0 || !isDigit(s[i-1])) && (end == len(s) || !isDigit(s[end])) {
+			return true
+		}
+		next := strings.Index(s[i+1:], code)
+		if next < 0 {
+			return false
+		}
+		i += next + 1
+	}
+	return false
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// checkJSONOutput reports a parse_error when args asked the CLI for JSON
+// (codex --json, --output-format json or stream-json) and it exited cleanly
+// without printing any, so raw text is not passed off as an answer.
+func (a *CLIAdapter) checkJSONOutput(args []string, output string) error {
+	output = strings.TrimSpace(output)
+	if output == "" || !requestsJSONOutput(args) || containsJSONOutput(output) {
+		return nil
+	}
+	return withErrorCode(errCodeParseError, fmt.Errorf("%s CLI printed malformed JSON output: %s", a.Name, extractConciseError(output, nil)))
+}
+
+func requestsJSONOutput(args []string) bool {
+	for i, arg := range args {
+		format, ok := "", false
+		switch {
+		case arg == "--json":
+			return true
+		case arg == "--output-format" && i+1 < len(args):
+			format, ok = args[i+1], true
+		case strings.HasPrefix(arg, "--output-format="):
+			format, ok = strings.TrimPrefix(arg, "--output-format="), true
+		}
+		if ok {
+			return format == "json" || format == "stream-json"
+		}
+	}
+	return false
+}
+
+// containsJSONOutput reports whether output holds a JSON event line or one
+// (possibly pretty-printed) JSON document. Other lines are stderr, which the
+// adapter merges into the output.
+func containsJSONOutput(output string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		var event map[string]interface{}
+		if json.Unmarshal([]byte(strings.TrimSpace(line)), &event) == nil {
+			return true
+		}
+	}
+	start, end := strings.Index(output, "{"), strings.LastIndex(output, "}")
+	return start >= 0 && end > start && json.Valid([]byte(output[start:end+1]))
+}
+
+// extractConciseError extracts a concise error message from CLI output.
+// Avoids including full output to prevent token explosion on retries.
+func extractConciseError(output string, err error) string {
+	switch failureClass(output) {
+	case errCodeQuota:
+		return "quota exceeded - please wait or check your Google API quota"
+	case errCodeRateLimit:
+		return "rate limit exceeded - please wait before retrying"
+	case errCodeAuth:
 		return "authentication failed - check API key"
 	}
 
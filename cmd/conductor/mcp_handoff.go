@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Only the most recent turns are carried into a handoff
+	handoffMaxTurns = 6
+	// Per-field cap so one long answer does not crowd out the rest
+	handoffMaxFieldChars = 2000
+	handoffDefaultPrompt = "Continue this conversation from where it left off."
+)
+
+// MCPHandoffInput for the handoff tool
+type MCPHandoffInput struct {
+	ThreadID      string `json:"threadId"`
+	CLI           string `json:"cli,omitempty"`
+	Role          string `json:"role,omitempty"`
+	Prompt        string `json:"prompt,omitempty"`
+	IdleTimeoutMs int    `json:"idle_timeout_ms,omitempty"`
+}
+
+// mcpRunHandoff continues a thread on another CLI or role. Native sessions
+// cannot move between CLIs, so a condensed context is sent as the first
+// prompt of a new native session and the two threads are linked.
+func mcpRunHandoff(ctx context.Context, input MCPHandoffInput) (*SessionResult, error) {
+	if input.ThreadID == "" {
+		return nil, fmt.Errorf("threadId is required")
+	}
+	if (input.CLI == "") == (input.Role == "") {
+		return nil, fmt.Errorf("exactly one of cli or role is required")
+	}
+	if input.CLI != "" {
+		if err := checkToolAllowed(input.CLI); err != nil {
+			return nil, err
+		}
+	}
+	source, ok := mcpLookupSession(input.ThreadID)
+	if !ok {
+		return nil, fmt.Errorf("thread not found: %s", input.ThreadID)
+	}
+
+	userPrompt := strings.TrimSpace(input.Prompt)
+	if userPrompt == "" {
+		userPrompt = handoffDefaultPrompt
+	}
+	if err := ValidatePrompt(userPrompt); err != nil {
+		return nil, err
+	}
+	condensed, contextSource := buildHandoffContext(*source)
+	prompt := userPrompt
+	if condensed != "" {
+		prompt = condensed + "\n\n" + userPrompt
+	}
+
+	var (
+		result *SessionResult
+		err    error
+	)
+	if input.Role != "" {
+		result, err = mcpRunRoleSession(ctx, MCPConductorInput{Prompt: prompt, Role: input.Role, IdleTimeoutMs: input.IdleTimeoutMs, handoffFrom: source})
+	} else {
+		sent := applySharedMemory(prompt)
+		config := handoffSessionConfig(*source, input.CLI)
+		if err := enforceSessionPolicy(input.CLI, "", &config); err != nil {
+			return nil, err
+		}
+		var args []string
+		switch input.CLI {
+		case "codex":
+			args, err = mcpBuildCodexArgs(MCPCodexInput{Prompt: sent, Sandbox: config.Sandbox, ApprovalPolicy: config.ApprovalPolicy, Cwd: config.Cwd})
+		case "claude":
+			args, err = mcpBuildClaudeArgs(MCPClaudeInput{Prompt: sent, PermissionMode: config.PermissionMode, Cwd: config.Cwd, AddDir: config.AddDir})
+		case "gemini":
+			args, err = mcpBuildGeminiArgs(MCPGeminiInput{Prompt: sent, Yolo: config.Yolo, ApprovalMode: config.ApprovalMode, Cwd: config.Cwd, IncludeDirectories: config.IncludeDirectories})
+		default:
+			return nil, fmt.Errorf("unknown CLI: %s", input.CLI)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result, err = mcpRunSessionWithConfig(ctx, input.CLI, "", "", prompt, sent, args, input.IdleTimeoutMs, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mcpLinkHandoff(source.ID, result.StructuredContent.ThreadID)
+	result.StructuredContent.HandoffFrom = source.ID
+	result.StructuredContent.HandoffContext = contextSource
+	return result, nil
+}
+
+// Permission tiers shared by the three CLIs, so a handoff can carry the
+// source thread's access over without granting the target more.
+const (
+	handoffTierReadOnly = iota
+	handoffTierEdit
+	handoffTierFull
+)
+
+var (
+	handoffCodexSandbox     = []string{"read-only", "workspace-write", "danger-full-access"}
+	handoffClaudePermission = []string{"default", "acceptEdits", "bypassPermissions"}
+	handoffGeminiApproval   = []string{"default", "auto_edit", "yolo"}
+)
+
+// handoffPermissionTier ranks what the source session was allowed to do.
+// Settings that were not recorded count as read-only.
+func handoffPermissionTier(sess MCPSession) int {
+	config := sess.Config
+	switch sess.CLI {
+	case "codex":
+		switch config.Sandbox {
+		case "workspace-write":
+			return handoffTierEdit
+		case "danger-full-access":
+			return handoffTierFull
+		}
+	case "claude":
+		switch config.PermissionMode {
+		case "acceptEdits":
+			return handoffTierEdit
+		case "bypassPermissions":
+			return handoffTierFull
+		}
+	case "gemini":
+		if config.Yolo || config.ApprovalMode == "yolo" {
+			return handoffTierFull
+		}
+		if config.ApprovalMode == "auto_edit" {
+			return handoffTierEdit
+		}
+	}
+	return handoffTierReadOnly
+}
+
+// handoffSessionConfig builds the target session's settings from the source
+// thread: the same working directory and extra directories, and permissions
+// no broader than the source had. A handoff to the same CLI keeps the
+// source's settings as they were. Codex has no extra-directory flag, so only
+// the working directory carries over to it.
+func handoffSessionConfig(source MCPSession, cli string) MCPSessionConfig {
+	var dirs []string
+	for _, dir := range policyDirectories(&source.Config) {
+		if dir.setting != "cwd" {
+			dirs = append(dirs, dir.path)
+		}
+	}
+	config := MCPSessionConfig{Cwd: source.Config.Cwd}
+	tier := handoffPermissionTier(source)
+	same := source.CLI == cli
+	switch cli {
+	case "codex":
+		config.Sandbox = handoffCodexSandbox[tier]
+		if same {
+			config.Sandbox, config.ApprovalPolicy = source.Config.Sandbox, source.Config.ApprovalPolicy
+		}
+	case "claude":
+		config.PermissionMode = handoffClaudePermission[tier]
+		if same && source.Config.PermissionMode != "" {
+			config.PermissionMode = source.Config.PermissionMode
+		}
+		config.AddDir = strings.Join(dirs, " ")
+	case "gemini":
+		if tier > handoffTierReadOnly {
+			config.ApprovalMode = handoffGeminiApproval[tier]
+		}
+		if same {
+			config.Yolo, config.ApprovalMode = source.Config.Yolo, source.Config.ApprovalMode
+		}
+		config.IncludeDirectories = strings.Join(dirs, ",")
+	}
+	return config
+}
+
+// buildHandoffContext condenses the source thread from its transcript, or
+// from the role's shared memory when no transcript was recorded.
+func buildHandoffContext(sess MCPSession) (string, string) {
+	label := sess.CLI
+	if sess.Role != "" {
+		label = fmt.Sprintf("%s (role %s)", sess.CLI, sess.Role)
+	}
+	header := fmt.Sprintf("[handoff:%s]\nThis conversation started in %s. Condensed context so far:", sess.ID, label)
+
+	if turns, err := readTranscript(sess.ID); err == nil && len(turns) > 0 {
+		if len(turns) > handoffMaxTurns {
+			turns = turns[len(turns)-handoffMaxTurns:]
+		}
+		var sb strings.Builder
+		sb.WriteString(header)
+		for _, turn := range turns {
+			sb.WriteString(fmt.Sprintf("\n\nTurn %d prompt:\n%s\n\nTurn %d response:\n%s",
+				turn.Turn, truncateHandoffField(turn.Prompt), turn.Turn, truncateHandoffField(turn.Response)))
+		}
+		sb.WriteString("\n[/handoff]")
+		return sb.String(), "transcript"
+	}
+
+	if entry, ok := sharedMemory.get(memoryRoleKey(memoryLabel(sess.CLI, sess.Role))); ok {
+		if value := strings.TrimSpace(entry.Value); value != "" {
+			return header + "\n\n" + truncateRunesTail(value, handoffMaxTurns*handoffMaxFieldChars) + "\n[/handoff]", "memory"
+		}
+	}
+	return "", "none"
+}
+
+func truncateHandoffField(value string) string {
+	return truncateRunes(strings.TrimSpace(value), handoffMaxFieldChars)
+}
+
+// mcpLinkHandoff records the handoff on both sessions and persists them.
+func mcpLinkHandoff(sourceID, targetID string) {
+	if targetID == "" {
+		return
+	}
+	mcpSessionStoreMu.Lock()
+	var snapshots []MCPSession
+	if source, ok := mcpSessionStore[sourceID]; ok {
+		if indexOf(source.HandoffTo, targetID) < 0 {
+			source.HandoffTo = append(source.HandoffTo, targetID)
+		}
+		snapshots = append(snapshots, *source)
+	}
+	if target, ok := mcpSessionStore[targetID]; ok {
+		target.HandoffFrom = sourceID
+		snapshots = append(snapshots, *target)
+	}
+	mcpSessionStoreMu.Unlock()
+	for _, sess := range snapshots {
+		_ = saveMCPSession(sess)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fakeClaudeHandoff = `printf '%s' "$2" > "$HANDOFF_PROMPT_FILE"
+echo '{"type":"system","session_id":"claude-1"}'
+`
+
+func TestHandoffCodexThreadToClaude(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeSessionRuntimeConfig(t, "")
+	resetMCPSessions(t)
+	configureMCPSessions(MCPConfig{Transcripts: true})
+	installFakeCLI(t, "codex", fakeCodexSession)
+	installFakeCLI(t, "claude", fakeClaudeHandoff)
+	promptFile := filepath.Join(t.TempDir(), "prompt.txt")
+	t.Setenv("HANDOFF_PROMPT_FILE", promptFile)
+
+	if _, err := mcpRunSessionWithConfig(context.Background(), "codex", "", "", "design the cache", "design the cache", []string{"exec", "--json", "design the cache"}, 0, MCPSessionConfig{}); err != nil {
+		t.Fatalf("start codex session: %v", err)
+	}
+
+	result, err := mcpRunHandoff(context.Background(), MCPHandoffInput{ThreadID: "native-1", CLI: "claude", Prompt: "implement it"})
+	if err != nil {
+		t.Fatalf("mcpRunHandoff: %v", err)
+	}
+	structured := result.StructuredContent
+	if structured.ThreadID != "claude-1" || structured.HandoffFrom != "native-1" || structured.HandoffContext != "transcript" {
+		t.Fatalf("unexpected handoff result: %v", structured)
+	}
+
+	data, err := os.ReadFile(promptFile)
+	if err != nil {
+		t.Fatalf("read prompt: %v", err)
+	}
+	sent := string(data)
+	for _, want := range []string{"[handoff:native-1]", "Turn 1 prompt:\ndesign the cache", "Turn 1 response:\ndone", "implement it"} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("expected handoff prompt to contain %q, got %q", want, sent)
+		}
+	}
+
+	source, _ := mcpLookupSession("native-1")
+	target, _ := mcpLookupSession("claude-1")
+	if source == nil || target == nil {
+		t.Fatal("expected both sessions stored")
+	}
+	if len(source.HandoffTo) != 1 || source.HandoffTo[0] != "claude-1" || target.HandoffFrom != "native-1" {
+		t.Errorf("expected linked threads, got to=%v from=%q", source.HandoffTo, target.HandoffFrom)
+	}
+	if target.CLI != "claude" || target.Config.PermissionMode != "default" {
+		t.Errorf("expected claude session no more permissive than the read-only source, got %+v", target)
+	}
+
+	// Links are persisted with the sessions.
+	reloaded, err := readMCPSession(mcpSessionPath("native-1"))
+	if err != nil || len(reloaded.HandoffTo) != 1 {
+		t.Errorf("expected persisted handoff link, got %+v (%v)", reloaded, err)
+	}
+}
+
+func TestHandoffSessionConfigInheritsSource(t *testing.T) {
+	dirs := MCPSessionConfig{Cwd: "/work", AddDir: "/work/lib /work/docs"}
+	cases := []struct {
+		name   string
+		source MCPSession
+		cli    string
+		want   MCPSessionConfig
+	}{
+		{"read-only codex to claude", MCPSession{CLI: "codex", Config: MCPSessionConfig{Sandbox: "read-only", Cwd: "/work"}}, "claude",
+			MCPSessionConfig{Cwd: "/work", PermissionMode: "default"}},
+		{"workspace codex to gemini", MCPSession{CLI: "codex", Config: MCPSessionConfig{Sandbox: "workspace-write", Cwd: "/work"}}, "gemini",
+			MCPSessionConfig{Cwd: "/work", ApprovalMode: "auto_edit"}},
+		{"claude dirs to gemini", MCPSession{CLI: "claude", Config: MCPSessionConfig{Cwd: dirs.Cwd, AddDir: dirs.AddDir, PermissionMode: "bypassPermissions"}}, "gemini",
+			MCPSessionConfig{Cwd: "/work", ApprovalMode: "yolo", IncludeDirectories: "/work/lib,/work/docs"}},
+		{"gemini dirs to claude", MCPSession{CLI: "gemini", Config: MCPSessionConfig{Cwd: "/work", IncludeDirectories: "/work/lib,/work/docs"}}, "claude",
+			MCPSessionConfig{Cwd: "/work", PermissionMode: "default", AddDir: "/work/lib /work/docs"}},
+		{"role session to codex", MCPSession{CLI: "claude", Role: "oracle", Config: MCPSessionConfig{Cwd: "/work"}}, "codex",
+			MCPSessionConfig{Cwd: "/work", Sandbox: "read-only"}},
+		{"same CLI keeps settings", MCPSession{CLI: "claude", Config: MCPSessionConfig{PermissionMode: "plan"}}, "claude",
+			MCPSessionConfig{PermissionMode: "plan"}},
+	}
+	for _, tc := range cases {
+		if got := handoffSessionConfig(tc.source, tc.cli); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %+v, got %+v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestHandoffValidatesInput(t *testing.T) {
+	resetMCPSessions(t)
+	cases := []MCPHandoffInput{
+		{CLI: "claude"},
+		{ThreadID: "x"},
+		{ThreadID: "x", CLI: "claude", Role: "oracle"},
+		{ThreadID: "missing", CLI: "claude"},
+	}
+	for _, input := range cases {
+		if _, err := mcpRunHandoff(context.Background(), input); err == nil {
+			t.Errorf("expected error for %+v", input)
+		}
+	}
+}
+
+func TestBuildHandoffContextFallsBackToMemory(t *testing.T) {
+	resetMCPSessions(t)
+	sess := MCPSession{ID: "t-mem", CLI: "codex", Role: "handoff-test-role"}
+	if text, source := buildHandoffContext(sess); text != "" || source != "none" {
+		t.Fatalf("expected empty context without transcript or memory, got %q (%s)", text, source)
+	}
+	key := memoryRoleKey(memoryLabel(sess.CLI, sess.Role))
+	if _, err := sharedMemory.set(key, "prior design notes"); err != nil {
+		t.Fatalf("set memory: %v", err)
+	}
+	defer sharedMemory.clear(key)
+	text, source := buildHandoffContext(sess)
+	if source != "memory" || !strings.Contains(text, "prior design notes") || !strings.Contains(text, "role handoff-test-role") {
+		t.Errorf("expected memory-based context, got %q (%s)", text, source)
+	}
+}
+
+func TestHandoffReadOnlyThreadToRole(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	resetMCPSessions(t)
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, `{"roles": {"builder": {"cli": "claude"}, "reviewer": {"cli": "codex"}}}`)
+	installFakeCLI(t, "claude", fakeClaudeHandoff)
+	installFakeCLI(t, "codex", `echo '{"type":"thread.started","thread_id":"review-1"}'
+echo "{\"type\":\"item.completed\",\"item\":{\"type\":\"agent_message\",\"text\":\"pwd=$(pwd)\"}}"
+`)
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("eval symlinks: %v", err)
+	}
+	mcpStoreSession(&MCPSession{ID: "t-ro", CLI: "codex", Config: MCPSessionConfig{Sandbox: "read-only", Cwd: dir}, LastStatus: "ok", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+
+	// The claude role template runs with bypassPermissions.
+	if _, err := mcpRunHandoff(context.Background(), MCPHandoffInput{ThreadID: "t-ro", Role: "builder"}); err == nil || !strings.Contains(err.Error(), "broader permissions") {
+		t.Fatalf("expected handoff to a more permissive role to be refused, got %v", err)
+	}
+
+	result, err := mcpRunHandoff(context.Background(), MCPHandoffInput{ThreadID: "t-ro", Role: "reviewer"})
+	if err != nil {
+		t.Fatalf("mcpRunHandoff: %v", err)
+	}
+	if text := result.Content[0].Text; text != "pwd="+dir {
+		t.Errorf("expected the role to run in the source's cwd, got %q", text)
+	}
+	if target, _ := mcpLookupSession("review-1"); target == nil || target.Config.Cwd != dir || target.HandoffFrom != "t-ro" {
+		t.Errorf("expected a linked role session in the source's cwd, got %+v", target)
+	}
+}
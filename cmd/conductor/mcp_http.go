@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Endpoints served by `conductor mcp --listen/--socket`.
+const (
+	mcpHTTPPath = "/mcp" // streamable HTTP
+	mcpSSEPath  = "/sse" // legacy HTTP+SSE for older hosts
+)
+
+// mcpListenOptions selects the network transport; empty Addr and Socket mean stdio.
+type mcpListenOptions struct {
+	Addr   string
+	Socket string
+	Token  string
+}
+
+// resolveMCPListen reads the transport from the flags only, so a copied
+// conductor.json can never turn the stdio server a host launches into a
+// network listener. CONDUCTOR_MCP_TOKEN overrides mcp.token so the secret can
+// stay out of conductor.json.
+func resolveMCPListen(addrFlag, socketFlag string, cfg MCPConfig) (mcpListenOptions, error) {
+	opts := mcpListenOptions{Addr: addrFlag, Socket: socketFlag}
+	if opts.Addr != "" && opts.Socket != "" {
+		return opts, fmt.Errorf("use either --listen or --socket, not both")
+	}
+	opts.Token = getenv("CONDUCTOR_MCP_TOKEN", cfg.Token)
+	if opts.Addr != "" && opts.Token == "" && !isLoopbackAddr(opts.Addr) {
+		return opts, fmt.Errorf("mcp.token (or CONDUCTOR_MCP_TOKEN) is required to listen on %s", opts.Addr)
+	}
+	return opts, nil
+}
+
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && isLoopbackHost(host)
+}
+
+// isLoopbackHost reports whether a host, with or without a port, names this
+// machine.
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// mcpHTTPHandler serves one shared server to every HTTP client, so all hosts
+// see the same runtime queue, session store and memory. On a loopback
+// address requests must also name a loopback Host and Origin.
+func mcpHTTPHandler(server *mcp.Server, token string, loopback bool) http.Handler {
+	getServer := func(*http.Request) *mcp.Server { return server }
+	mux := http.NewServeMux()
+	mux.Handle(mcpHTTPPath, mcp.NewStreamableHTTPHandler(getServer, nil))
+	mux.Handle(mcpSSEPath, mcp.NewSSEHandler(getServer))
+	handler := requireBearerToken(token, mux)
+	if loopback {
+		handler = requireLoopbackHost(handler)
+	}
+	return handler
+}
+
+// requireLoopbackHost rejects requests whose Host or Origin header names
+// anything but this machine. A web page can reach a loopback port through a
+// DNS-rebound name, but the browser then sends that name, not localhost.
+func requireLoopbackHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || !isLoopbackHost(u.Host) {
+				http.Error(w, "forbidden origin", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireBearerToken rejects requests without "Authorization: Bearer <token>".
+// An empty token disables the check.
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="conductor"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func mcpListen(opts mcpListenOptions) (net.Listener, error) {
+	if opts.Socket == "" {
+		return net.Listen("tcp", opts.Addr)
+	}
+	// Remove a stale socket left by a crashed server, but never a live one.
+	if conn, err := net.DialTimeout("unix", opts.Socket, 200*time.Millisecond); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("socket already in use: %s", opts.Socket)
+	}
+	_ = os.Remove(opts.Socket)
+	listener, err := net.Listen("unix", opts.Socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(opts.Socket, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// serveMCPHTTP runs the HTTP transport until ctx is done.
+func serveMCPHTTP(ctx context.Context, server *mcp.Server, opts mcpListenOptions) error {
+	listener, err := mcpListen(opts)
+	if err != nil {
+		return err
+	}
+	if opts.Socket != "" {
+		defer os.Remove(opts.Socket)
+	}
+	if opts.Token == "" {
+		fmt.Fprintln(os.Stderr, "Warning: no mcp.token configured; any local process can use this server.")
+	}
+	where := listener.Addr().String()
+	if opts.Socket == "" {
+		where = "http://" + where
+	}
+	fmt.Fprintf(os.Stderr, "conductor mcp listening on %s (streamable HTTP %s, SSE %s)\n", where, mcpHTTPPath, mcpSSEPath)
+
+	httpServer := &http.Server{
+		Handler:           mcpHTTPHandler(server, opts.Token, opts.Socket == "" && isLoopbackAddr(opts.Addr)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- httpServer.Serve(listener) }()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return httpServer.Shutdown(shutdownCtx)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestResolveMCPListen(t *testing.T) {
+	t.Setenv("CONDUCTOR_MCP_TOKEN", "")
+	opts, err := resolveMCPListen("", "", MCPConfig{})
+	if err != nil || opts.Addr != "" || opts.Socket != "" {
+		t.Fatalf("expected stdio by default, got %+v (%v)", opts, err)
+	}
+
+	opts, err = resolveMCPListen("127.0.0.1:7777", "", MCPConfig{Token: "cfg"})
+	if err != nil || opts.Addr != "127.0.0.1:7777" || opts.Token != "cfg" {
+		t.Fatalf("expected flag listen with config token, got %+v (%v)", opts, err)
+	}
+
+	opts, err = resolveMCPListen("", "/tmp/c.sock", MCPConfig{})
+	if err != nil || opts.Socket != "/tmp/c.sock" || opts.Addr != "" {
+		t.Fatalf("expected socket flag, got %+v (%v)", opts, err)
+	}
+
+	if _, err := resolveMCPListen("127.0.0.1:1", "/tmp/c.sock", MCPConfig{}); err == nil {
+		t.Error("expected error for both listen and socket")
+	}
+	if _, err := resolveMCPListen("0.0.0.0:7777", "", MCPConfig{}); err == nil {
+		t.Error("expected token to be required off loopback")
+	}
+
+	t.Setenv("CONDUCTOR_MCP_TOKEN", "env")
+	opts, err = resolveMCPListen("0.0.0.0:7777", "", MCPConfig{Token: "cfg"})
+	if err != nil || opts.Token != "env" {
+		t.Fatalf("expected env token override, got %+v (%v)", opts, err)
+	}
+}
+
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (b bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return b.base.RoundTrip(req)
+}
+
+func TestMCPHTTPHandlerRequiresToken(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	server := newMCPServer(map[string]bool{mcpGroupSession: true})
+	ts := httptest.NewServer(mcpHTTPHandler(server, "secret", true))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+mcpHTTPPath, "application/json", nil)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", resp.StatusCode)
+	}
+
+	ctx := context.Background()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "0.0.0"}, nil)
+	transport := mcp.NewStreamableClientTransport(ts.URL+mcpHTTPPath, &mcp.StreamableClientTransportOptions{
+		HTTPClient: &http.Client{Transport: bearerTransport{token: "secret", base: http.DefaultTransport}},
+		MaxRetries: -1,
+	})
+	session, err := client.Connect(ctx, transport, nil)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer session.Close()
+	res, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	found := false
+	for _, tool := range res.Tools {
+		if tool.Name == "sessions" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected session tools over HTTP, got %d tools", len(res.Tools))
+	}
+}
+
+func TestMCPHTTPHandlerRejectsForeignHost(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := requireLoopbackHost(ok)
+	cases := []struct {
+		host, origin string
+		want         int
+	}{
+		{"127.0.0.1:8765", "", http.StatusOK},
+		{"localhost:8765", "http://localhost:8765", http.StatusOK},
+		{"[::1]:8765", "http://[::1]:8765", http.StatusOK},
+		{"rebound.example:8765", "", http.StatusForbidden},
+		{"127.0.0.1:8765", "http://rebound.example", http.StatusForbidden},
+		{"127.0.0.1:8765", "null", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, mcpHTTPPath, nil)
+		req.Host = tc.host
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("host %q origin %q: expected %d, got %d", tc.host, tc.origin, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestMCPListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conductor.sock")
+	listener, err := mcpListen(mcpListenOptions{Socket: path})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if _, err := mcpListen(mcpListenOptions{Socket: path}); err == nil {
+		t.Error("expected live socket to be refused")
+	}
+	listener.Close()
+
+	// A stale socket file is replaced.
+	if l, err := net.Listen("unix", path); err == nil {
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		l.Close()
+	}
+	listener, err = mcpListen(mcpListenOptions{Socket: path})
+	if err != nil {
+		t.Fatalf("expected stale socket to be replaced: %v", err)
+	}
+	listener.Close()
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	policyModeClamp  = "clamp"
+	policyModeReject = "reject"
+)
+
+// Permission levels from most to least restrictive. Values missing from a
+// ladder rank above it, so unknown modes are treated as the most permissive.
+var (
+	codexSandboxLevels       = []string{"read-only", "workspace-write", "danger-full-access"}
+	codexApprovalLevels      = []string{"untrusted", "on-request", "on-failure", "never"}
+	claudePermissionLevels   = []string{"plan", "default", "dontAsk", "acceptEdits", "bypassPermissions"}
+	geminiApprovalModeLevels = []string{"default", "auto_edit", "yolo"}
+)
+
+// geminiApprovalModeNoYolo is the most permissive approval mode when a
+// policy disables yolo.
+const geminiApprovalModeNoYolo = "auto_edit"
+
+// policyLog receives one line per policy decision (stderr, i.e. the MCP
+// host's server log).
+var policyLog io.Writer = os.Stderr
+
+// mcpPolicy caps what MCP callers may request. Set by runMCPServer.
+var mcpPolicy PolicyConfig
+
+// resolveMCPPolicy validates the policy section.
+func resolveMCPPolicy(cfg PolicyConfig) (PolicyConfig, error) {
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = policyModeClamp
+	case policyModeClamp, policyModeReject:
+	default:
+		return PolicyConfig{}, fmt.Errorf("invalid policy.mode %q (use clamp or reject)", cfg.Mode)
+	}
+	check := func(scope string, limits PolicyLimits) error {
+		if limits.Sandbox != "" && policyRank(codexSandboxLevels, limits.Sandbox) >= len(codexSandboxLevels) {
+			return fmt.Errorf("invalid sandbox %q in policy %s", limits.Sandbox, scope)
+		}
+		if limits.ApprovalPolicy != "" && policyRank(codexApprovalLevels, limits.ApprovalPolicy) >= len(codexApprovalLevels) {
+			return fmt.Errorf("invalid approval_policy %q in policy %s", limits.ApprovalPolicy, scope)
+		}
+		if limits.PermissionMode != "" && policyRank(claudePermissionLevels, limits.PermissionMode) >= len(claudePermissionLevels) {
+			return fmt.Errorf("invalid permission_mode %q in policy %s", limits.PermissionMode, scope)
+		}
+		if limits.ApprovalMode != "" && policyRank(geminiApprovalModeLevels, limits.ApprovalMode) >= len(geminiApprovalModeLevels) {
+			return fmt.Errorf("invalid approval_mode %q in policy %s", limits.ApprovalMode, scope)
+		}
+		return nil
+	}
+	for cli, limits := range cfg.CLI {
+		if err := check("cli."+cli, limits); err != nil {
+			return PolicyConfig{}, err
+		}
+	}
+	for role, limits := range cfg.Roles {
+		if err := check("roles."+role, limits); err != nil {
+			return PolicyConfig{}, err
+		}
+	}
+	return cfg, nil
+}
+
+func policyRank(levels []string, value string) int {
+	for i, level := range levels {
+		if level == value {
+			return i
+		}
+	}
+	return len(levels)
+}
+
+// policyScope is one set of limits that applies to a session.
+type policyScope struct {
+	name   string
+	limits PolicyLimits
+}
+
+// policyScopes returns the CLI and role limits that apply; both must hold.
+func policyScopes(cli, role string) []policyScope {
+	var scopes []policyScope
+	if limits, ok := mcpPolicy.CLI[cli]; ok {
+		scopes = append(scopes, policyScope{"cli " + cli, limits})
+	}
+	if limits, ok := mcpPolicy.Roles[role]; ok && role != "" {
+		scopes = append(scopes, policyScope{"role " + role, limits})
+	}
+	return scopes
+}
+
+// enforceSessionPolicy clamps (or, in reject mode, refuses) the permission
+// settings in config against the CLI and role limits. A capped setting left
+// empty is set to the cap, so the CLI's own configuration cannot pick
+// something broader; a codex profile is refused under a cap for the same
+// reason. Directories outside the allowed cwd roots are always refused. Every
+// clamp and rejection is logged.
+func enforceSessionPolicy(cli, role string, config *MCPSessionConfig) error {
+	target := cli
+	if role != "" {
+		target = fmt.Sprintf("%s (role %s)", cli, role)
+	}
+	for _, scope := range policyScopes(cli, role) {
+		limits := scope.limits
+		clamp := func(setting string, levels []string, value *string, max string) error {
+			if max == "" || *value == "" || policyRank(levels, *value) <= policyRank(levels, max) {
+				return nil
+			}
+			if mcpPolicy.Mode == policyModeReject {
+				logPolicyDecision("rejected %s=%s for %s (%s allows at most %s)", setting, *value, target, scope.name, max)
+				return fmt.Errorf("%s %q exceeds the policy for %s (max %q)", setting, *value, scope.name, max)
+			}
+			logPolicyDecision("clamped %s=%s to %s for %s (%s)", setting, *value, max, target, scope.name)
+			*value = max
+			return nil
+		}
+		fill := func(value *string, max string) {
+			if *value == "" {
+				*value = max
+			}
+		}
+		switch cli {
+		case "codex":
+			if config.Profile != "" && (limits.Sandbox != "" || limits.ApprovalPolicy != "") {
+				logPolicyDecision("rejected profile=%s for %s (%s caps what a profile can set)", config.Profile, target, scope.name)
+				return fmt.Errorf("profile %q is not allowed while %s caps sandbox or approval policy", config.Profile, scope.name)
+			}
+			fill(&config.Sandbox, limits.Sandbox)
+			fill(&config.ApprovalPolicy, limits.ApprovalPolicy)
+			if err := clamp("sandbox", codexSandboxLevels, &config.Sandbox, limits.Sandbox); err != nil {
+				return err
+			}
+			if err := clamp("approval-policy", codexApprovalLevels, &config.ApprovalPolicy, limits.ApprovalPolicy); err != nil {
+				return err
+			}
+		case "claude":
+			fill(&config.PermissionMode, limits.PermissionMode)
+			if err := clamp("permission-mode", claudePermissionLevels, &config.PermissionMode, limits.PermissionMode); err != nil {
+				return err
+			}
+		case "gemini":
+			maxMode := limits.ApprovalMode
+			if limits.Yolo != nil && !*limits.Yolo && (maxMode == "" || policyRank(geminiApprovalModeLevels, maxMode) > policyRank(geminiApprovalModeLevels, geminiApprovalModeNoYolo)) {
+				maxMode = geminiApprovalModeNoYolo
+			}
+			if maxMode != "" && maxMode != "yolo" {
+				if config.Yolo {
+					if mcpPolicy.Mode == policyModeReject {
+						logPolicyDecision("rejected yolo for %s (%s)", target, scope.name)
+						return fmt.Errorf("yolo exceeds the policy for %s", scope.name)
+					}
+					logPolicyDecision("dropped yolo for %s (%s)", target, scope.name)
+					config.Yolo = false
+				}
+				if err := clamp("approval-mode", geminiApprovalModeLevels, &config.ApprovalMode, maxMode); err != nil {
+					return err
+				}
+			}
+		}
+		if len(limits.CwdRoots) > 0 {
+			for _, dir := range policyDirectories(config) {
+				if !withinRoots(dir.path, limits.CwdRoots) {
+					logPolicyDecision("rejected %s %s for %s (outside cwd_roots of %s)", dir.setting, dir.path, target, scope.name)
+					return fmt.Errorf("%s %q is outside the cwd_roots allowed for %s", dir.setting, dir.path, scope.name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type policyDirectory struct {
+	setting string
+	path    string
+}
+
+// policyDirectories lists the directories a session would run in or grant
+// access to.
+func policyDirectories(config *MCPSessionConfig) []policyDirectory {
+	var dirs []policyDirectory
+	if config.Cwd != "" {
+		dirs = append(dirs, policyDirectory{"cwd", config.Cwd})
+	}
+	for _, dir := range strings.Fields(strings.ReplaceAll(config.AddDir, ",", " ")) {
+		dirs = append(dirs, policyDirectory{"add-dir", dir})
+	}
+	for _, dir := range splitList(config.IncludeDirectories) {
+		dirs = append(dirs, policyDirectory{"include-directories", dir})
+	}
+	return dirs
+}
+
+// withinRoots reports whether dir resolves inside one of roots. Symlinks are
+// resolved when the paths exist so a link cannot escape a root.
+func withinRoots(dir string, roots []string) bool {
+	resolved := resolvePolicyPath(dir)
+	for _, root := range roots {
+		rel, err := filepath.Rel(resolvePolicyPath(root), resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func resolvePolicyPath(path string) string {
+	path = expandPath(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	return filepath.Clean(path)
+}
+
+func logPolicyDecision(format string, args ...interface{}) {
+	fmt.Fprintf(policyLog, "conductor policy: "+format+"\n", args...)
+}
+
+// policyArgFlags maps the permission flags of each CLI, long and short
+// spellings alike, to the setting they carry.
+var policyArgFlags = map[string]map[string]string{
+	"codex": {
+		"--sandbox": "sandbox", "-s": "sandbox",
+		"--approval-policy": "approval-policy", "--ask-for-approval": "approval-policy", "-a": "approval-policy",
+		"--config": "config", "-c": "config",
+		"--profile": "profile", "-p": "profile",
+	},
+	"claude": {"--permission-mode": "permission-mode"},
+	"gemini": {"--approval-mode": "approval-mode"},
+}
+
+// policyBypassFlags switch every permission check off. They cannot be
+// clamped, so they are refused whenever a cap applies to what they skip.
+var policyBypassFlags = map[string]map[string]MCPSessionConfig{
+	"codex": {
+		"--dangerously-bypass-approvals-and-sandbox": {Sandbox: "danger-full-access", ApprovalPolicy: "never"},
+		"--yolo": {Sandbox: "danger-full-access", ApprovalPolicy: "never"},
+	},
+	"claude": {"--dangerously-skip-permissions": {PermissionMode: "bypassPermissions"}},
+}
+
+// codexFullAuto is what codex's --full-auto shorthand stands for.
+var codexFullAuto = MCPSessionConfig{Sandbox: "workspace-write", ApprovalPolicy: "on-request"}
+
+// enforceArgsPolicy applies the policy to the permission flags of a role's
+// argv template and returns the rewritten argv. Each occurrence is clamped
+// on its own, in `--flag value`, `--flag=value` and short form, and codex
+// `-c` overrides of sandbox_mode/approval_policy are treated like the flags.
+// It is a no-op outside `conductor mcp`, where no policy is loaded.
+func enforceArgsPolicy(cli, role string, args []string, cwd string) ([]string, error) {
+	if err := enforceSessionPolicy(cli, role, &MCPSessionConfig{Cwd: cwd}); err != nil {
+		return nil, err
+	}
+	target := cli
+	if role != "" {
+		target = fmt.Sprintf("%s (role %s)", cli, role)
+	}
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if implied, ok := policyBypassFlags[cli][arg]; ok {
+			if scope, over := exceedsPolicy(cli, role, implied); over {
+				logPolicyDecision("rejected %s for %s (%s)", arg, target, scope)
+				return nil, fmt.Errorf("%s is not allowed by the policy for %s", arg, scope)
+			}
+			out = append(out, arg)
+			continue
+		}
+		switch {
+		case cli == "codex" && arg == "--full-auto":
+			config := codexFullAuto
+			if err := enforceSessionPolicy(cli, role, &config); err != nil {
+				return nil, err
+			}
+			if config.Sandbox == codexFullAuto.Sandbox && config.ApprovalPolicy == codexFullAuto.ApprovalPolicy {
+				out = append(out, arg)
+			} else {
+				out = append(out, "--sandbox", config.Sandbox, "--approval-policy", config.ApprovalPolicy)
+			}
+			continue
+		case cli == "gemini" && (arg == "--yolo" || arg == "-y"):
+			config := MCPSessionConfig{Yolo: true}
+			if err := enforceSessionPolicy(cli, role, &config); err != nil {
+				return nil, err
+			}
+			if config.Yolo {
+				out = append(out, arg)
+			}
+			continue
+		}
+
+		name, value, inline := strings.Cut(arg, "=")
+		setting, ok := policyArgFlags[cli][name]
+		if !ok || (!inline && i+1 >= len(args)) {
+			out = append(out, arg)
+			continue
+		}
+		if !inline {
+			value = args[i+1]
+			i++
+		}
+		var err error
+		if setting == "config" {
+			value, err = enforceCodexOverride(role, value)
+		} else {
+			value, err = enforceArgSetting(cli, role, setting, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if inline {
+			out = append(out, name+"="+value)
+		} else {
+			out = append(out, name, value)
+		}
+	}
+	return out, nil
+}
+
+// enforceArgSetting clamps one permission flag value.
+func enforceArgSetting(cli, role, setting, value string) (string, error) {
+	config := MCPSessionConfig{}
+	field := map[string]*string{
+		"sandbox":         &config.Sandbox,
+		"approval-policy": &config.ApprovalPolicy,
+		"permission-mode": &config.PermissionMode,
+		"approval-mode":   &config.ApprovalMode,
+		"profile":         &config.Profile,
+	}[setting]
+	*field = value
+	if err := enforceSessionPolicy(cli, role, &config); err != nil {
+		return "", err
+	}
+	return *field, nil
+}
+
+// enforceCodexOverride polices a codex `-c key=value` override that sets
+// sandbox_mode or approval_policy (including profile-scoped keys such as
+// profiles.fast.sandbox_mode) like the matching flag. Other overrides are
+// returned unchanged.
+func enforceCodexOverride(role, override string) (string, error) {
+	setting := codexOverrideSetting(override)
+	if setting == "" {
+		return override, nil
+	}
+	key, value, _ := strings.Cut(override, "=")
+	raw := strings.Trim(strings.TrimSpace(value), `"'`)
+	limited, err := enforceArgSetting("codex", role, setting, raw)
+	if err != nil || limited == raw {
+		return override, err
+	}
+	return key + "=" + limited, nil
+}
+
+// codexOverrideSetting returns the permission setting a codex `-c key=value`
+// override sets, or "" for other keys.
+func codexOverrideSetting(override string) string {
+	key, _, ok := strings.Cut(override, "=")
+	if !ok {
+		return ""
+	}
+	name := strings.Trim(strings.TrimSpace(key), `"'`)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = strings.Trim(name[i+1:], `"'`)
+	}
+	return map[string]string{"sandbox_mode": "sandbox", "approval_policy": "approval-policy"}[name]
+}
+
+// policyCapArgs makes the caps explicit in a role's (policed) argv template:
+// each capped codex sandbox or approval policy, or claude permission mode,
+// that the template leaves unset is added before {prompt}.
+func policyCapArgs(cli, role string, args []string) []string {
+	var capped MCPSessionConfig
+	if err := enforceSessionPolicy(cli, role, &capped); err != nil {
+		return args
+	}
+	set := map[string]bool{}
+	for i, arg := range args {
+		if implied, ok := policyBypassFlags[cli][arg]; ok {
+			set["sandbox"] = set["sandbox"] || implied.Sandbox != ""
+			set["approval-policy"] = set["approval-policy"] || implied.ApprovalPolicy != ""
+			set["permission-mode"] = set["permission-mode"] || implied.PermissionMode != ""
+			continue
+		}
+		if cli == "codex" && arg == "--full-auto" {
+			set["sandbox"], set["approval-policy"] = true, true
+			continue
+		}
+		name, value, inline := strings.Cut(arg, "=")
+		setting := policyArgFlags[cli][name]
+		if setting == "config" {
+			if !inline && i+1 < len(args) {
+				value = args[i+1]
+			}
+			setting = codexOverrideSetting(value)
+		}
+		if setting != "" {
+			set[setting] = true
+		}
+	}
+	var extra []string
+	for _, flag := range []struct{ setting, name, value string }{
+		{"sandbox", "--sandbox", capped.Sandbox},
+		{"approval-policy", "--approval-policy", capped.ApprovalPolicy},
+		{"permission-mode", "--permission-mode", capped.PermissionMode},
+	} {
+		if flag.value != "" && !set[flag.setting] {
+			extra = append(extra, flag.name, flag.value)
+		}
+	}
+	if len(extra) == 0 {
+		return args
+	}
+	at := indexOf(args, "{prompt}")
+	if at < 0 {
+		at = len(args)
+	}
+	return append(append(append([]string{}, args[:at]...), extra...), args[at:]...)
+}
+
+// exceedsPolicy reports the first scope whose caps config goes beyond,
+// without clamping or logging.
+func exceedsPolicy(cli, role string, config MCPSessionConfig) (string, bool) {
+	over := func(levels []string, value, max string) bool {
+		return max != "" && value != "" && policyRank(levels, value) > policyRank(levels, max)
+	}
+	for _, scope := range policyScopes(cli, role) {
+		limits := scope.limits
+		if over(codexSandboxLevels, config.Sandbox, limits.Sandbox) ||
+			over(codexApprovalLevels, config.ApprovalPolicy, limits.ApprovalPolicy) ||
+			over(claudePermissionLevels, config.PermissionMode, limits.PermissionMode) {
+			return scope.name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// mustArgs unwraps an argv builder result, failing the test on error.
+func mustArgs(t *testing.T) func([]string, error) []string {
+	t.Helper()
+	return func(args []string, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("build args: %v", err)
+		}
+		return args
+	}
+}
+
+// withPolicy installs policy for the test and captures decision log lines.
+func withPolicy(t *testing.T, policy PolicyConfig) *bytes.Buffer {
+	t.Helper()
+	resolved, err := resolveMCPPolicy(policy)
+	if err != nil {
+		t.Fatalf("resolve policy: %v", err)
+	}
+	prevPolicy, prevLog := mcpPolicy, policyLog
+	var log bytes.Buffer
+	mcpPolicy, policyLog = resolved, &log
+	t.Cleanup(func() { mcpPolicy, policyLog = prevPolicy, prevLog })
+	return &log
+}
+
+func TestResolveMCPPolicyValidates(t *testing.T) {
+	if _, err := resolveMCPPolicy(PolicyConfig{Mode: "deny"}); err == nil {
+		t.Fatal("expected invalid mode to be rejected")
+	}
+	if _, err := resolveMCPPolicy(PolicyConfig{CLI: map[string]PolicyLimits{"codex": {Sandbox: "full"}}}); err == nil {
+		t.Fatal("expected invalid sandbox to be rejected")
+	}
+	if _, err := resolveMCPPolicy(PolicyConfig{Roles: map[string]PolicyLimits{"scout": {ApprovalMode: "auto"}}}); err == nil {
+		t.Fatal("expected invalid approval_mode to be rejected")
+	}
+	policy, err := resolveMCPPolicy(PolicyConfig{})
+	if err != nil || policy.Mode != policyModeClamp {
+		t.Fatalf("expected clamp by default, got %+v (%v)", policy, err)
+	}
+}
+
+func TestPolicyClampsCodexAndLogs(t *testing.T) {
+	log := withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{"codex": {Sandbox: "workspace-write", ApprovalPolicy: "on-request"}}})
+	args := mustArgs(t)(mcpBuildCodexArgs(MCPCodexInput{Prompt: "hi", Sandbox: "danger-full-access", ApprovalPolicy: "never"}))
+	assertArgPair(t, args, "--sandbox", "workspace-write")
+	assertArgPair(t, args, "--approval-policy", "on-request")
+	if !strings.Contains(log.String(), "clamped sandbox=danger-full-access to workspace-write for codex (cli codex)") {
+		t.Fatalf("expected clamp to be logged, got %q", log.String())
+	}
+
+	log.Reset()
+	args = mustArgs(t)(mcpBuildCodexArgs(MCPCodexInput{Prompt: "hi", Sandbox: "read-only"}))
+	assertArgPair(t, args, "--sandbox", "read-only")
+	if log.Len() != 0 {
+		t.Fatalf("expected no decision for an allowed request, got %q", log.String())
+	}
+}
+
+func TestPolicyRejectMode(t *testing.T) {
+	no := false
+	log := withPolicy(t, PolicyConfig{Mode: policyModeReject, CLI: map[string]PolicyLimits{
+		"claude": {PermissionMode: "acceptEdits"},
+		"gemini": {Yolo: &no},
+	}})
+	// An unset claude permission mode defaults to bypassPermissions, which the
+	// policy refuses.
+	if _, err := mcpBuildClaudeArgs(MCPClaudeInput{Prompt: "hi"}); err == nil || !strings.Contains(err.Error(), "bypassPermissions") {
+		t.Fatalf("expected default bypassPermissions to be rejected, got %v", err)
+	}
+	assertArgPair(t, mustArgs(t)(mcpBuildClaudeArgs(MCPClaudeInput{Prompt: "hi", PermissionMode: "plan"})), "--permission-mode", "plan")
+	if _, err := mcpBuildGeminiArgs(MCPGeminiInput{Prompt: "hi", Yolo: true}); err == nil {
+		t.Fatal("expected gemini yolo to be rejected")
+	}
+	if !strings.Contains(log.String(), "rejected yolo for gemini") {
+		t.Fatalf("expected rejection to be logged, got %q", log.String())
+	}
+}
+
+func TestPolicyClampsResumeByRole(t *testing.T) {
+	withPolicy(t, PolicyConfig{Roles: map[string]PolicyLimits{"reviewer": {PermissionMode: "plan"}}})
+	config := MCPSessionConfig{PermissionMode: "bypassPermissions"}
+	args := mustArgs(t)(mcpBuildResumeArgsWithModel("claude", "reviewer", "s-1", "next", "", config))
+	assertArgPair(t, args, "--permission-mode", "plan")
+	args = mustArgs(t)(mcpBuildResumeArgsWithModel("claude", "", "s-1", "next", "", config))
+	assertArgPair(t, args, "--permission-mode", "bypassPermissions")
+}
+
+func TestPolicyStricterLimitWins(t *testing.T) {
+	withPolicy(t, PolicyConfig{
+		CLI:   map[string]PolicyLimits{"codex": {Sandbox: "workspace-write"}},
+		Roles: map[string]PolicyLimits{"oracle": {Sandbox: "read-only"}},
+	})
+	args, err := enforceArgsPolicy("codex", "oracle", []string{"exec", "--sandbox", "danger-full-access", "{prompt}"}, "")
+	if err != nil {
+		t.Fatalf("enforce args: %v", err)
+	}
+	if strings.Join(args, " ") != "exec --sandbox read-only {prompt}" {
+		t.Fatalf("unexpected args: %v", args)
+	}
+
+	no := false
+	withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{"gemini": {Yolo: &no}}})
+	args, err = enforceArgsPolicy("gemini", "", []string{"--yolo", "--approval-mode", "yolo", "{prompt}"}, "")
+	if err != nil {
+		t.Fatalf("enforce args: %v", err)
+	}
+	if strings.Join(args, " ") != "--approval-mode auto_edit {prompt}" {
+		t.Fatalf("unexpected gemini args: %v", args)
+	}
+}
+
+func TestPolicyAppliesToRoleSpecs(t *testing.T) {
+	withIsolatedMemoryStore(t, func() {})
+	withPolicy(t, PolicyConfig{Roles: map[string]PolicyLimits{"oracle": {PermissionMode: "acceptEdits"}}})
+	cfg := Config{Roles: map[string]RoleConfig{"oracle": {CLI: "claude", Args: mcpRoleSessionArgs("claude")}}}
+	spec, err := buildSpecFromRole(cfg, "oracle", "--permission-mode", "", "", false)
+	if err != nil {
+		t.Fatalf("build spec: %v", err)
+	}
+	assertArgPair(t, spec.Args, "--permission-mode", "acceptEdits")
+	if spec.Args[1] != "--permission-mode" {
+		t.Fatalf("expected prompt text to be left alone, got %v", spec.Args)
+	}
+}
+
+func TestPolicyCwdRoots(t *testing.T) {
+	root := t.TempDir()
+	inside := filepath.Join(root, "project")
+	if err := os.MkdirAll(inside, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	link := filepath.Join(root, "escape")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	log := withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{"codex": {CwdRoots: []string{root}}}})
+
+	if _, err := mcpBuildCodexArgs(MCPCodexInput{Prompt: "hi", Cwd: inside}); err != nil {
+		t.Fatalf("expected cwd inside root to be allowed: %v", err)
+	}
+	for _, dir := range []string{outside, link, root + "/../"} {
+		if _, err := mcpBuildCodexArgs(MCPCodexInput{Prompt: "hi", Cwd: dir}); err == nil {
+			t.Fatalf("expected cwd %s to be rejected", dir)
+		}
+	}
+	if !strings.Contains(log.String(), "outside cwd_roots") {
+		t.Fatalf("expected cwd rejection to be logged, got %q", log.String())
+	}
+}
+
+func TestPolicyClampsCodexConfigOverrides(t *testing.T) {
+	log := withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{"codex": {Sandbox: "workspace-write", ApprovalPolicy: "on-request"}}})
+	input := MCPCodexInput{Prompt: "hi", Config: map[string]interface{}{"sandbox_mode": "danger-full-access", "approval_policy": "never", "model_verbosity": "low"}}
+	args := mustArgs(t)(mcpBuildCodexArgs(input))
+	assertArgPair(t, args, "-c", "approval_policy=on-request")
+	assertArgPair(t, args, "-c", "sandbox_mode=workspace-write")
+	assertArgPair(t, args, "-c", "model_verbosity=low")
+	if !strings.Contains(log.String(), "clamped sandbox=danger-full-access to workspace-write") {
+		t.Fatalf("expected override clamp to be logged, got %q", log.String())
+	}
+
+	// Replies replay the stored overrides through the same check.
+	config := MCPSessionConfig{ConfigOverrides: mcpCodexConfigOverrides(input)}
+	args = mustArgs(t)(mcpBuildResumeArgsWithModel("codex", "", "t-1", "next", "", config))
+	assertArgPair(t, args, "-c", "sandbox_mode=workspace-write")
+	assertArgPair(t, args, "-c", "approval_policy=on-request")
+
+	withPolicy(t, PolicyConfig{Mode: policyModeReject, CLI: map[string]PolicyLimits{"codex": {Sandbox: "read-only"}}})
+	if _, err := mcpBuildCodexArgs(MCPCodexInput{Prompt: "hi", Config: map[string]interface{}{"profiles.fast.sandbox_mode": `"workspace-write"`}}); err == nil {
+		t.Fatal("expected profile-scoped sandbox override to be rejected")
+	}
+}
+
+func TestPolicyNormalizesArgForms(t *testing.T) {
+	no := false
+	withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{
+		"codex":  {Sandbox: "read-only", ApprovalPolicy: "on-request"},
+		"claude": {PermissionMode: "acceptEdits"},
+		"gemini": {Yolo: &no},
+	}})
+	cases := []struct {
+		cli  string
+		args []string
+		want string
+	}{
+		{"codex", []string{"exec", "-s", "danger-full-access", "{prompt}"}, "exec -s read-only {prompt}"},
+		{"codex", []string{"exec", "--sandbox=danger-full-access", "{prompt}"}, "exec --sandbox=read-only {prompt}"},
+		{"codex", []string{"exec", "-a", "never", "{prompt}"}, "exec -a on-request {prompt}"},
+		{"codex", []string{"exec", "--ask-for-approval=never", "{prompt}"}, "exec --ask-for-approval=on-request {prompt}"},
+		{"codex", []string{"exec", "-c", "sandbox_mode=danger-full-access", "{prompt}"}, "exec -c sandbox_mode=read-only {prompt}"},
+		{"codex", []string{"exec", "--full-auto", "{prompt}"}, "exec --sandbox read-only --approval-policy on-request {prompt}"},
+		{"claude", []string{"-p", "{prompt}", "--permission-mode=bypassPermissions"}, "-p {prompt} --permission-mode=acceptEdits"},
+		{"gemini", []string{"-y", "{prompt}"}, "{prompt}"},
+		{"gemini", []string{"--approval-mode=yolo", "{prompt}"}, "--approval-mode=auto_edit {prompt}"},
+	}
+	for _, tc := range cases {
+		args, err := enforceArgsPolicy(tc.cli, "", tc.args, "")
+		if err != nil {
+			t.Fatalf("%s %v: %v", tc.cli, tc.args, err)
+		}
+		if got := strings.Join(args, " "); got != tc.want {
+			t.Errorf("%s %v: expected %q, got %q", tc.cli, tc.args, tc.want, got)
+		}
+	}
+}
+
+func TestPolicyRejectsBypassFlags(t *testing.T) {
+	log := withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{
+		"codex":  {Sandbox: "workspace-write"},
+		"claude": {PermissionMode: "acceptEdits"},
+	}})
+	for _, tc := range []struct {
+		cli  string
+		flag string
+	}{
+		{"codex", "--dangerously-bypass-approvals-and-sandbox"},
+		{"codex", "--yolo"},
+		{"claude", "--dangerously-skip-permissions"},
+	} {
+		if _, err := enforceArgsPolicy(tc.cli, "", []string{tc.flag, "{prompt}"}, ""); err == nil || !strings.Contains(err.Error(), tc.flag) {
+			t.Errorf("expected %s %s to be rejected, got %v", tc.cli, tc.flag, err)
+		}
+	}
+	if !strings.Contains(log.String(), "rejected --dangerously-skip-permissions for claude") {
+		t.Fatalf("expected bypass rejection to be logged, got %q", log.String())
+	}
+
+	// Without a cap the flags are left alone.
+	withPolicy(t, PolicyConfig{})
+	args := mustArgs(t)(enforceArgsPolicy("claude", "", []string{"--dangerously-skip-permissions", "{prompt}"}, ""))
+	if strings.Join(args, " ") != "--dangerously-skip-permissions {prompt}" {
+		t.Fatalf("unexpected uncapped args: %v", args)
+	}
+}
+
+func TestPolicyMakesCapsExplicit(t *testing.T) {
+	log := withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{
+		"codex":  {Sandbox: "read-only", ApprovalPolicy: "on-request"},
+		"claude": {PermissionMode: "acceptEdits"},
+	}})
+	args := mustArgs(t)(mcpBuildCodexArgs(MCPCodexInput{Prompt: "hi"}))
+	assertArgPair(t, args, "--sandbox", "read-only")
+	assertArgPair(t, args, "--approval-policy", "on-request")
+	args = mustArgs(t)(mcpBuildResumeArgs("claude", "s-1", "next", MCPSessionConfig{}))
+	assertArgPair(t, args, "--permission-mode", "acceptEdits")
+
+	args = policyCapArgs("codex", "", []string{"exec", "-a", "untrusted", "{prompt}"})
+	if strings.Join(args, " ") != "exec -a untrusted --sandbox read-only {prompt}" {
+		t.Fatalf("expected the unset sandbox cap before the prompt, got %v", args)
+	}
+	args = policyCapArgs("codex", "", []string{"exec", "-c", "sandbox_mode=read-only", "--full-auto", "{prompt}"})
+	if strings.Join(args, " ") != "exec -c sandbox_mode=read-only --full-auto {prompt}" {
+		t.Fatalf("expected settings the template sets to be left alone, got %v", args)
+	}
+	if log.Len() != 0 {
+		t.Fatalf("expected filling a cap to be silent, got %q", log.String())
+	}
+}
+
+func TestPolicyRefusesCodexProfileUnderCap(t *testing.T) {
+	log := withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{"codex": {Sandbox: "workspace-write"}}})
+	if _, err := mcpBuildCodexArgs(MCPCodexInput{Prompt: "hi", Profile: "yolo"}); err == nil || !strings.Contains(err.Error(), `profile "yolo"`) {
+		t.Fatalf("expected the profile to be refused, got %v", err)
+	}
+	if _, err := enforceArgsPolicy("codex", "", []string{"exec", "--profile=yolo", "{prompt}"}, ""); err == nil {
+		t.Fatal("expected a role --profile to be refused")
+	}
+	if !strings.Contains(log.String(), "rejected profile=yolo for codex") {
+		t.Fatalf("expected the rejection to be logged, got %q", log.String())
+	}
+
+	withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{"claude": {PermissionMode: "plan"}}})
+	assertArgPair(t, mustArgs(t)(mcpBuildCodexArgs(MCPCodexInput{Prompt: "hi", Profile: "fast"})), "-p", "fast")
+}
+
+func TestPolicyCapsGeminiApprovalMode(t *testing.T) {
+	withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{"gemini": {ApprovalMode: "default"}}})
+	args := mustArgs(t)(mcpBuildGeminiArgs(MCPGeminiInput{Prompt: "hi", Yolo: true, ApprovalMode: "auto_edit"}))
+	if indexOf(args, "--yolo") >= 0 {
+		t.Errorf("expected --yolo to be dropped under an approval_mode cap, got %v", args)
+	}
+	assertArgPair(t, args, "--approval-mode", "default")
+}
+
+func TestSessionToolsStorePolicedSettings(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	resetMCPSessions(t)
+	withIsolatedMemoryStore(t, func() {})
+	writeSessionRuntimeConfig(t, "")
+	installFakeCLI(t, "codex", fakeCodexSession)
+	withPolicy(t, PolicyConfig{CLI: map[string]PolicyLimits{"codex": {Sandbox: "workspace-write", ApprovalPolicy: "on-request"}}})
+	_, session := connectTestClient(t, mcp.ClientOptions{})
+
+	res, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "codex", Arguments: map[string]any{"prompt": "hi", "sandbox": "danger-full-access"}})
+	if err != nil || res.IsError {
+		t.Fatalf("call codex: %v %+v", err, res)
+	}
+	sess, ok := mcpLookupSession("native-1")
+	if !ok || sess.Config.Sandbox != "workspace-write" || sess.Config.ApprovalPolicy != "on-request" {
+		t.Fatalf("expected the clamped settings in the session, got %+v", sess)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// mcpPromptArgument is the single prompt argument every command accepts; its
+// description comes from the command's argument-hint frontmatter.
+const mcpPromptArgument = "arguments"
+
+// mcpCommandsDir is the directory whose *.md slash commands are served as MCP
+// prompts. Set by runMCPServer; empty disables prompt registration.
+var mcpCommandsDir string
+
+// commandPrompt is a parsed commands/*.md file.
+type commandPrompt struct {
+	Name         string
+	Description  string
+	ArgumentHint string
+	Body         string
+}
+
+// resolveMCPCommandsDir returns mcp.commands_dir when set, otherwise the
+// commands directory of the installed kit (the same source `conductor install`
+// copies from).
+func resolveMCPCommandsDir(cfg MCPConfig) string {
+	if cfg.CommandsDir != "" {
+		return expandPath(cfg.CommandsDir)
+	}
+	return filepath.Join(detectRepoRoot(), "commands")
+}
+
+// loadCommandPrompts parses every *.md file in dir, sorted by name. A missing
+// directory yields no prompts.
+func loadCommandPrompts(dir string) ([]commandPrompt, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var prompts []commandPrompt
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		prompt := parseCommandPrompt(string(data))
+		prompt.Name = strings.TrimSuffix(entry.Name(), ".md")
+		prompts = append(prompts, prompt)
+	}
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Name < prompts[j].Name })
+	return prompts, nil
+}
+
+// parseCommandPrompt splits a command file into its frontmatter fields and
+// body. Only the flat `key: value` form used by commands/*.md is understood.
+func parseCommandPrompt(content string) commandPrompt {
+	var prompt commandPrompt
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(content, "---\n") {
+		prompt.Body = strings.TrimSpace(content)
+		return prompt
+	}
+	rest := content[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		prompt.Body = strings.TrimSpace(content)
+		return prompt
+	}
+	scanner := bufio.NewScanner(strings.NewReader(rest[:end]))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = unquoteFrontmatter(strings.TrimSpace(value))
+		switch strings.TrimSpace(key) {
+		case "description":
+			prompt.Description = value
+		case "argument-hint":
+			prompt.ArgumentHint = value
+		}
+	}
+	body := rest[end+len("\n---"):]
+	if idx := strings.IndexByte(body, '\n'); idx >= 0 {
+		body = body[idx+1:]
+	} else {
+		body = ""
+	}
+	prompt.Body = strings.TrimSpace(body)
+	return prompt
+}
+
+func unquoteFrontmatter(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' && last == '"') || (first == '\'' && last == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// renderCommandPrompt fills the command body with the caller's arguments.
+// $ARGUMENTS placeholders are substituted; otherwise the arguments are
+// appended the way hosts append text typed after a slash command.
+func renderCommandPrompt(prompt commandPrompt, args string) string {
+	args = strings.TrimSpace(args)
+	if strings.Contains(prompt.Body, "$ARGUMENTS") {
+		return strings.ReplaceAll(prompt.Body, "$ARGUMENTS", args)
+	}
+	if args == "" {
+		return prompt.Body
+	}
+	return prompt.Body + "\n\n" + args
+}
+
+// registerCommandPrompts serves each command in dir as an MCP prompt so hosts
+// the installer does not know about still get the slash-command workflows.
+func registerCommandPrompts(server *mcp.Server, dir string) {
+	prompts, err := loadCommandPrompts(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Prompt load error:", err.Error())
+		return
+	}
+	for _, prompt := range prompts {
+		prompt := prompt
+		def := &mcp.Prompt{
+			Name:        prompt.Name,
+			Description: prompt.Description,
+		}
+		if prompt.ArgumentHint != "" {
+			def.Arguments = []*mcp.PromptArgument{{
+				Name:        mcpPromptArgument,
+				Description: prompt.ArgumentHint,
+			}}
+		}
+		server.AddPrompt(def, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			var args string
+			if req.Params != nil {
+				args = req.Params.Arguments[mcpPromptArgument]
+			}
+			return &mcp.GetPromptResult{
+				Description: prompt.Description,
+				Messages: []*mcp.PromptMessage{{
+					Role:    "user",
+					Content: &mcp.TextContent{Text: renderCommandPrompt(prompt, args)},
+				}},
+			}, nil
+		})
+	}
+}
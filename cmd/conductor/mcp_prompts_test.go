@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestParseCommandPrompt(t *testing.T) {
+	prompt := parseCommandPrompt("---\ndescription: \"Plan mode: read-only.\"\nargument-hint: 'task to plan'\n---\n\nStep one.\n")
+	if prompt.Description != "Plan mode: read-only." {
+		t.Fatalf("unexpected description: %q", prompt.Description)
+	}
+	if prompt.ArgumentHint != "task to plan" {
+		t.Fatalf("unexpected argument hint: %q", prompt.ArgumentHint)
+	}
+	if prompt.Body != "Step one." {
+		t.Fatalf("unexpected body: %q", prompt.Body)
+	}
+
+	plain := parseCommandPrompt("No frontmatter here.")
+	if plain.Body != "No frontmatter here." || plain.Description != "" {
+		t.Fatalf("unexpected plain prompt: %+v", plain)
+	}
+}
+
+func TestRenderCommandPrompt(t *testing.T) {
+	appended := renderCommandPrompt(commandPrompt{Body: "Review."}, " src/main.go ")
+	if appended != "Review.\n\nsrc/main.go" {
+		t.Fatalf("unexpected appended prompt: %q", appended)
+	}
+	substituted := renderCommandPrompt(commandPrompt{Body: "Fix $ARGUMENTS now."}, "the bug")
+	if substituted != "Fix the bug now." {
+		t.Fatalf("unexpected substituted prompt: %q", substituted)
+	}
+	if got := renderCommandPrompt(commandPrompt{Body: "Review."}, ""); got != "Review." {
+		t.Fatalf("unexpected empty-args prompt: %q", got)
+	}
+}
+
+func TestResolveMCPCommandsDir(t *testing.T) {
+	if got := resolveMCPCommandsDir(MCPConfig{CommandsDir: "/tmp/commands"}); got != "/tmp/commands" {
+		t.Fatalf("expected config override, got %q", got)
+	}
+}
+
+func TestMCPServerServesCommandPrompts(t *testing.T) {
+	dir := filepath.Join("..", "..", "commands")
+	if !pathExists(dir) {
+		t.Skip("commands directory not present")
+	}
+	prev := mcpCommandsDir
+	mcpCommandsDir = dir
+	defer func() { mcpCommandsDir = prev }()
+
+	ctx := context.Background()
+	server := newMCPServer(map[string]bool{mcpGroupSession: true})
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	defer serverSession.Close()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "0.0.0"}, nil)
+	clientSession, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	defer clientSession.Close()
+
+	if clientSession.InitializeResult().Capabilities.Prompts == nil {
+		t.Fatal("expected prompts capability to be advertised")
+	}
+	list, err := clientSession.ListPrompts(ctx, nil)
+	if err != nil {
+		t.Fatalf("list prompts: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(list.Prompts) != len(entries) {
+		t.Fatalf("expected %d prompts, got %d", len(entries), len(list.Prompts))
+	}
+	var plan *mcp.Prompt
+	for _, prompt := range list.Prompts {
+		if prompt.Name == "conductor-plan" {
+			plan = prompt
+		}
+	}
+	if plan == nil {
+		t.Fatal("expected conductor-plan prompt")
+	}
+	if len(plan.Arguments) != 1 || plan.Arguments[0].Name != mcpPromptArgument || plan.Arguments[0].Description != "task to plan" {
+		t.Fatalf("unexpected plan arguments: %+v", plan.Arguments)
+	}
+
+	res, err := clientSession.GetPrompt(ctx, &mcp.GetPromptParams{
+		Name:      "conductor-plan",
+		Arguments: map[string]string{mcpPromptArgument: "add retries"},
+	})
+	if err != nil {
+		t.Fatalf("get prompt: %v", err)
+	}
+	if len(res.Messages) != 1 {
+		t.Fatalf("expected one message, got %d", len(res.Messages))
+	}
+	text, ok := res.Messages[0].Content.(*mcp.TextContent)
+	if !ok {
+		t.Fatalf("unexpected content type %T", res.Messages[0].Content)
+	}
+	if !strings.Contains(text.Text, "Activate plan mode") || !strings.HasSuffix(text.Text, "add retries") {
+		t.Fatalf("unexpected prompt text: %q", text.Text)
+	}
+}
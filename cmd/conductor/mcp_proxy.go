@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Downstream bundle servers are restarted with exponential backoff; the
+// backoff resets once a server stayed up for proxyStableAfter.
+const (
+	proxyConnectTimeout    = 30 * time.Second
+	proxyRestartMinBackoff = time.Second
+	proxyRestartMaxBackoff = 30 * time.Second
+	proxyStableAfter       = time.Minute
+)
+
+// bundleProxy runs one downstream MCP server from mcp-bundles.json and
+// re-exports its tools on the conductor server as <name>.<tool>.
+type bundleProxy struct {
+	spec   BundleServer
+	server *mcp.Server
+
+	mu        sync.Mutex
+	session   *mcp.ClientSession
+	tools     []string
+	status    string // starting, running, restarting or stopped
+	restarts  int
+	lastErr   string
+	startedAt time.Time
+}
+
+var (
+	bundleProxiesMu sync.Mutex
+	bundleProxies   []*bundleProxy
+)
+
+// resolveMCPBundleServers lists the enabled servers of the bundles to proxy.
+// The --bundles flag overrides mcp.bundles. A server that is conductor itself
+// is skipped.
+func resolveMCPBundleServers(flagValue string, cfg MCPConfig) ([]BundleServer, error) {
+	names := splitList(flagValue)
+	if len(names) == 0 {
+		names = cfg.Bundles
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	path := cfg.BundlesConfig
+	if path == "" {
+		cwd, _ := os.Getwd()
+		path = defaultBundleConfigPath(cwd)
+	}
+	bundles, err := loadBundleConfig(expandPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("bundle config error: %w", err)
+	}
+	var servers []BundleServer
+	seen := map[string]bool{}
+	for _, name := range names {
+		bundle, ok := bundles.Bundles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown MCP bundle: %s", name)
+		}
+		for _, s := range bundle.Servers {
+			if !bundleEnabled(s) || isConductorBundleServer(s) {
+				continue
+			}
+			if strings.TrimSpace(s.Name) == "" || strings.ContainsAny(s.Name, ". ") {
+				return nil, fmt.Errorf("invalid bundle server name %q (no dots or spaces)", s.Name)
+			}
+			if seen[s.Name] {
+				return nil, fmt.Errorf("duplicate bundle server name: %s", s.Name)
+			}
+			seen[s.Name] = true
+			servers = append(servers, s)
+		}
+	}
+	return servers, nil
+}
+
+// isConductorBundleServer reports whether s would start `conductor mcp`
+// again, which would proxy itself.
+func isConductorBundleServer(s BundleServer) bool {
+	return strings.TrimSuffix(filepath.Base(s.Command), ".exe") == "conductor" && len(s.Args) > 0 && s.Args[0] == "mcp"
+}
+
+// startBundleProxies connects to every server and registers its tools before
+// returning, so the first tools/list already includes them. Servers that fail
+// to start are retried in the background. Call stopBundleProxies on exit.
+func startBundleProxies(ctx context.Context, server *mcp.Server, servers []BundleServer) {
+	var wg sync.WaitGroup
+	proxies := make([]*bundleProxy, 0, len(servers))
+	for _, spec := range servers {
+		p := &bundleProxy{spec: spec, server: server, status: "starting"}
+		proxies = append(proxies, p)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.connect(ctx); err != nil {
+				p.fail(err)
+			}
+		}()
+	}
+	wg.Wait()
+	bundleProxiesMu.Lock()
+	bundleProxies = proxies
+	bundleProxiesMu.Unlock()
+	for _, p := range proxies {
+		go p.supervise(ctx)
+	}
+}
+
+// stopBundleProxies closes every downstream session, which stops its process.
+// The context passed to startBundleProxies must be canceled first so the
+// supervisors do not restart them.
+func stopBundleProxies() {
+	bundleProxiesMu.Lock()
+	proxies := bundleProxies
+	bundleProxies = nil
+	bundleProxiesMu.Unlock()
+	for _, p := range proxies {
+		p.mu.Lock()
+		session := p.session
+		p.session = nil
+		p.status = "stopped"
+		p.mu.Unlock()
+		if session != nil {
+			_ = session.Close()
+		}
+	}
+}
+
+// connect starts the downstream process and exports its current tools.
+func (p *bundleProxy) connect(ctx context.Context) error {
+	cmd := exec.Command(p.spec.Command, p.spec.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range p.spec.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+	cmd.Stderr = os.Stderr
+	connectCtx, cancel := context.WithTimeout(ctx, proxyConnectTimeout)
+	defer cancel()
+	client := mcp.NewClient(&mcp.Implementation{Name: "conductor-proxy", Version: "1.0.0"}, nil)
+	session, err := client.Connect(connectCtx, &mcp.CommandTransport{Command: cmd}, nil)
+	if err != nil {
+		return err
+	}
+	var tools []*mcp.Tool
+	for tool, err := range session.Tools(connectCtx, nil) {
+		if err != nil {
+			_ = session.Close()
+			return err
+		}
+		tools = append(tools, tool)
+	}
+	p.export(session, tools)
+	return nil
+}
+
+// export registers the downstream tools under the proxy namespace and drops
+// tools the server no longer offers.
+func (p *bundleProxy) export(session *mcp.ClientSession, tools []*mcp.Tool) {
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		name := p.spec.Name + "." + tool.Name
+		if !mcpActiveAllowlist.allowsTool(name) {
+			continue
+		}
+		exported := *tool
+		exported.Name = name
+		exported.Description = strings.TrimSpace(fmt.Sprintf("[%s] %s", p.spec.Name, tool.Description))
+		if exported.InputSchema == nil {
+			exported.InputSchema = &jsonschema.Schema{Type: "object"}
+		}
+		if exported.InputSchema.Type != "object" {
+			fmt.Fprintf(os.Stderr, "conductor proxy: skipping %s (input schema is not an object)\n", name)
+			continue
+		}
+		if exported.OutputSchema != nil && exported.OutputSchema.Type != "object" {
+			exported.OutputSchema = nil
+		}
+		p.server.AddTool(&exported, p.forward(tool.Name))
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	p.mu.Lock()
+	if p.status == "stopped" {
+		p.mu.Unlock()
+		_ = session.Close()
+		return
+	}
+	var stale []string
+	for _, name := range p.tools {
+		if indexOf(names, name) < 0 {
+			stale = append(stale, name)
+		}
+	}
+	p.session = session
+	p.tools = names
+	p.status = "running"
+	p.lastErr = ""
+	p.startedAt = time.Now()
+	p.mu.Unlock()
+	if len(stale) > 0 {
+		p.server.RemoveTools(stale...)
+	}
+}
+
+// forward relays a call to the downstream tool. While the server restarts the
+// call fails with a tool error instead of hanging.
+func (p *bundleProxy) forward(tool string) mcp.ToolHandler {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		p.mu.Lock()
+		session, status := p.session, p.status
+		p.mu.Unlock()
+		if session == nil {
+			return &mcp.CallToolResult{
+				IsError: true,
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("MCP server %s is %s; retry shortly", p.spec.Name, status)}},
+			}, nil
+		}
+		return session.CallTool(ctx, &mcp.CallToolParams{Name: tool, Arguments: req.Params.Arguments})
+	}
+}
+
+func (p *bundleProxy) fail(err error) {
+	p.mu.Lock()
+	if p.status == "stopped" {
+		p.mu.Unlock()
+		return
+	}
+	p.session = nil
+	p.status = "restarting"
+	if err != nil {
+		p.lastErr = err.Error()
+	}
+	p.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "conductor proxy: %s: %v\n", p.spec.Name, err)
+}
+
+// supervise restarts the downstream server whenever its session ends, until
+// ctx is canceled.
+func (p *bundleProxy) supervise(ctx context.Context) {
+	backoff := proxyRestartMinBackoff
+	for {
+		p.mu.Lock()
+		session, startedAt := p.session, p.startedAt
+		p.mu.Unlock()
+		if session != nil {
+			err := session.Wait()
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				err = fmt.Errorf("server exited")
+			}
+			p.fail(err)
+			if time.Since(startedAt) >= proxyStableAfter {
+				backoff = proxyRestartMinBackoff
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > proxyRestartMaxBackoff {
+			backoff = proxyRestartMaxBackoff
+		}
+		p.mu.Lock()
+		stopped := p.status == "stopped"
+		p.restarts++
+		p.mu.Unlock()
+		if stopped {
+			return
+		}
+		if err := p.connect(ctx); err != nil {
+			p.fail(err)
+		}
+	}
+}
+
+// bundleProxyStatus reports each proxied server for the status tool.
+func bundleProxyStatus() []map[string]interface{} {
+	bundleProxiesMu.Lock()
+	proxies := append([]*bundleProxy{}, bundleProxies...)
+	bundleProxiesMu.Unlock()
+	out := make([]map[string]interface{}, 0, len(proxies))
+	for _, p := range proxies {
+		p.mu.Lock()
+		out = append(out, map[string]interface{}{
+			"name":     p.spec.Name,
+			"command":  p.spec.Command,
+			"status":   p.status,
+			"tools":    append([]string{}, p.tools...),
+			"restarts": p.restarts,
+			"error":    p.lastErr,
+		})
+		p.mu.Unlock()
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TestHelperDownstreamMCP is not a real test. Proxy tests start the test
+// binary with CONDUCTOR_TEST_DOWNSTREAM=1 to get a small stdio MCP server.
+func TestHelperDownstreamMCP(t *testing.T) {
+	if os.Getenv("CONDUCTOR_TEST_DOWNSTREAM") != "1" {
+		return
+	}
+	server := mcp.NewServer(&mcp.Implementation{Name: "downstream", Version: "0.0.0"}, nil)
+	type input struct {
+		Symbol string `json:"symbol"`
+	}
+	mcp.AddTool(server, &mcp.Tool{Name: "definition", Description: "Find a definition."}, func(ctx context.Context, req *mcp.CallToolRequest, in input) (*mcp.CallToolResult, any, error) {
+		if in.Symbol == "crash" {
+			os.Exit(3)
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "def " + in.Symbol}}}, nil, nil
+	})
+	_ = server.Run(context.Background(), mcp.NewStdioTransport())
+	os.Exit(0)
+}
+
+func downstreamBundleServer(name string) BundleServer {
+	return BundleServer{
+		Name:    name,
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^TestHelperDownstreamMCP$"},
+		Env:     map[string]string{"CONDUCTOR_TEST_DOWNSTREAM": "1"},
+	}
+}
+
+func TestResolveMCPBundleServers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp-bundles.json")
+	content := `{"bundles": {"extended": {"servers": [
+  {"name": "lsp", "command": "lsp-mcp"},
+  {"name": "search", "command": "search-mcp", "enabled": false},
+  {"name": "ast", "command": ""},
+  {"name": "conductor", "command": "/usr/local/bin/conductor", "args": ["mcp"]}
+]}}}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := MCPConfig{Bundles: []string{"extended"}, BundlesConfig: path}
+	servers, err := resolveMCPBundleServers("", cfg)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if len(servers) != 1 || servers[0].Name != "lsp" {
+		t.Fatalf("expected only the enabled non-conductor server, got %+v", servers)
+	}
+	if servers, _ := resolveMCPBundleServers("", MCPConfig{BundlesConfig: path}); servers != nil {
+		t.Fatalf("expected no proxying without bundles, got %+v", servers)
+	}
+	if _, err := resolveMCPBundleServers("core", cfg); err == nil || !strings.Contains(err.Error(), "unknown MCP bundle: core") {
+		t.Fatalf("expected --bundles to override config and fail on unknown bundle, got %v", err)
+	}
+}
+
+func TestBundleProxyExportsAndRestarts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := newMCPServer(map[string]bool{mcpGroupSession: true})
+	startBundleProxies(ctx, server, []BundleServer{downstreamBundleServer("lsp")})
+	defer stopBundleProxies()
+	defer cancel()
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	defer serverSession.Close()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "0.0.0"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	defer session.Close()
+
+	tools := listServerTools(t, server)
+	if !tools["lsp.definition"] {
+		t.Fatalf("expected namespaced downstream tool, got %v", tools)
+	}
+	callDefinition := func(symbol string) (*mcp.CallToolResult, error) {
+		return session.CallTool(ctx, &mcp.CallToolParams{Name: "lsp.definition", Arguments: map[string]any{"symbol": symbol}})
+	}
+	res, err := callDefinition("main")
+	if err != nil || res.IsError || res.Content[0].(*mcp.TextContent).Text != "def main" {
+		t.Fatalf("unexpected proxied result: %+v (%v)", res, err)
+	}
+
+	_, _ = callDefinition("crash")
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status := bundleProxyStatus()[0]
+		if status["status"] == "running" && status["restarts"] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("downstream server was not restarted: %v", status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	res, err = callDefinition("again")
+	if err != nil || res.IsError || res.Content[0].(*mcp.TextContent).Text != "def again" {
+		t.Fatalf("expected proxy to work after restart, got %+v (%v)", res, err)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	conductorURIPrefix   = "conductor://"
+	historyResourceURI   = "conductor://history"
+	historyResourceLimit = 100
+	// logNotifyInterval throttles resource-updated notifications while a run
+	// is writing output.
+	logNotifyInterval = 500 * time.Millisecond
+)
+
+// escapeURISegment percent-encodes everything outside RFC 3986 unreserved
+// characters so the segment matches a simple {var} template expansion
+// (memory keys such as role:oracle contain ':').
+func escapeURISegment(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func runResourceURI(runID string) string {
+	return conductorURIPrefix + "runs/" + escapeURISegment(runID)
+}
+
+func runStdoutURI(runID string) string {
+	return runResourceURI(runID) + "/stdout"
+}
+
+func runStderrURI(runID string) string {
+	return runResourceURI(runID) + "/stderr"
+}
+
+func memoryResourceURI(key string) string {
+	return conductorURIPrefix + "memory/" + escapeURISegment(key)
+}
+
+// conductorResource is a parsed conductor:// URI.
+type conductorResource struct {
+	Kind   string // queue, history, run, stdout, stderr, memory
+	RunID  string
+	MemKey string
+}
+
+func parseConductorURI(uri string) (conductorResource, error) {
+	switch uri {
+	case runtimeQueueResourceURI:
+		return conductorResource{Kind: "queue"}, nil
+	case historyResourceURI:
+		return conductorResource{Kind: "history"}, nil
+	}
+	rest, ok := strings.CutPrefix(uri, conductorURIPrefix)
+	if !ok {
+		return conductorResource{}, fmt.Errorf("unknown resource: %s", uri)
+	}
+	if escaped, ok := strings.CutPrefix(rest, "memory/"); ok {
+		key, err := url.PathUnescape(escaped)
+		if err != nil || strings.TrimSpace(key) == "" {
+			return conductorResource{}, fmt.Errorf("unknown resource: %s", uri)
+		}
+		return conductorResource{Kind: "memory", MemKey: key}, nil
+	}
+	if escaped, ok := strings.CutPrefix(rest, "runs/"); ok {
+		parts := strings.Split(escaped, "/")
+		runID, err := url.PathUnescape(parts[0])
+		if err != nil || runID == "" || strings.ContainsAny(runID, `/\`) || runID == "." || runID == ".." {
+			return conductorResource{}, fmt.Errorf("unknown resource: %s", uri)
+		}
+		switch {
+		case len(parts) == 1:
+			return conductorResource{Kind: "run", RunID: runID}, nil
+		case len(parts) == 2 && (parts[1] == "stdout" || parts[1] == "stderr"):
+			return conductorResource{Kind: parts[1], RunID: runID}, nil
+		}
+	}
+	return conductorResource{}, fmt.Errorf("unknown resource: %s", uri)
+}
+
+// registerConductorResources adds run history plus the run, run log and
+// shared memory resource templates.
+func registerConductorResources(server *mcp.Server) {
+	server.AddResource(&mcp.Resource{
+		URI:         historyResourceURI,
+		Name:        "Run history",
+		Description: fmt.Sprintf("The %d most recent run records, newest first.", historyResourceLimit),
+		MIMEType:    "application/json",
+	}, conductorResourceHandler)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: "conductor://runs/{id}",
+		Name:        "Run",
+		Description: "Status and metadata for a run, with links to its stdout/stderr resources.",
+		MIMEType:    "application/json",
+	}, conductorResourceHandler)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: "conductor://runs/{id}/stdout",
+		Name:        "Run stdout",
+		Description: "Full stdout of a run. Subscribe to be notified as an async run writes output.",
+		MIMEType:    "text/plain",
+	}, conductorResourceHandler)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: "conductor://runs/{id}/stderr",
+		Name:        "Run stderr",
+		Description: "Full stderr of a run. Subscribe to be notified as an async run writes output.",
+		MIMEType:    "text/plain",
+	}, conductorResourceHandler)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: "conductor://memory/{key}",
+		Name:        "Shared memory",
+		Description: "Value of a shared memory key (e.g. shared, role:oracle).",
+		MIMEType:    "text/plain",
+	}, conductorResourceHandler)
+}
+
+// conductorSubscribeHandler accepts subscriptions to any conductor:// resource.
+func conductorSubscribeHandler(ctx context.Context, req *mcp.SubscribeRequest) error {
+	_, err := parseConductorURI(req.Params.URI)
+	return err
+}
+
+// conductorUnsubscribeHandler accepts unsubscriptions from any conductor:// resource.
+func conductorUnsubscribeHandler(ctx context.Context, req *mcp.UnsubscribeRequest) error {
+	_, err := parseConductorURI(req.Params.URI)
+	return err
+}
+
+func conductorResourceHandler(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	res, err := parseConductorURI(uri)
+	if err != nil {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	switch res.Kind {
+	case "history":
+		records, err := readRunHistory(historyResourceLimit, "", "", "")
+		if err != nil {
+			return nil, err
+		}
+		return jsonResource(uri, map[string]interface{}{"count": len(records), "runs": records})
+	case "run":
+		payload, err := runResourcePayload(res.RunID)
+		if err != nil {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		return jsonResource(uri, payload)
+	case "stdout", "stderr":
+		data, err := os.ReadFile(filepath.Join(asyncRunDir(res.RunID), res.Kind+".log"))
+		if err != nil {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		return textResource(uri, string(data)), nil
+	case "memory":
+		entry, ok := sharedMemory.get(res.MemKey)
+		if !ok {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		return textResource(uri, entry.Value), nil
+	}
+	return nil, mcp.ResourceNotFoundError(uri)
+}
+
+// runResourcePayload returns run status without inline output; stdout and
+// stderr are linked as resources instead.
+func runResourcePayload(runID string) (map[string]interface{}, error) {
+	var payload map[string]interface{}
+	var err error
+	if runtime := mcpRuntimeSnapshot(); runtime != nil {
+		payload, err = mcpRuntimeRunStatus(runtime, runID, 0)
+	} else {
+		payload, err = getRunStatus(runID, 0)
+		if err != nil {
+			record, ok, findErr := findRunRecord(runID)
+			if findErr != nil {
+				return nil, findErr
+			}
+			if !ok {
+				return nil, errors.New("not_found")
+			}
+			payload, err = runRecordPayload(record), nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, len(payload)+2)
+	for key, val := range payload {
+		if key == "stdout" || key == "stderr" {
+			continue
+		}
+		out[key] = val
+	}
+	addRunLogLinks(out, runID)
+	return out, nil
+}
+
+func runRecordPayload(record RunRecord) map[string]interface{} {
+	payload := map[string]interface{}{
+		"run_id":     record.ID,
+		"status":     record.Status,
+		"agent":      firstNonEmpty(record.Role, record.Agent),
+		"role":       record.Role,
+		"model":      record.Model,
+		"exit_code":  record.ExitCode,
+		"error":      record.Error,
+		"error_code": record.ErrorCode,
+		"started_at": record.StartedAt,
+		"ended_at":   record.EndedAt,
+	}
+	if len(record.Hops) > 0 {
+		payload["hops"] = record.Hops
+	}
+	return payload
+}
+
+// addRunLogLinks sets stdout_uri/stderr_uri when the run kept log files.
+func addRunLogLinks(payload map[string]interface{}, runID string) {
+	if runID == "" {
+		return
+	}
+	dir := asyncRunDir(runID)
+	if pathExists(filepath.Join(dir, "stdout.log")) {
+		payload["stdout_uri"] = runStdoutURI(runID)
+	}
+	if pathExists(filepath.Join(dir, "stderr.log")) {
+		payload["stderr_uri"] = runStderrURI(runID)
+	}
+}
+
+func jsonResource(uri string, payload interface{}) (*mcp.ReadResourceResult, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{{URI: uri, MIMEType: "application/json", Text: string(data)}},
+	}, nil
+}
+
+func textResource(uri, text string) *mcp.ReadResourceResult {
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{{URI: uri, MIMEType: "text/plain", Text: text}},
+	}
+}
+
+// resourceUpdateWriter notifies subscribers of uri as output is written, at
+// most once per logNotifyInterval.
+type resourceUpdateWriter struct {
+	w    io.Writer
+	uri  string
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (r *resourceUpdateWriter) Write(p []byte) (int, error) {
+	n, err := r.w.Write(p)
+	if n > 0 {
+		r.mu.Lock()
+		now := time.Now()
+		due := now.Sub(r.last) >= logNotifyInterval
+		if due {
+			r.last = now
+		}
+		r.mu.Unlock()
+		if due {
+			notifyResourceUpdated(r.uri)
+		}
+	}
+	return n, err
+}
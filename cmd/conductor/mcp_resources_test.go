@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestParseConductorURI(t *testing.T) {
+	cases := []struct {
+		uri  string
+		want conductorResource
+	}{
+		{runtimeQueueResourceURI, conductorResource{Kind: "queue"}},
+		{historyResourceURI, conductorResource{Kind: "history"}},
+		{"conductor://runs/abc", conductorResource{Kind: "run", RunID: "abc"}},
+		{"conductor://runs/abc/stdout", conductorResource{Kind: "stdout", RunID: "abc"}},
+		{"conductor://runs/abc/stderr", conductorResource{Kind: "stderr", RunID: "abc"}},
+		{memoryResourceURI("role:oracle"), conductorResource{Kind: "memory", MemKey: "role:oracle"}},
+		{memoryResourceURI("a/b c"), conductorResource{Kind: "memory", MemKey: "a/b c"}},
+	}
+	for _, tc := range cases {
+		got, err := parseConductorURI(tc.uri)
+		if err != nil {
+			t.Fatalf("parse %s: %v", tc.uri, err)
+		}
+		if got != tc.want {
+			t.Fatalf("parse %s: expected %+v, got %+v", tc.uri, tc.want, got)
+		}
+	}
+	for _, bad := range []string{"conductor://runs/", "conductor://runs/..", "conductor://runs/abc/meta", "conductor://runs/%2e%2e/stdout", "conductor://memory/", "file:///etc/passwd"} {
+		if _, err := parseConductorURI(bad); err == nil {
+			t.Fatalf("expected %s to be rejected", bad)
+		}
+	}
+}
+
+func TestSummarizePayloadLinksRunLogs(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	withIsolatedMemoryStore(t, func() {})
+	installFakeCLI(t, "codex", "echo full output\n")
+	payload, err := runCommand(CmdSpec{Agent: "codex", Cmd: "codex"})
+	if err != nil {
+		t.Fatalf("run command: %v", err)
+	}
+	runID := payload["run_id"].(string)
+	summary := summarizePayload(payload)
+	if _, ok := summary["stdout"]; ok {
+		t.Fatal("expected stdout to be dropped from summary")
+	}
+	if summary["stdout_uri"] != runStdoutURI(runID) || summary["stderr_uri"] != runStderrURI(runID) {
+		t.Fatalf("expected log links, got %v", summary)
+	}
+}
+
+func readResourceText(t *testing.T, session *mcp.ClientSession, uri string) string {
+	t.Helper()
+	res, err := session.ReadResource(context.Background(), &mcp.ReadResourceParams{URI: uri})
+	if err != nil {
+		t.Fatalf("read %s: %v", uri, err)
+	}
+	if len(res.Contents) != 1 {
+		t.Fatalf("expected one content for %s, got %d", uri, len(res.Contents))
+	}
+	return res.Contents[0].Text
+}
+
+func TestRunLogResourcesNotifySubscribers(t *testing.T) {
+	resetRuntime()
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	installFakeCLI(t, "codex", "echo first\nsleep 0.3\necho second\necho oops >&2\n")
+
+	var mu sync.Mutex
+	updates := map[string]int{}
+	_, session := connectTestClient(t, mcp.ClientOptions{
+		ResourceUpdatedHandler: func(ctx context.Context, req *mcp.ResourceUpdatedNotificationRequest) {
+			mu.Lock()
+			updates[req.Params.URI]++
+			mu.Unlock()
+		},
+	})
+	ctx := context.Background()
+
+	runID := newRunID()
+	for _, uri := range []string{runStdoutURI(runID), historyResourceURI} {
+		if err := session.Subscribe(ctx, &mcp.SubscribeParams{URI: uri}); err != nil {
+			t.Fatalf("subscribe %s: %v", uri, err)
+		}
+	}
+	if err := session.Subscribe(ctx, &mcp.SubscribeParams{URI: "conductor://bogus"}); err == nil {
+		t.Fatal("expected subscribe to unknown resource to fail")
+	}
+
+	if _, err := startAsyncWithID(runID, CmdSpec{Agent: "codex", Cmd: "codex"}); err != nil {
+		t.Fatalf("start async: %v", err)
+	}
+	if _, err := waitRun(runID, 10*time.Second, 0); err != nil {
+		t.Fatalf("wait run: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		stdoutUpdates, historyUpdates := updates[runStdoutURI(runID)], updates[historyResourceURI]
+		mu.Unlock()
+		if stdoutUpdates >= 2 && historyUpdates >= 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected stdout and history updates, got %v", updates)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if got := readResourceText(t, session, runStdoutURI(runID)); got != "first\nsecond\n" {
+		t.Fatalf("unexpected stdout resource: %q", got)
+	}
+	if got := readResourceText(t, session, runStderrURI(runID)); got != "oops\n" {
+		t.Fatalf("unexpected stderr resource: %q", got)
+	}
+
+	var run map[string]interface{}
+	if err := json.Unmarshal([]byte(readResourceText(t, session, runResourceURI(runID))), &run); err != nil {
+		t.Fatalf("decode run: %v", err)
+	}
+	if run["status"] != "ok" || run["stdout_uri"] != runStdoutURI(runID) {
+		t.Fatalf("unexpected run resource: %v", run)
+	}
+	if _, ok := run["stdout"]; ok {
+		t.Fatal("expected run resource to link stdout instead of inlining it")
+	}
+
+	history := readResourceText(t, session, historyResourceURI)
+	if !strings.Contains(history, runID) {
+		t.Fatalf("expected run %s in history: %s", runID, history)
+	}
+
+	if _, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: runStdoutURI("missing")}); err == nil {
+		t.Fatal("expected missing run log to fail")
+	}
+}
+
+func TestMemoryResource(t *testing.T) {
+	withIsolatedMemoryStore(t, func() {
+		var mu sync.Mutex
+		var updated []string
+		_, session := connectTestClient(t, mcp.ClientOptions{
+			ResourceUpdatedHandler: func(ctx context.Context, req *mcp.ResourceUpdatedNotificationRequest) {
+				mu.Lock()
+				updated = append(updated, req.Params.URI)
+				mu.Unlock()
+			},
+		})
+		uri := memoryResourceURI("role:oracle")
+		if err := session.Subscribe(context.Background(), &mcp.SubscribeParams{URI: uri}); err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+		if _, err := sharedMemory.set("role:oracle", "notes"); err != nil {
+			t.Fatalf("set memory: %v", err)
+		}
+		if got := readResourceText(t, session, uri); got != "notes" {
+			t.Fatalf("unexpected memory resource: %q", got)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mu.Lock()
+			n := len(updated)
+			mu.Unlock()
+			if n > 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("expected memory update notification")
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		sharedMemory.clear("role:oracle")
+		if _, err := session.ReadResource(context.Background(), &mcp.ReadResourceParams{URI: uri}); err == nil {
+			t.Fatal("expected cleared memory key to be missing")
+		}
+	})
+}
+
+func TestSessionRunKeepsLogs(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	installFakeCLI(t, "codex", "echo out\necho err >&2\n")
+	runID := newRunID()
+	adapter := &CLIAdapter{Name: "Codex", Cmd: "codex"}
+	// A parent run id in the env (e.g. from a role's env) must not pick the log directory.
+	opts := CLIRunOptions{RunID: runID, Env: map[string]string{envConductorParentRun: "run-other"}}
+	if _, err := adapter.Run(context.Background(), opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if _, err := os.Stat(asyncRunDir("run-other")); !os.IsNotExist(err) {
+		t.Errorf("expected no logs under the env's run id, stat err=%v", err)
+	}
+	for name, want := range map[string]string{"stdout.log": "out\n", "stderr.log": "err\n"} {
+		data, err := os.ReadFile(filepath.Join(asyncRunDir(runID), name))
+		if err != nil || string(data) != want {
+			t.Errorf("expected %s %q, got %q (%v)", name, want, data, err)
+		}
+	}
+	payload := map[string]interface{}{}
+	addRunLogLinks(payload, runID)
+	if payload["stdout_uri"] != runStdoutURI(runID) {
+		t.Errorf("expected session run log links, got %v", payload)
+	}
+}
+
+func TestPruneRunDirs(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	old := time.Now().Add(-2 * runDirRetention)
+	makeRun := func(runID string, meta *AsyncMeta, modTime time.Time) {
+		dir := asyncRunDir(runID)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "stdout.log"), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if meta != nil {
+			meta.ID = runID
+			if err := writeAsyncMeta(*meta); err != nil {
+				t.Fatal(err)
+			}
+		}
+		files, _ := os.ReadDir(dir)
+		for _, file := range files {
+			_ = os.Chtimes(filepath.Join(dir, file.Name()), modTime, modTime)
+		}
+		_ = os.Chtimes(dir, modTime, modTime)
+	}
+	makeRun("run-old", nil, old)
+	makeRun("run-old-async", &AsyncMeta{Status: "ok"}, old)
+	makeRun("run-live", &AsyncMeta{Status: "running", PID: os.Getpid()}, old)
+	makeRun("run-recent", nil, time.Now())
+
+	removed, err := pruneRunDirs(time.Now().Add(-runDirRetention))
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	sort.Strings(removed)
+	if strings.Join(removed, ",") != "run-old,run-old-async" {
+		t.Fatalf("expected old finished runs removed, got %v", removed)
+	}
+	for _, kept := range []string{"run-live", "run-recent"} {
+		if !pathExists(asyncRunDir(kept)) {
+			t.Errorf("expected %s to be kept", kept)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import "encoding/json"
+
+// Typed MCP tool results. mcp.AddTool derives each tool's output schema from
+// these, so hosts see the exact field names (structuredContent.threadId,
+// run_id, changed_files, ...) instead of a free-form object. Renaming a JSON
+// tag here is a breaking change for hosts; mcp_results_test.go pins them.
+
+// TextBlock is a text content entry in a session response.
+type TextBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// SessionResult is returned by codex, claude, gemini, conductor, their
+// *-reply tools and handoff.
+type SessionResult struct {
+	Content           []TextBlock       `json:"content"`
+	StructuredContent SessionStructured `json:"structuredContent"`
+}
+
+// SessionStructured identifies the thread to continue with *-reply. Answers
+// from host sampling leave ThreadID empty.
+type SessionStructured struct {
+	ThreadID       string        `json:"threadId"`
+	CLI            string        `json:"cli,omitempty"`
+	Role           string        `json:"role,omitempty"`
+	Model          string        `json:"model,omitempty"`
+	HandoffFrom    string        `json:"handoffFrom,omitempty"`
+	HandoffContext string        `json:"handoffContext,omitempty"` // transcript, memory or none
+	Backend        string        `json:"backend,omitempty"`        // host-sampling when no delegate CLI could run
+	Hops           []FallbackHop `json:"hops,omitempty"`           // failed attempts of a fallback chain
+}
+
+// RunResult is a single run payload: conductor.run*, run_status, run_wait,
+// run_cancel and the approval tools. Fields are filled depending on the run's
+// stage; an unknown role yields status "unknown_role" with roles/message.
+type RunResult struct {
+	RunID            string        `json:"run_id,omitempty"`
+	Status           string        `json:"status"`
+	Agent            string        `json:"agent,omitempty"`
+	Role             string        `json:"role,omitempty"`
+	Model            string        `json:"model,omitempty"`
+	Kind             string        `json:"kind,omitempty"` // async or session
+	Cmd              string        `json:"cmd,omitempty"`
+	Args             []string      `json:"args,omitempty"`
+	PID              int           `json:"pid,omitempty"`
+	Attempt          int           `json:"attempt,omitempty"`
+	Attempts         int           `json:"attempts,omitempty"`
+	ExitCode         int           `json:"exit_code,omitempty"`
+	Stdout           string        `json:"stdout,omitempty"`
+	Stderr           string        `json:"stderr,omitempty"`
+	StdoutURI        string        `json:"stdout_uri,omitempty"`
+	StderrURI        string        `json:"stderr_uri,omitempty"`
+	DurationMs       int64         `json:"duration_ms,omitempty"`
+	CreatedAt        string        `json:"created_at,omitempty"`
+	StartedAt        string        `json:"started_at,omitempty"`
+	EndedAt          string        `json:"ended_at,omitempty"`
+	Error            string        `json:"error,omitempty"`
+	ErrorCode        string        `json:"error_code,omitempty"`
+	ReadFiles        []string      `json:"read_files,omitempty"`
+	ChangedFiles     []string      `json:"changed_files,omitempty"`
+	ModeHash         string        `json:"mode_hash,omitempty"`
+	ApprovalRequired bool          `json:"approval_required,omitempty"`
+	Roles            []string      `json:"roles,omitempty"`
+	Config           string        `json:"config,omitempty"`
+	Message          string        `json:"message,omitempty"`
+	Hops             []FallbackHop `json:"hops,omitempty"`
+}
+
+// BatchResult is returned by conductor.run_batch and run_batch_async.
+// Synchronous batches fill results; queued batches fill runs.
+type BatchResult struct {
+	Status      string      `json:"status"`
+	Agents      []string    `json:"agents,omitempty"`
+	Count       int         `json:"count"`
+	MaxParallel int         `json:"max_parallel,omitempty"`
+	Results     []RunResult `json:"results,omitempty"`
+	Runs        []RunResult `json:"runs,omitempty"`
+	Warning     string      `json:"warning,omitempty"`
+	Note        string      `json:"note,omitempty"`
+	Config      string      `json:"config,omitempty"`
+}
+
+// HistoryResult is returned by conductor.run_history.
+type HistoryResult struct {
+	Count int         `json:"count"`
+	Runs  []RunRecord `json:"runs"`
+}
+
+// RunInfoResult is returned by conductor.run_info.
+type RunInfoResult struct {
+	Found bool      `json:"found"`
+	Run   RunRecord `json:"run"`
+}
+
+// RolesResult is returned by conductor.roles and conductor.status.
+type RolesResult struct {
+	Count    int          `json:"count"`
+	Roles    []RoleStatus `json:"roles"`
+	Config   string       `json:"config"`
+	Disabled bool         `json:"disabled"`
+}
+
+// RoleStatus is one configured role; status/error are only set by
+// conductor.status.
+type RoleStatus struct {
+	Role      string `json:"role"`
+	CLI       string `json:"cli,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Reasoning string `json:"reasoning,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// QueueResult is returned by conductor.queue_list and approval_list.
+type QueueResult struct {
+	Status string      `json:"status,omitempty"` // runtime_not_running
+	Count  int         `json:"count"`
+	Runs   []RunResult `json:"runs,omitempty"`
+}
+
+// RuntimeStatusResult is returned by conductor.runtime_status.
+type RuntimeStatusResult struct {
+	Status      string `json:"status,omitempty"` // runtime_not_running
+	OK          bool   `json:"ok"`
+	PID         int    `json:"pid,omitempty"`
+	Host        string `json:"host,omitempty"`
+	Port        int    `json:"port"`
+	StartedAt   string `json:"started_at,omitempty"`
+	UptimeMs    int64  `json:"uptime_ms,omitempty"`
+	Queued      int    `json:"queued"`
+	Awaiting    int    `json:"awaiting"`
+	Running     int    `json:"running"`
+	Completed   int    `json:"completed"`
+	MaxParallel int    `json:"max_parallel,omitempty"`
+	ModeHash    string `json:"mode_hash,omitempty"`
+}
+
+// MemoryResult is returned by the memory tool. Single-key actions fill
+// key/size/updated_at (and value for get); list fills items.
+type MemoryResult struct {
+	Status    string           `json:"status"`
+	Key       string           `json:"key,omitempty"`
+	Size      int              `json:"size,omitempty"`
+	UpdatedAt string           `json:"updated_at,omitempty"`
+	Value     string           `json:"value,omitempty"`
+	Count     int              `json:"count,omitempty"`
+	Items     []MemoryItemView `json:"items,omitempty"`
+	MaxBytes  int              `json:"max_bytes,omitempty"`
+	Removed   bool             `json:"removed,omitempty"`
+}
+
+// MemoryItemView is one key in a memory list.
+type MemoryItemView struct {
+	Key       string `json:"key"`
+	Size      int    `json:"size"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// SessionView describes a stored session; config/expiresAt are only set by
+// inspect and last.
+type SessionView struct {
+	ThreadID       string            `json:"threadId"`
+	NativeThreadID string            `json:"nativeThreadId"`
+	CLI            string            `json:"cli"`
+	Role           string            `json:"role"`
+	Model          string            `json:"model"`
+	CreatedAt      string            `json:"createdAt"`
+	UpdatedAt      string            `json:"updatedAt"`
+	LastStatus     string            `json:"lastStatus,omitempty"`
+	HandoffFrom    string            `json:"handoffFrom,omitempty"`
+	HandoffTo      []string          `json:"handoffTo,omitempty"`
+	Config         *MCPSessionConfig `json:"config,omitempty"`
+	ExpiresAt      string            `json:"expiresAt,omitempty"`
+}
+
+// SessionsResult is returned by the sessions tool. list fills sessions;
+// inspect/last fill the session fields; delete/expire report what was removed.
+type SessionsResult struct {
+	Status         string            `json:"status,omitempty"`
+	Count          int               `json:"count,omitempty"`
+	Sessions       []SessionView     `json:"sessions,omitempty"`
+	Removed        []string          `json:"removed,omitempty"`
+	ThreadID       string            `json:"threadId,omitempty"`
+	NativeThreadID string            `json:"nativeThreadId,omitempty"`
+	CLI            string            `json:"cli,omitempty"`
+	Role           string            `json:"role,omitempty"`
+	Model          string            `json:"model,omitempty"`
+	CreatedAt      string            `json:"createdAt,omitempty"`
+	UpdatedAt      string            `json:"updatedAt,omitempty"`
+	LastStatus     string            `json:"lastStatus,omitempty"`
+	HandoffFrom    string            `json:"handoffFrom,omitempty"`
+	HandoffTo      []string          `json:"handoffTo,omitempty"`
+	Config         *MCPSessionConfig `json:"config,omitempty"`
+	ExpiresAt      string            `json:"expiresAt,omitempty"`
+}
+
+// TranscriptResult is returned by the transcript tool.
+type TranscriptResult struct {
+	ThreadID string `json:"threadId"`
+	Turns    int    `json:"turns"`
+	Path     string `json:"path"`
+	Format   string `json:"format"`
+	Content  string `json:"content"`
+}
+
+// StatusResult is returned by the status tool.
+type StatusResult struct {
+	CLI       map[string]CLIStatus `json:"cli"`
+	Allowlist mcpAllowlist         `json:"allowlist"`
+	Bundles   []BundleProxyStatus  `json:"bundles,omitempty"`
+	Sessions  SessionsStatus       `json:"sessions"`
+}
+
+// BundleProxyStatus reports one downstream server proxied from a bundle.
+type BundleProxyStatus struct {
+	Name     string   `json:"name"`
+	Command  string   `json:"command"`
+	Status   string   `json:"status"` // starting, running, restarting or stopped
+	Tools    []string `json:"tools"`
+	Restarts int      `json:"restarts"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// CLIStatus reports whether a delegate CLI is installed and logged in.
+type CLIStatus struct {
+	Available     bool   `json:"available"`
+	Authenticated bool   `json:"authenticated"`
+	Status        string `json:"status"`
+}
+
+// SessionsStatus summarizes the session store.
+type SessionsStatus struct {
+	Count  int           `json:"count"`
+	Max    int           `json:"max"`
+	TTL    string        `json:"ttl"`
+	Active []SessionView `json:"active"`
+}
+
+// toolResult converts a payload map into its typed tool result. Run, batch,
+// roles, memory and session payloads are built as maps because the CLI
+// commands print the same payloads with printJSON; converting at the MCP
+// boundary keeps both outputs identical.
+func toolResult[T any](payload map[string]interface{}) (*T, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	out := new(T)
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
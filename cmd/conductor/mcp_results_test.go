@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// jsonFieldNames lists the JSON names of a struct's fields.
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestToolResultFieldNames pins the wire names hosts rely on. A failure here
+// means a field was renamed or removed: that breaks MCP clients, so update
+// this list only together with a documented breaking change.
+func TestToolResultFieldNames(t *testing.T) {
+	want := map[reflect.Type]string{
+		reflect.TypeOf(SessionResult{}):       "content structuredContent",
+		reflect.TypeOf(TextBlock{}):           "text type",
+		reflect.TypeOf(SessionStructured{}):   "backend cli handoffContext handoffFrom hops model role threadId",
+		reflect.TypeOf(RunResult{}):           "agent approval_required args attempt attempts changed_files cmd config created_at duration_ms ended_at error error_code exit_code hops kind message mode_hash model pid read_files role roles run_id started_at status stderr stderr_uri stdout stdout_uri",
+		reflect.TypeOf(BatchResult{}):         "agents config count max_parallel note results runs status warning",
+		reflect.TypeOf(HistoryResult{}):       "count runs",
+		reflect.TypeOf(RunInfoResult{}):       "found run",
+		reflect.TypeOf(RunRecord{}):           "agent args changed_files cmd depth duration_ms ended_at error error_code exit_code hops id model parent_run prompt prompt_hash prompt_len read_files role root_run started_at status",
+		reflect.TypeOf(FallbackHop{}):         "error failure_class model role run_id status",
+		reflect.TypeOf(RolesResult{}):         "config count disabled roles",
+		reflect.TypeOf(RoleStatus{}):          "cli error model reasoning role status",
+		reflect.TypeOf(QueueResult{}):         "count runs status",
+		reflect.TypeOf(RuntimeStatusResult{}): "awaiting completed host max_parallel mode_hash ok pid port queued running started_at status uptime_ms",
+		reflect.TypeOf(MemoryResult{}):        "count items key max_bytes removed size status updated_at value",
+		reflect.TypeOf(MemoryItemView{}):      "key size updated_at",
+		reflect.TypeOf(SessionView{}):         "cli config createdAt expiresAt handoffFrom handoffTo lastStatus model nativeThreadId role threadId updatedAt",
+		reflect.TypeOf(SessionsResult{}):      "cli config count createdAt expiresAt handoffFrom handoffTo lastStatus model nativeThreadId removed role sessions status threadId updatedAt",
+		reflect.TypeOf(TranscriptResult{}):    "content format path threadId turns",
+		reflect.TypeOf(StatusResult{}):        "allowlist bundles cli sessions",
+		reflect.TypeOf(BundleProxyStatus{}):   "command error name restarts status tools",
+		reflect.TypeOf(mcpAllowlist{}):        "roles tools",
+		reflect.TypeOf(CLIStatus{}):           "authenticated available status",
+		reflect.TypeOf(SessionsStatus{}):      "active count max ttl",
+	}
+	for typ, fields := range want {
+		if got := strings.Join(jsonFieldNames(typ), " "); got != fields {
+			t.Errorf("%s fields changed:\n got: %s\nwant: %s", typ.Name(), got, fields)
+		}
+	}
+}
+
+// decodeStrict fails when payload has a key the result type does not
+// declare, i.e. when a producer adds or renames a field without updating the
+// typed result (which would silently drop it from MCP output).
+func decodeStrict[T any](t *testing.T, name string, payload map[string]interface{}) {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("%s: marshal: %v", name, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var out T
+	if err := dec.Decode(&out); err != nil {
+		t.Errorf("%s: payload does not match %T: %v", name, out, err)
+	}
+}
+
+func TestToolResultsCoverPayloads(t *testing.T) {
+	resetRuntime()
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	withIsolatedMemoryStore(t, func() {})
+	installFakeCLI(t, "codex", "echo done\n")
+	// The async run fails so its goroutine does not write shared memory after
+	// waitRun returns.
+	installFakeCLI(t, "gemini", "echo failed >&2\nexit 3\n")
+	cfg := Config{Roles: map[string]RoleConfig{"oracle": {CLI: "codex", Model: "gpt-5", Reasoning: "high"}, "broken": {}}}
+
+	run, err := runCommand(CmdSpec{Agent: "codex", Role: "oracle", Cmd: "codex"})
+	if err != nil {
+		t.Fatalf("run command: %v", err)
+	}
+	decodeStrict[RunResult](t, "runCommand", run)
+	decodeStrict[RunResult](t, "summarizePayload", summarizePayload(run))
+	decodeStrict[RunResult](t, "unknownRolePayload", unknownRolePayload(cfg, "ghost", "conductor.json"))
+
+	started, err := startAsync(CmdSpec{Agent: "gemini", Cmd: "gemini"})
+	if err != nil {
+		t.Fatalf("start async: %v", err)
+	}
+	decodeStrict[RunResult](t, "startAsync", started)
+	status, err := waitRun(started["run_id"].(string), 10*time.Second, 100)
+	if err != nil {
+		t.Fatalf("wait run: %v", err)
+	}
+	decodeStrict[RunResult](t, "getRunStatus", status)
+
+	item := &RunItem{ID: "r-1", Status: "queued", Spec: CmdSpec{Role: "oracle"}, CreatedAt: time.Now()}
+	decodeStrict[RunResult](t, "RunItem.view", item.view())
+	decodeStrict[RunResult](t, "runRecordPayload", runRecordPayload(RunRecord{ID: "r-1", Status: "ok"}))
+
+	batch := map[string]interface{}{
+		"status":       "ok",
+		"agents":       []string{"oracle"},
+		"results":      []map[string]interface{}{run},
+		"count":        1,
+		"max_parallel": 1,
+		"warning":      nil,
+	}
+	decodeStrict[BatchResult](t, "runBatch", batch)
+	decodeStrict[BatchResult](t, "summarizeBatchPayload", summarizeBatchPayload(batch))
+
+	decodeStrict[RolesResult](t, "listRolesPayload", listRolesPayload(cfg, "conductor.json"))
+	rolesStatus, _ := statusPayload(cfg, "conductor.json")
+	decodeStrict[RolesResult](t, "statusPayload", rolesStatus)
+
+	runtime, err := ensureMcpRuntime("")
+	if err != nil {
+		t.Fatalf("ensure runtime: %v", err)
+	}
+	defer resetRuntime()
+	decodeStrict[RuntimeStatusResult](t, "mcpRuntimeHealthPayload", mcpRuntimeHealthPayload(runtime))
+	queue, _ := queueListTool(QueueListInput{})
+	decodeStrict[QueueResult](t, "queueListTool", queue)
+
+	withIsolatedMemoryStore(t, func() {
+		for _, input := range []MCPMemoryInput{
+			{Action: "set", Key: "k", Value: "v"},
+			{Action: "get", Key: "k"},
+			{Action: "list"},
+			{Action: "clear", Key: "k"},
+			{Action: "clear_all"},
+		} {
+			payload, err := mcpHandleMemory(input)
+			if err != nil {
+				t.Fatalf("memory %s: %v", input.Action, err)
+			}
+			decodeStrict[MemoryResult](t, "memory "+input.Action, payload)
+		}
+	})
+
+	resetMCPSessions(t)
+	mcpStoreSession(&MCPSession{ID: "t-1", CLI: "codex", Role: "oracle", HandoffTo: []string{"t-2"}, LastStatus: "ok", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	for _, input := range []MCPSessionsInput{
+		{Action: "list"},
+		{Action: "inspect", ThreadID: "t-1"},
+		{Action: "expire"},
+		{Action: "delete", ThreadID: "t-1"},
+	} {
+		payload, err := mcpHandleSessions(input)
+		if err != nil {
+			t.Fatalf("sessions %s: %v", input.Action, err)
+		}
+		decodeStrict[SessionsResult](t, "sessions "+input.Action, payload)
+	}
+	decodeStrict[StatusResult](t, "mcpGetStatus", mcpGetStatus())
+
+	mcpTranscriptsEnabled = true
+	defer func() { mcpTranscriptsEnabled = false }()
+	recordTranscriptTurn(&MCPSession{ID: "t-9", CLI: "codex"}, "hi", "hi", "hello", time.Now())
+	transcript, err := mcpExportTranscript(MCPTranscriptInput{ThreadID: "t-9"})
+	if err != nil {
+		t.Fatalf("export transcript: %v", err)
+	}
+	decodeStrict[TranscriptResult](t, "mcpExportTranscript", transcript)
+}
+
+func TestEveryToolAdvertisesOutputSchema(t *testing.T) {
+	ctx := context.Background()
+	server := newMCPServer(map[string]bool{mcpGroupSession: true, mcpGroupRuntime: true})
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	defer serverSession.Close()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "0.0.0"}, nil)
+	clientSession, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	defer clientSession.Close()
+
+	res, err := clientSession.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	for _, tool := range res.Tools {
+		if tool.OutputSchema == nil || len(tool.OutputSchema.Properties) == 0 {
+			t.Errorf("tool %s has no typed output schema", tool.Name)
+		}
+	}
+	for _, tool := range res.Tools {
+		if tool.Name != "codex" {
+			continue
+		}
+		structured := tool.OutputSchema.Properties["structuredContent"]
+		if structured == nil || structured.Properties["threadId"] == nil {
+			t.Fatalf("expected codex schema to declare structuredContent.threadId, got %+v", tool.OutputSchema)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+var (
+	liveServersMu sync.Mutex
+	liveServers   = map[*mcp.Server]struct{}{}
+)
+
+// trackServer makes notifyResourceUpdated reach the subscribers of server
+// until the returned func is called.
+func trackServer(server *mcp.Server) func() {
+	liveServersMu.Lock()
+	liveServers[server] = struct{}{}
+	liveServersMu.Unlock()
+	return func() {
+		liveServersMu.Lock()
+		delete(liveServers, server)
+		liveServersMu.Unlock()
+	}
+}
+
+// notifyResourceUpdated tells the sessions subscribed to uri, on every
+// tracked server, that the resource changed.
+func notifyResourceUpdated(uri string) {
+	liveServersMu.Lock()
+	servers := make([]*mcp.Server, 0, len(liveServers))
+	for server := range liveServers {
+		servers = append(servers, server)
+	}
+	liveServersMu.Unlock()
+	for _, server := range servers {
+		_ = server.ResourceUpdated(context.Background(), &mcp.ResourceUpdatedNotificationParams{URI: uri})
+	}
+}
+
+type callerSessionKey struct{}
+
+// callerSessionMiddleware records the session of a tool call in its context,
+// so approvals and host sampling go back to the client that asked.
+func callerSessionMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if ss, ok := req.GetSession().(*mcp.ServerSession); ok && method == "tools/call" {
+			ctx = context.WithValue(ctx, callerSessionKey{}, ss)
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// callerSession returns the session whose tool call ctx belongs to, or nil.
+func callerSession(ctx context.Context) *mcp.ServerSession {
+	ss, _ := ctx.Value(callerSessionKey{}).(*mcp.ServerSession)
+	return ss
+}
+
+// clientCapabilities returns what the client of ss advertised, or nil.
+func clientCapabilities(ss *mcp.ServerSession) *mcp.ClientCapabilities {
+	if ss == nil {
+		return nil
+	}
+	if init := ss.InitializeParams(); init != nil {
+		return init.Capabilities
+	}
+	return nil
+}
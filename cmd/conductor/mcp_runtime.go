@@ -25,24 +25,10 @@ var (
 	mcpRuntimeMu         sync.Mutex
 	mcpRuntime           *Runtime
 	mcpRuntimeConfigPath string
-	runtimeNotifyMu      sync.Mutex
-	runtimeNotify        func(context.Context) error
 )
 
-func setRuntimeNotify(notify func(context.Context) error) {
-	runtimeNotifyMu.Lock()
-	runtimeNotify = notify
-	runtimeNotifyMu.Unlock()
-}
-
 func notifyRuntimeChanged() {
-	runtimeNotifyMu.Lock()
-	notify := runtimeNotify
-	runtimeNotifyMu.Unlock()
-	if notify == nil {
-		return
-	}
-	_ = notify(context.Background())
+	notifyResourceUpdated(runtimeQueueResourceURI)
 }
 
 func runtimeQueueSnapshot(runtime *Runtime) map[string]interface{} {
@@ -117,7 +103,29 @@ type RunItem struct {
 	StartedAt       time.Time
 	EndedAt         time.Time
 	Error           string
+	ErrorCode       string // set when the code cannot be derived from Status
 	ExitCode        int
+
+	// session is set for items enqueued by the MCP session tools; they run
+	// in-process through a CLIAdapter instead of as detached async runs.
+	session *sessionRun
+	// done is closed once the item reaches a terminal state.
+	done chan struct{}
+	// requester is the MCP session that queued the item; approval questions
+	// go to it.
+	requester *mcp.ServerSession
+	// stopElicit withdraws the approval question sent to the client, if any.
+	stopElicit context.CancelFunc
+}
+
+// sessionRun carries the adapter invocation and result for a session item.
+type sessionRun struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	adapter *CLIAdapter
+	opts    CLIRunOptions
+	output  string
+	err     error
 }
 
 type Runtime struct {
@@ -128,6 +136,7 @@ type Runtime struct {
 	lastMode   string
 	startedAt  time.Time
 	shutdownCh chan struct{}
+	wakeCh     chan struct{}
 	mu         sync.Mutex
 }
 
@@ -169,6 +178,7 @@ func ensureMcpRuntime(configPath string) (*Runtime, error) {
 			completed:  []*RunItem{},
 			startedAt:  time.Now().UTC(),
 			shutdownCh: make(chan struct{}),
+			wakeCh:     make(chan struct{}, 1),
 		}
 		mcpRuntimeConfigPath = resolved
 		go mcpRuntime.schedulerLoop()
@@ -220,7 +230,7 @@ func mcpRuntimeHealthPayload(runtime *Runtime) map[string]interface{} {
 }
 
 func mcpRuntimeRunStatus(runtime *Runtime, runID string, tail int) (map[string]interface{}, error) {
-	if payload, ok := runtime.findQueued(runID); ok {
+	if payload, ok := runtime.findLocal(runID); ok {
 		return payload, nil
 	}
 	res, err := getRunStatus(runID, tail)
@@ -228,17 +238,7 @@ func mcpRuntimeRunStatus(runtime *Runtime, runID string, tail int) (map[string]i
 		return res, nil
 	}
 	if record, ok, _ := findRunRecord(runID); ok {
-		return map[string]interface{}{
-			"run_id":     record.ID,
-			"status":     record.Status,
-			"agent":      firstNonEmpty(record.Role, record.Agent),
-			"role":       record.Role,
-			"model":      record.Model,
-			"exit_code":  record.ExitCode,
-			"error":      record.Error,
-			"started_at": record.StartedAt,
-			"ended_at":   record.EndedAt,
-		}, nil
+		return runRecordPayload(record), nil
 	}
 	return nil, errors.New("not_found")
 }
@@ -251,7 +251,7 @@ func mcpRuntimeWait(runtime *Runtime, runID string, timeout time.Duration, tail
 			return nil, err
 		}
 		status, _ := res["status"].(string)
-		if status != "running" && status != "queued" && status != "awaiting_approval" {
+		if status != "running" && status != "starting" && status != "queued" && status != "awaiting_approval" {
 			return res, nil
 		}
 		if time.Now().After(deadline) {
@@ -261,13 +261,16 @@ func mcpRuntimeWait(runtime *Runtime, runID string, timeout time.Duration, tail
 	}
 }
 
-func mcpRuntimeRun(input RunInput, spec CmdSpec) (map[string]interface{}, error) {
+func mcpRuntimeRun(ctx context.Context, input RunInput, spec CmdSpec) (map[string]interface{}, error) {
 	runtime, err := ensureMcpRuntime(input.Config)
 	if err != nil {
 		return nil, err
 	}
 	modeHash := computeModeHash(spec, input.Mode)
 	requiresApproval := input.RequireApproval || needsApproval(spec, runtime.cfg)
+	for _, hop := range spec.Fallback {
+		requiresApproval = requiresApproval || needsApproval(hop, runtime.cfg)
+	}
 	item := &RunItem{
 		ID:              newRunID(),
 		Status:          "queued",
@@ -275,20 +278,23 @@ func mcpRuntimeRun(input RunInput, spec CmdSpec) (map[string]interface{}, error)
 		ModeHash:        modeHash,
 		RequireApproval: requiresApproval,
 		CreatedAt:       time.Now().UTC(),
+		requester:       callerSession(ctx),
 	}
 	if requiresApproval {
 		item.Status = "awaiting_approval"
 	}
-	runtime.enqueue(item)
-	return map[string]interface{}{
+	// The scheduler owns item once it is queued, so build the reply first.
+	res := map[string]interface{}{
 		"run_id":            item.ID,
 		"status":            item.Status,
 		"mode_hash":         item.ModeHash,
 		"approval_required": item.RequireApproval,
-	}, nil
+	}
+	runtime.enqueue(item)
+	return res, nil
 }
 
-func mcpRuntimeRunBatch(input BatchInput) (map[string]interface{}, error) {
+func mcpRuntimeRunBatch(ctx context.Context, input BatchInput) (map[string]interface{}, error) {
 	runtime, err := ensureMcpRuntime(input.Config)
 	if err != nil {
 		return nil, err
@@ -309,11 +315,11 @@ func mcpRuntimeRunBatch(input BatchInput) (map[string]interface{}, error) {
 			ModeHash:        modeHash,
 			RequireApproval: requiresApproval,
 			CreatedAt:       time.Now().UTC(),
+			requester:       callerSession(ctx),
 		}
 		if requiresApproval {
 			item.Status = "awaiting_approval"
 		}
-		runtime.enqueue(item)
 		results = append(results, map[string]interface{}{
 			"run_id":            item.ID,
 			"status":            item.Status,
@@ -321,6 +327,7 @@ func mcpRuntimeRunBatch(input BatchInput) (map[string]interface{}, error) {
 			"mode_hash":         item.ModeHash,
 			"approval_required": item.RequireApproval,
 		})
+		runtime.enqueue(item)
 	}
 	return map[string]interface{}{
 		"status": "queued",
@@ -329,12 +336,98 @@ func mcpRuntimeRunBatch(input BatchInput) (map[string]interface{}, error) {
 	}, nil
 }
 
+// mcpRuntimeRunSession queues a session tool invocation in the shared runtime
+// so max_parallel, approval and on_mode_change apply to it, then blocks until
+// it finishes and returns the run ID and the raw CLI output.
+func mcpRuntimeRunSession(ctx context.Context, spec CmdSpec, adapter *CLIAdapter) (string, string, error) {
+	runtime, err := ensureMcpRuntime("")
+	if err != nil {
+		return "", "", err
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	report := progressReporterFromContext(ctx)
+	runID := newRunID()
+	opts := cliRunOptionsForSpec(spec)
+	opts.RunID = runID
+	opts.Env = lineageEnv(opts.Env, runID)
+	opts.Progress = report
+	requiresApproval := needsApproval(spec, runtime.cfg)
+	done := make(chan struct{})
+	item := &RunItem{
+		ID:              runID,
+		Status:          "queued",
+		Spec:            spec,
+		ModeHash:        computeModeHash(spec, ""),
+		RequireApproval: requiresApproval,
+		CreatedAt:       time.Now().UTC(),
+		requester:       callerSession(ctx),
+		session: &sessionRun{
+			ctx:     runCtx,
+			cancel:  cancel,
+			adapter: adapter,
+			opts:    opts,
+		},
+		done: done,
+	}
+	if requiresApproval {
+		item.Status = "awaiting_approval"
+	}
+	runtime.enqueue(item)
+	if report != nil && requiresApproval {
+		report(fmt.Sprintf("awaiting approval (run %s)", item.ID), 0, 0)
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		runtime.cancel(item.ID, false)
+		<-done
+		return runID, "", withErrorCode(errCodeCanceled, ctx.Err())
+	}
+
+	runtime.mu.Lock()
+	status := item.Status
+	output := item.session.output
+	runErr := item.session.err
+	errMsg := item.Error
+	code := item.errorCode()
+	runtime.mu.Unlock()
+
+	switch {
+	case status == "ok":
+		return runID, output, nil
+	case runErr != nil:
+		return runID, "", withErrorCode(code, runErr)
+	case errMsg != "":
+		return runID, "", withErrorCode(code, fmt.Errorf("run %s %s: %s", item.ID, status, errMsg))
+	default:
+		return runID, "", withErrorCode(code, fmt.Errorf("run %s %s", item.ID, status))
+	}
+}
+
 func (d *Runtime) enqueue(item *RunItem) {
 	d.mu.Lock()
 	_ = d.handleModeChange(item.ModeHash)
 	d.queue = append(d.queue, item)
+	awaiting := item.Status == "awaiting_approval"
 	d.mu.Unlock()
 	notifyRuntimeChanged()
+	if awaiting {
+		go d.requestApproval(item)
+	}
+	d.wake()
+}
+
+// wake asks the scheduler to run a tick now instead of waiting for the ticker.
+func (d *Runtime) wake() {
+	if d.wakeCh == nil {
+		return
+	}
+	select {
+	case d.wakeCh <- struct{}{}:
+	default:
+	}
 }
 
 func (d *Runtime) handleModeChange(newHash string) bool {
@@ -374,6 +467,7 @@ func (d *Runtime) cancelPendingLocked(reason string) bool {
 		item.Status = "canceled"
 		item.Error = reason
 		item.EndedAt = time.Now().UTC()
+		item.settleApproval()
 		d.appendCompletedLocked(item)
 	}
 	d.queue = []*RunItem{}
@@ -386,6 +480,10 @@ func (d *Runtime) cancelRunningLocked() bool {
 	}
 	for id, item := range d.running {
 		item.Error = "mode_changed"
+		if item.session != nil {
+			item.session.cancel()
+			continue
+		}
 		_, _ = cancelRun(id, false)
 	}
 	return true
@@ -397,6 +495,7 @@ func (d *Runtime) approve(runID string) bool {
 	for _, item := range d.queue {
 		if item.ID == runID && item.Status == "awaiting_approval" {
 			item.Status = "queued"
+			item.settleApproval()
 			changed = true
 			break
 		}
@@ -404,6 +503,7 @@ func (d *Runtime) approve(runID string) bool {
 	d.mu.Unlock()
 	if changed {
 		notifyRuntimeChanged()
+		d.wake()
 	}
 	return changed
 }
@@ -415,6 +515,7 @@ func (d *Runtime) reject(runID string) bool {
 		if item.ID == runID {
 			item.Status = "rejected"
 			item.EndedAt = time.Now().UTC()
+			item.settleApproval()
 			d.queue = append(d.queue[:i], d.queue[i+1:]...)
 			d.appendCompletedLocked(item)
 			changed = true
@@ -436,17 +537,25 @@ func (d *Runtime) cancel(runID string, force bool) string {
 		if item.ID == runID {
 			item.Status = "canceled"
 			item.EndedAt = time.Now().UTC()
+			item.settleApproval()
 			d.queue = append(d.queue[:i], d.queue[i+1:]...)
 			d.appendCompletedLocked(item)
+			record := runRecordForItem(item)
 			changed = true
 			d.mu.Unlock()
+			_ = appendRunRecord(record, item.Spec.LogPrompt)
 			if changed {
 				notifyRuntimeChanged()
 			}
 			return "canceled"
 		}
 	}
-	if _, ok := d.running[runID]; ok {
+	if item, ok := d.running[runID]; ok {
+		if item.session != nil {
+			item.session.cancel()
+			d.mu.Unlock()
+			return "cancelled"
+		}
 		cancelRunning = true
 	}
 	d.mu.Unlock()
@@ -470,7 +579,9 @@ func (d *Runtime) countStatus(status string) int {
 	return count
 }
 
-func (d *Runtime) findQueued(runID string) (map[string]interface{}, bool) {
+// findLocal returns queued items and in-process session items, which have no
+// async run directory to read status from.
+func (d *Runtime) findLocal(runID string) (map[string]interface{}, bool) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	for _, item := range d.queue {
@@ -478,6 +589,14 @@ func (d *Runtime) findQueued(runID string) (map[string]interface{}, bool) {
 			return item.view(), true
 		}
 	}
+	if item, ok := d.running[runID]; ok && item.session != nil {
+		return item.view(), true
+	}
+	for _, item := range d.completed {
+		if item.ID == runID && item.session != nil {
+			return item.view(), true
+		}
+	}
 	return nil, false
 }
 
@@ -508,20 +627,26 @@ func (d *Runtime) listRuns(status string, limit int) []map[string]interface{} {
 }
 
 func (d *Runtime) schedulerLoop() {
+	d.mu.Lock()
+	shutdownCh := d.shutdownCh
+	d.mu.Unlock()
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 	for {
 		select {
-		case <-d.shutdownCh:
+		case <-shutdownCh:
 			return
 		case <-ticker.C:
 			d.tick()
+		case <-d.wakeCh:
+			d.tick()
 		}
 	}
 }
 
 func (d *Runtime) tick() {
 	d.syncRunning()
+	d.expireApprovals()
 	for {
 		d.mu.Lock()
 		if len(d.running) >= d.cfg.MaxParallel {
@@ -529,6 +654,15 @@ func (d *Runtime) tick() {
 			return
 		}
 		next := d.popReadyLocked()
+		if next != nil && next.session != nil {
+			next.Status = "running"
+			next.StartedAt = time.Now().UTC()
+			d.running[next.ID] = next
+			d.mu.Unlock()
+			go d.runSession(next)
+			notifyRuntimeChanged()
+			continue
+		}
 		d.mu.Unlock()
 		if next == nil {
 			return
@@ -539,6 +673,7 @@ func (d *Runtime) tick() {
 		if err != nil {
 			next.Status = "error"
 			next.Error = err.Error()
+			next.ErrorCode = errorCode(err)
 			next.EndedAt = time.Now().UTC()
 			d.appendCompletedLocked(next)
 			changed = true
@@ -555,10 +690,82 @@ func (d *Runtime) tick() {
 	}
 }
 
+// runSession executes a session item through its adapter and records the result.
+func (d *Runtime) runSession(item *RunItem) {
+	run := item.session
+	var output string
+	var status, errMsg string
+	var exitCode int
+	gateStatus, err := gateRun(run.ctx, item.ID, item.Spec)
+	if err != nil {
+		status, exitCode, errMsg = gateStatus, 1, err.Error()
+		err = withErrorCode(runErrorCode(gateStatus, false, ""), err)
+	} else {
+		output, err = run.adapter.Run(run.ctx, run.opts)
+		status, exitCode, errMsg = statusFromErrorWithTimeout(run.ctx, err, false)
+		if errorCode(err) == errCodeDeadline {
+			status = "deadline"
+		}
+		if err == nil {
+			if err = run.adapter.checkJSONOutput(run.opts.Args, output); err != nil {
+				status, errMsg = "error", err.Error()
+			}
+		}
+	}
+
+	d.mu.Lock()
+	run.output = output
+	run.err = err
+	item.Status = status
+	item.ExitCode = exitCode
+	if errMsg != "" {
+		item.Error = errMsg
+	}
+	item.ErrorCode = errorCode(err)
+	item.EndedAt = time.Now().UTC()
+	delete(d.running, item.ID)
+	d.appendCompletedLocked(item)
+	record := runRecordForItem(item)
+	d.mu.Unlock()
+
+	_ = appendRunRecord(record, item.Spec.LogPrompt)
+	notifyRuntimeChanged()
+	d.wake()
+}
+
+// runRecordForItem builds the run-history entry for a finished local item.
+func runRecordForItem(item *RunItem) RunRecord {
+	record := RunRecord{
+		ID:         item.ID,
+		Agent:      item.Spec.Agent,
+		Role:       item.Spec.Role,
+		Model:      item.Spec.Model,
+		Cmd:        item.Spec.Cmd,
+		Args:       item.Spec.Args,
+		Status:     item.Status,
+		ExitCode:   item.ExitCode,
+		StartedAt:  formatTime(item.StartedAt),
+		EndedAt:    formatTime(item.EndedAt),
+		PromptHash: item.Spec.PromptHash,
+		PromptLen:  item.Spec.PromptLen,
+		Prompt:     item.Spec.Prompt,
+		Error:      item.Error,
+		ErrorCode:  item.errorCode(),
+		Hops:       item.Spec.Hops,
+	}
+	if !item.StartedAt.IsZero() {
+		record.DurationMs = item.EndedAt.Sub(item.StartedAt).Milliseconds()
+	}
+	return record
+}
+
 func (d *Runtime) syncRunning() {
 	d.mu.Lock()
 	ids := make([]string, 0, len(d.running))
-	for id := range d.running {
+	for id, item := range d.running {
+		if item.session != nil {
+			continue
+		}
 		ids = append(ids, id)
 	}
 	d.mu.Unlock()
@@ -580,9 +787,18 @@ func (d *Runtime) syncRunning() {
 			if errStr, ok := res["error"].(string); ok {
 				item.Error = errStr
 			}
+			if code, ok := res["error_code"].(string); ok {
+				item.ErrorCode = code
+			}
 			if exit, ok := res["exit_code"].(float64); ok {
 				item.ExitCode = int(exit)
 			}
+			// A fallback chain may have ended on another role or model.
+			if hops, ok := res["hops"].([]FallbackHop); ok {
+				item.Spec.Role, _ = res["role"].(string)
+				item.Spec.Model, _ = res["model"].(string)
+				item.Spec.Hops = hops
+			}
 			delete(d.running, id)
 			d.appendCompletedLocked(item)
 			changed = true
@@ -605,6 +821,10 @@ func (d *Runtime) popReadyLocked() *RunItem {
 }
 
 func (d *Runtime) appendCompletedLocked(item *RunItem) {
+	if item.done != nil {
+		close(item.done)
+		item.done = nil
+	}
 	d.completed = append(d.completed, item)
 	if len(d.completed) > maxCompletedRuns {
 		d.completed = d.completed[len(d.completed)-maxCompletedRuns:]
@@ -625,7 +845,25 @@ func (r *RunItem) view() map[string]interface{} {
 		"ended_at":          formatTime(r.EndedAt),
 		"exit_code":         r.ExitCode,
 		"error":             r.Error,
+		"error_code":        r.errorCode(),
+		"kind":              r.kind(),
+	}
+}
+
+// errorCode is the recorded code, or the one implied by the item's status
+// (canceled, rejected and expired items never ran).
+func (r *RunItem) errorCode() string {
+	if r.ErrorCode != "" {
+		return r.ErrorCode
+	}
+	return runErrorCode(r.Status, false, r.Error)
+}
+
+func (r *RunItem) kind() string {
+	if r.session != nil {
+		return "session"
 	}
+	return "async"
 }
 
 func formatTime(t time.Time) string {
@@ -713,6 +951,7 @@ func buildSpecForInput(input RunInput, configPath string) (CmdSpec, error) {
 	if input.IdleTimeoutMs > 0 {
 		spec.IdleTimeoutMs = input.IdleTimeoutMs
 	}
+	applyTimeout(&spec, input.TimeoutMs)
 	return spec, nil
 }
 
@@ -731,6 +970,7 @@ func buildBatchSpecs(input BatchInput, configPath string) ([]specEntry, error) {
 	}
 	defaults := normalizeDefaults(cfg.Defaults)
 	logPrompt := defaults.LogPrompt
+	deadline := batchDeadline(input.DeadlineMs)
 
 	tasks := []DelegatedTask{}
 	tasks = tasksFromRoles(splitList(input.Roles), input.Prompt)
@@ -783,6 +1023,7 @@ func buildBatchSpecs(input BatchInput, configPath string) ([]specEntry, error) {
 			if input.IdleTimeoutMs > 0 {
 				spec.IdleTimeoutMs = input.IdleTimeoutMs
 			}
+			applyBatchTimeouts(&spec, input.TimeoutMs, deadline)
 			results = append(results, specEntry{agent: role, spec: spec})
 		}
 	}
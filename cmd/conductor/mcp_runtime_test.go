@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 func writeTempConfig(t *testing.T, approvalRequired bool) string {
@@ -58,7 +62,7 @@ func TestRuntimeQueueApprovalFlow(t *testing.T) {
 	resetRuntime()
 	configPath := writeTempConfig(t, true)
 
-	payload, err := runAsyncTool(RunInput{Prompt: "hi", Role: "oracle", Config: configPath}, nil)
+	payload, err := runAsyncTool(context.Background(), RunInput{Prompt: "hi", Role: "oracle", Config: configPath}, nil)
 	if err != nil {
 		t.Fatalf("runAsyncTool: %v", err)
 	}
@@ -123,7 +127,7 @@ func TestRuntimeBatchQueued(t *testing.T) {
 	}
 	runtime.cfg.MaxParallel = 0
 
-	payload, err := runBatchAsyncTool(BatchInput{Prompt: "hi", Roles: "oracle,oracle", Config: configPath, RequireApproval: true}, nil)
+	payload, err := runBatchAsyncTool(context.Background(), BatchInput{Prompt: "hi", Roles: "oracle,oracle", Config: configPath, RequireApproval: true}, nil)
 	if err != nil {
 		t.Fatalf("runBatchAsyncTool: %v", err)
 	}
@@ -193,3 +197,170 @@ func TestReportRunLabel(t *testing.T) {
 		t.Fatalf("expected %q, got %q", want, got)
 	}
 }
+
+// installFakeCLI puts an executable shell script named name on PATH.
+func installFakeCLI(t *testing.T, name, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatalf("write fake cli: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// writeTestConfig writes content as the conductor.json of the test and gives
+// the test its own CONDUCTOR_HOME. It returns the config path.
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "conductor.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("CONDUCTOR_CONFIG", path)
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	return path
+}
+
+// connectTestClient serves every tool group to a client built with opts and
+// returns both ends of the connection. Resource updates reach the client.
+func connectTestClient(t *testing.T, opts mcp.ClientOptions) (*mcp.ServerSession, *mcp.ClientSession) {
+	t.Helper()
+	ctx := context.Background()
+	server := newMCPServer(map[string]bool{mcpGroupSession: true, mcpGroupRuntime: true})
+	t.Cleanup(trackServer(server))
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	t.Cleanup(func() { serverSession.Close() })
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "0.0.0"}, &opts)
+	clientSession, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { clientSession.Close() })
+	return serverSession, clientSession
+}
+
+func writeSessionRuntimeConfig(t *testing.T, approvalAgents string) {
+	t.Helper()
+	writeTestConfig(t, fmt.Sprintf(`{
+  "runtime": {
+    "max_parallel": 1,
+    "approval": { "agents": [%s] }
+  },
+  "roles": { "oracle": { "cli": "codex" } }
+}`, approvalAgents))
+}
+
+const fakeCodexSession = `echo '{"type":"thread.started","thread_id":"native-1"}'
+echo '{"type":"item.completed","item":{"type":"agent_message","text":"done"}}'
+`
+
+func TestSessionRunsThroughRuntime(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeSessionRuntimeConfig(t, "")
+	installFakeCLI(t, "codex", fakeCodexSession)
+
+	result, err := mcpRunSessionWithConfig(context.Background(), "codex", "", "", "hi", "hi", []string{"exec", "--json", "hi"}, 0, MCPSessionConfig{})
+	if err != nil {
+		t.Fatalf("mcpRunSessionWithConfig: %v", err)
+	}
+	if result.StructuredContent.ThreadID != "native-1" {
+		t.Fatalf("expected native thread id, got %v", result.StructuredContent.ThreadID)
+	}
+
+	runtime := mcpRuntimeSnapshot()
+	if runtime == nil {
+		t.Fatal("expected runtime to be started by session call")
+	}
+	runs := runtime.listRuns("ok", 0)
+	if len(runs) != 1 || runs[0]["kind"] != "session" || runs[0]["agent"] != "codex" {
+		t.Fatalf("expected one completed session run, got %v", runs)
+	}
+}
+
+func TestSessionRunWaitsForApproval(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeSessionRuntimeConfig(t, `"codex"`)
+	installFakeCLI(t, "codex", fakeCodexSession)
+
+	type outcome struct {
+		output string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		_, output, err := mcpRuntimeRunSession(context.Background(), CmdSpec{Agent: "codex", Cmd: "codex", Args: []string{"exec"}}, mcpCodexAdapter)
+		done <- outcome{output, err}
+	}()
+
+	var runID string
+	deadline := time.Now().Add(5 * time.Second)
+	for runID == "" && time.Now().Before(deadline) {
+		if runtime := mcpRuntimeSnapshot(); runtime != nil {
+			if runs := runtime.listRuns("awaiting_approval", 0); len(runs) > 0 {
+				runID, _ = runs[0]["run_id"].(string)
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if runID == "" {
+		t.Fatal("expected session run to await approval")
+	}
+	select {
+	case <-done:
+		t.Fatal("session run finished before approval")
+	default:
+	}
+
+	if _, err := approvalApproveTool(ApprovalInput{RunID: runID}); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatalf("session run: %v", res.err)
+		}
+		if res.output == "" {
+			t.Fatal("expected output")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("session run did not finish after approval")
+	}
+}
+
+func TestSessionRunRejected(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeSessionRuntimeConfig(t, `"codex"`)
+	installFakeCLI(t, "codex", fakeCodexSession)
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := mcpRuntimeRunSession(context.Background(), CmdSpec{Agent: "codex", Cmd: "codex"}, mcpCodexAdapter)
+		done <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if runtime := mcpRuntimeSnapshot(); runtime != nil {
+			if runs := runtime.listRuns("awaiting_approval", 0); len(runs) > 0 {
+				runtime.reject(runs[0]["run_id"].(string))
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected rejection error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("session run did not return after rejection")
+	}
+}
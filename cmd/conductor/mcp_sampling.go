@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// backendHostSampling marks answers produced by the MCP host's model
+	// instead of a delegate CLI.
+	backendHostSampling = "host-sampling"
+	samplingMaxTokens   = 4096
+)
+
+// errNoSampling is reported when the client that called the tool cannot
+// sample.
+var errNoSampling = errors.New("the calling client does not support sampling")
+
+// delegateUnavailable explains why the delegate CLI of a role could not run
+// when err says it is missing from PATH, not ready or not signed in. The
+// ready_cmd and auth probes only run then, never ahead of a call. It returns
+// "" for any other failure, which the host model would not fix.
+func delegateUnavailable(cli string, spec CmdSpec, err error) string {
+	switch errorCode(err) {
+	case errCodeMissingCLI:
+		return "missing CLI on PATH: " + spec.Cmd
+	case errCodeNotReady, errCodeAuth:
+	default:
+		return ""
+	}
+	if readyErr := checkReady(spec); readyErr != nil {
+		return readyErr.Error()
+	}
+	if status, detail := checkAuthForCLI(cli); status == "not_ready" || status == "missing" {
+		return fmt.Sprintf("%s auth %s: %s", cli, status, detail)
+	}
+	return err.Error()
+}
+
+// mcpSampleRole answers a role prompt with the model of the client whose tool
+// call ctx belongs to, using the role description as the system prompt.
+// unavailable is why the delegate CLI could not run; it is reported when the
+// client cannot sample either. No session is stored, so the result has an
+// empty threadId and cannot be continued with a *-reply tool.
+func mcpSampleRole(ctx context.Context, role string, roleCfg RoleConfig, model, prompt, unavailable string) (*SessionResult, error) {
+	ss := callerSession(ctx)
+	if caps := clientCapabilities(ss); caps == nil || caps.Sampling == nil {
+		return nil, fmt.Errorf("role %s: %s (%v)", role, unavailable, errNoSampling)
+	}
+	system := roleCfg.Description
+	if system == "" {
+		system = fmt.Sprintf("You are the %s role of a team of coding agents.", role)
+	}
+	params := &mcp.CreateMessageParams{
+		Messages:     []*mcp.SamplingMessage{{Role: "user", Content: &mcp.TextContent{Text: prompt}}},
+		SystemPrompt: system,
+		MaxTokens:    samplingMaxTokens,
+	}
+	if model != "" {
+		params.ModelPreferences = &mcp.ModelPreferences{Hints: []*mcp.ModelHint{{Name: model}}}
+	}
+	res, err := ss.CreateMessage(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("role %s: %s; host sampling failed: %w", role, unavailable, err)
+	}
+	text, ok := res.Content.(*mcp.TextContent)
+	if !ok {
+		return nil, fmt.Errorf("role %s: host sampling returned non-text content", role)
+	}
+	return &SessionResult{
+		Content: []TextBlock{{Type: "text", Text: text.Text}},
+		StructuredContent: SessionStructured{
+			Role:    role,
+			Model:   res.Model,
+			Backend: backendHostSampling,
+		},
+	}, nil
+}
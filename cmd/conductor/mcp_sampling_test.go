@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// samplingConfig has one codex role and no codex on PATH once the test sets
+// PATH to an empty directory.
+func samplingConfig(fallback bool) string {
+	return fmt.Sprintf(`{
+  "mcp": { "sampling_fallback": %t },
+  "roles": { "oracle": { "cli": "codex", "model": "o3", "description": "Deep reasoning reviewer." } }
+}`, fallback)
+}
+
+// sampleWithText answers sampling requests with text and records the last one.
+func sampleWithText(got **mcp.CreateMessageParams) mcp.ClientOptions {
+	return mcp.ClientOptions{
+		CreateMessageHandler: func(ctx context.Context, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+			*got = req.Params
+			return &mcp.CreateMessageResult{Content: &mcp.TextContent{Text: "sampled answer"}, Model: "host-model", Role: "assistant"}, nil
+		},
+	}
+}
+
+func TestRoleFallsBackToHostSampling(t *testing.T) {
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, samplingConfig(true))
+	t.Setenv("PATH", t.TempDir())
+	var got *mcp.CreateMessageParams
+	_, session := connectTestClient(t, sampleWithText(&got))
+
+	res, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "conductor", Arguments: map[string]any{"role": "oracle", "prompt": "review this"}})
+	if err != nil || res.IsError {
+		t.Fatalf("call conductor: %v %+v", err, res)
+	}
+	typed, err := toolResult[SessionResult](res.StructuredContent.(map[string]any))
+	if err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if typed.StructuredContent.Backend != backendHostSampling || typed.StructuredContent.Model != "host-model" || typed.Content[0].Text != "sampled answer" {
+		t.Fatalf("unexpected sampled result: %+v", typed)
+	}
+	if typed.StructuredContent.ThreadID != "" {
+		t.Fatalf("expected no threadId for a sampled answer, got %q", typed.StructuredContent.ThreadID)
+	}
+	if got == nil || got.SystemPrompt != "Deep reasoning reviewer." || got.ModelPreferences.Hints[0].Name != "o3" {
+		t.Fatalf("unexpected sampling request: %+v", got)
+	}
+	if text := got.Messages[0].Content.(*mcp.TextContent).Text; text != "review this" {
+		t.Fatalf("expected role prompt to be sampled, got %q", text)
+	}
+}
+
+func TestRoleWithoutSamplingFallbackErrors(t *testing.T) {
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, samplingConfig(false))
+	t.Setenv("PATH", t.TempDir())
+	var got *mcp.CreateMessageParams
+	connectTestClient(t, sampleWithText(&got))
+
+	_, err := mcpRunRoleSession(context.Background(), MCPConductorInput{Role: "oracle", Prompt: "review this"})
+	if err == nil || got != nil {
+		t.Fatalf("expected CLI error without sampling, got %v (sampled: %v)", err, got != nil)
+	}
+}
+
+func TestSamplingFallbackWithoutHostSupport(t *testing.T) {
+	writeTestConfig(t, samplingConfig(true))
+	_, err := mcpSampleRole(context.Background(), "oracle", RoleConfig{}, "", "hi", "missing CLI on PATH: codex")
+	if err == nil || !strings.Contains(err.Error(), "missing CLI on PATH: codex") || !strings.Contains(err.Error(), "sampling") {
+		t.Fatalf("expected both causes in error, got %v", err)
+	}
+}
+
+func TestSamplingFallbackDoesNotProbeWorkingCLI(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, samplingConfig(true))
+	calls := filepath.Join(t.TempDir(), "calls")
+	installFakeCLI(t, "codex", "echo \"$1\" >> "+calls+"\n"+fakeCodexSession)
+	var got *mcp.CreateMessageParams
+	_, session := connectTestClient(t, sampleWithText(&got))
+
+	res, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: "conductor", Arguments: map[string]any{"role": "oracle", "prompt": "review this"}})
+	if err != nil || res.IsError {
+		t.Fatalf("call conductor: %v %+v", err, res)
+	}
+	if got != nil {
+		t.Fatal("expected the working CLI to answer instead of the host")
+	}
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatalf("read calls: %v", err)
+	}
+	if lines := strings.Fields(string(data)); len(lines) != 1 || lines[0] != "exec" {
+		t.Fatalf("expected a single exec call and no auth probe, got %q", lines)
+	}
+}
@@ -3,63 +3,81 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-const (
-	// Session TTL - sessions expire after 1 hour of inactivity
-	mcpSessionTTL = 1 * time.Hour
-	// Cleanup interval - check for expired sessions every 10 minutes
-	mcpSessionCleanupInterval = 10 * time.Minute
-	// Max sessions to prevent memory exhaustion
-	mcpMaxSessions = 100
-)
-
-// Session management for multi-turn conversations (matches OpenAI Codex MCP pattern)
-var (
-	mcpSessionStore   = make(map[string]*MCPSession)
-	mcpSessionStoreMu sync.RWMutex
-)
-
 // MCPSession represents a conversation session
 // For Codex/Claude/Gemini: uses native session resume (no history re-transmission)
 type MCPSession struct {
-	ID             string           // Our session ID (maps to native thread/session ID)
-	NativeThreadID string           // Native CLI thread ID (for Codex: from structuredContent.threadId)
-	CLI            string           // codex, claude, gemini
-	Role           string           // role name if created via conductor tool
-	Model          string           // model used
-	Config         MCPSessionConfig // original session configuration
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	ID             string           `json:"id"`                     // Our session ID (maps to native thread/session ID)
+	NativeThreadID string           `json:"native_thread_id"`       // Native CLI thread ID (for Codex: from structuredContent.threadId)
+	CLI            string           `json:"cli"`                    // codex, claude, gemini
+	Role           string           `json:"role,omitempty"`         // role name if created via conductor tool
+	Model          string           `json:"model,omitempty"`        // model used
+	Config         MCPSessionConfig `json:"config"`                 // original session configuration
+	HandoffFrom    string           `json:"handoff_from,omitempty"` // thread this one was handed off from
+	HandoffTo      []string         `json:"handoff_to,omitempty"`   // threads this one was handed off to
+	LastStatus     string           `json:"last_status,omitempty"`  // outcome of the latest turn: ok, canceled, error
+	Turns          int              `json:"turns,omitempty"`        // transcript turns recorded so far
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
 }
 
 // MCPSessionConfig stores original session settings for reply
 type MCPSessionConfig struct {
+	// Model settings (flags fall back to the CLI defaults when empty)
+	Reasoning     string `json:"reasoning,omitempty"`
+	ModelFlag     string `json:"model_flag,omitempty"`
+	ReasoningFlag string `json:"reasoning_flag,omitempty"`
+	ReasoningKey  string `json:"reasoning_key,omitempty"`
 	// Codex settings
-	ApprovalPolicy string
-	Sandbox        string
-	Cwd            string
-	Profile        string
+	ApprovalPolicy  string   `json:"approval_policy,omitempty"`
+	Sandbox         string   `json:"sandbox,omitempty"`
+	Cwd             string   `json:"cwd,omitempty"`
+	Profile         string   `json:"profile,omitempty"`
+	ConfigOverrides []string `json:"config_overrides,omitempty"` // -c key=value pairs
 	// Claude settings
-	PermissionMode     string
-	AllowedTools       string
-	DisallowedTools    string
-	SystemPrompt       string
-	AppendSystemPrompt string
+	PermissionMode     string `json:"permission_mode,omitempty"`
+	AllowedTools       string `json:"allowed_tools,omitempty"`
+	DisallowedTools    string `json:"disallowed_tools,omitempty"`
+	SystemPrompt       string `json:"system_prompt,omitempty"`
+	AppendSystemPrompt string `json:"append_system_prompt,omitempty"`
+	MaxTurns           int    `json:"max_turns,omitempty"`
+	AddDir             string `json:"add_dir,omitempty"`
+	McpConfig          string `json:"mcp_config,omitempty"`
+	Agents             string `json:"agents,omitempty"`
 	// Gemini settings
-	Yolo               bool
-	ApprovalMode       string
-	IncludeDirectories string
+	Yolo               bool   `json:"yolo,omitempty"`
+	ApprovalMode       string `json:"approval_mode,omitempty"`
+	IncludeDirectories string `json:"include_directories,omitempty"`
+	// Process settings carried over from the role config (Cwd above doubles as
+	// the working directory)
+	Env               map[string]string `json:"env,omitempty"`
+	IdleTimeoutMs     int               `json:"idle_timeout_ms,omitempty"`
+	TimeoutMs         int               `json:"timeout_ms,omitempty"`
+	KillGraceMs       int               `json:"kill_grace_ms,omitempty"`
+	Retry             int               `json:"retry,omitempty"`
+	RetryBackoffMs    int               `json:"retry_backoff_ms,omitempty"`
+	RetryOn           []string          `json:"retry_on,omitempty"`
+	RetryMaxBackoffMs int               `json:"retry_max_backoff_ms,omitempty"`
+	ReadyCmd          string            `json:"ready_cmd,omitempty"`
+	ReadyArgs         []string          `json:"ready_args,omitempty"`
+	ReadyTimeoutMs    int               `json:"ready_timeout_ms,omitempty"`
+	// RoleArgs are the other flags of a role's custom args, replayed on replies
+	RoleArgs []string `json:"role_args,omitempty"`
 }
 
 // MCPMessage represents a message in a session
@@ -89,6 +107,7 @@ type MCPCodexInput struct {
 	Profile          string                 `json:"profile,omitempty"`
 	Sandbox          string                 `json:"sandbox,omitempty"`
 	IdleTimeoutMs    int                    `json:"idle_timeout_ms,omitempty"`
+	TimeoutMs        int                    `json:"timeout_ms,omitempty"`
 	MemoryKey        string                 `json:"memory_key,omitempty"`
 	MemoryMode       string                 `json:"memory_mode,omitempty"`
 	// Reasoning effort for o-series models (low, medium, high)
@@ -111,6 +130,7 @@ type MCPClaudeInput struct {
 	Agents             string `json:"agents,omitempty"`
 	Debug              bool   `json:"debug,omitempty"`
 	IdleTimeoutMs      int    `json:"idle_timeout_ms,omitempty"`
+	TimeoutMs          int    `json:"timeout_ms,omitempty"`
 	MemoryKey          string `json:"memory_key,omitempty"`
 	MemoryMode         string `json:"memory_mode,omitempty"`
 }
@@ -126,6 +146,7 @@ type MCPGeminiInput struct {
 	Cwd                string `json:"cwd,omitempty"`
 	Debug              bool   `json:"debug,omitempty"`
 	IdleTimeoutMs      int    `json:"idle_timeout_ms,omitempty"`
+	TimeoutMs          int    `json:"timeout_ms,omitempty"`
 	MemoryKey          string `json:"memory_key,omitempty"`
 	MemoryMode         string `json:"memory_mode,omitempty"`
 }
@@ -135,6 +156,7 @@ type MCPReplyInput struct {
 	Prompt         string `json:"prompt"`
 	ThreadID       string `json:"threadId"`
 	ConversationID string `json:"conversationId,omitempty"` // deprecated alias
+	Role           string `json:"role,omitempty"`           // resume the latest session for this role when threadId is empty
 	MemoryKey      string `json:"memory_key,omitempty"`
 	MemoryMode     string `json:"memory_mode,omitempty"`
 }
@@ -144,8 +166,21 @@ type MCPConductorInput struct {
 	Prompt        string `json:"prompt"`
 	Role          string `json:"role"`
 	IdleTimeoutMs int    `json:"idle_timeout_ms,omitempty"`
+	TimeoutMs     int    `json:"timeout_ms,omitempty"`
 	MemoryKey     string `json:"memory_key,omitempty"`
 	MemoryMode    string `json:"memory_mode,omitempty"`
+	// handoffFrom is set by handoff: the role session runs in the source
+	// thread's directory unless the role has its own, and may not be more
+	// permissive than the source.
+	handoffFrom *MCPSession
+}
+
+// MCPSessionsInput for the sessions management tool
+type MCPSessionsInput struct {
+	Action   string `json:"action,omitempty"`
+	ThreadID string `json:"threadId,omitempty"`
+	CLI      string `json:"cli,omitempty"`
+	Role     string `json:"role,omitempty"`
 }
 
 type MCPMemoryInput struct {
@@ -155,21 +190,155 @@ type MCPMemoryInput struct {
 	Separator string `json:"separator,omitempty"`
 }
 
+// MCP tool groups selectable with `conductor mcp --groups` or mcp.groups in conductor.json.
+const (
+	mcpGroupSession = "session" // codex/claude/gemini/conductor (+ replies), memory, status
+	mcpGroupRuntime = "runtime" // conductor.run*, queue, approval tools and the queue resource
+)
+
+var mcpToolGroups = []string{mcpGroupSession, mcpGroupRuntime}
+
 func runMCPServer(args []string) int {
-	_ = args
+	fs := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	groupsFlag := fs.String("groups", "", "comma-separated tool groups (session,runtime)")
+	listenFlag := fs.String("listen", "", "serve streamable HTTP on host:port instead of stdio")
+	socketFlag := fs.String("socket", "", "serve streamable HTTP on a unix socket instead of stdio")
+	toolsFlag := fs.String("tools", "", "comma-separated tool names or patterns to expose")
+	bundlesFlag := fs.String("bundles", "", "comma-separated mcp-bundles.json bundles to proxy")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid flags.")
+		return 1
+	}
+
+	cfg, err := loadConfigOrEmpty(resolveConfigPath(""))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Config error:", err.Error())
+		return 1
+	}
+	groups, err := resolveMCPGroups(*groupsFlag, cfg.MCP)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	listen, err := resolveMCPListen(*listenFlag, *socketFlag, cfg.MCP)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	allowlist, err := resolveMCPAllowlist(*toolsFlag, cfg.MCP)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	policy, err := resolveMCPPolicy(cfg.Policy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Config error:", err.Error())
+		return 1
+	}
+	bundleServers, err := resolveMCPBundleServers(*bundlesFlag, cfg.MCP)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	configureMCPSessions(cfg.MCP)
+	loadMCPSessions()
 
 	// Start session cleanup goroutine
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go mcpSessionCleanupLoop(ctx)
 
+	mcpCommandsDir = resolveMCPCommandsDir(cfg.MCP)
+	mcpActiveAllowlist = allowlist
+	mcpPolicy = policy
+	server := newMCPServer(groups)
+	startBundleProxies(ctx, server, bundleServers)
+	defer stopBundleProxies()
+	defer trackServer(server)()
+
+	if listen.Addr != "" || listen.Socket != "" {
+		sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := serveMCPHTTP(sigCtx, server, listen); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		return 0
+	}
+
+	transport := mcp.NewStdioTransport()
+	session, err := server.Connect(context.Background(), transport, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	if err := session.Wait(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
+// resolveMCPGroups picks the enabled tool groups. The --groups flag wins over
+// config; when neither is set every group is enabled.
+func resolveMCPGroups(flagValue string, cfg MCPConfig) (map[string]bool, error) {
+	names := splitList(flagValue)
+	if len(names) == 0 {
+		names = cfg.Groups
+	}
+	if len(names) == 0 {
+		names = mcpToolGroups
+	}
+	groups := map[string]bool{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if indexOf(mcpToolGroups, name) < 0 {
+			return nil, fmt.Errorf("unknown MCP tool group: %s (available: %s)", name, strings.Join(mcpToolGroups, ", "))
+		}
+		groups[name] = true
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("no MCP tool groups enabled")
+	}
+	return groups, nil
+}
+
+// newMCPServer builds the unified MCP server with the selected tool groups.
+func newMCPServer(groups map[string]bool) *mcp.Server {
+	opts := &mcp.ServerOptions{
+		HasPrompts:         true,
+		HasResources:       true,
+		SubscribeHandler:   conductorSubscribeHandler,
+		UnsubscribeHandler: conductorUnsubscribeHandler,
+	}
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "conductor-mcp-server",
 		Version: "1.0.0",
-	}, nil)
+	}, opts)
+	server.AddReceivingMiddleware(allowlistMiddleware, errorCodeMiddleware, callerSessionMiddleware)
+
+	if groups[mcpGroupSession] {
+		registerSessionTools(server)
+	}
+	if groups[mcpGroupRuntime] {
+		registerRuntimeTools(server)
+	}
+	registerConductorResources(server)
+	registerCommandPrompts(server, mcpCommandsDir)
+	return server
+}
 
+// registerSessionTools adds the codex/claude/gemini/conductor session tools,
+// their *-reply counterparts, memory and status.
+func registerSessionTools(server *mcp.Server) {
 	// ===== Codex Tools =====
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name: "codex",
 		Description: `Run a Codex session. Returns structuredContent.threadId for continuation.
 
@@ -186,7 +355,8 @@ Parameters:
 - reasoning-effort: Reasoning effort for o-series models ("low", "medium", "high")
 - memory_key: Shared memory key to inject
 - memory_mode: "prepend" (default) or "append"`,
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPCodexInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPCodexInput) (*mcp.CallToolResult, *SessionResult, error) {
+		ctx = withProgressReporter(ctx, progressReporterForRequest(ctx, req))
 		if err := ValidatePrompt(input.Prompt); err != nil {
 			return nil, nil, err
 		}
@@ -200,19 +370,31 @@ Parameters:
 		}
 		prompt = applySharedMemory(prompt)
 		config := MCPSessionConfig{
-			ApprovalPolicy: input.ApprovalPolicy,
-			Sandbox:        input.Sandbox,
-			Cwd:            input.Cwd,
-			Profile:        input.Profile,
+			Reasoning:       input.ReasoningEffort,
+			ApprovalPolicy:  input.ApprovalPolicy,
+			Sandbox:         input.Sandbox,
+			Cwd:             input.Cwd,
+			Profile:         input.Profile,
+			ConfigOverrides: mcpCodexConfigOverrides(input),
+			TimeoutMs:       input.TimeoutMs,
 		}
-		result, err := mcpRunSessionWithConfig(ctx, "codex", "", input.Model, prompt, mcpBuildCodexArgs(input), input.IdleTimeoutMs, config)
+		// Store what the policy allows, so replies replay the same settings.
+		if err := enforceSessionPolicy("codex", "", &config); err != nil {
+			return nil, nil, err
+		}
+		input.Sandbox, input.ApprovalPolicy = config.Sandbox, config.ApprovalPolicy
+		args, err := mcpBuildCodexArgs(input)
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := mcpRunSessionWithConfig(ctx, "codex", "", input.Model, input.Prompt, prompt, args, input.IdleTimeoutMs, config)
 		if err != nil {
 			return nil, nil, err
 		}
 		return nil, result, nil
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name: "codex-reply",
 		Description: `Continue a Codex session.
 
@@ -221,8 +403,9 @@ Parameters:
 - threadId (required): Thread ID from previous response
 - memory_key: Shared memory key to inject
 - memory_mode: "prepend" (default) or "append"`,
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPReplyInput) (*mcp.CallToolResult, map[string]interface{}, error) {
-		result, err := mcpRunReply(ctx, input)
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPReplyInput) (*mcp.CallToolResult, *SessionResult, error) {
+		ctx = withProgressReporter(ctx, progressReporterForRequest(ctx, req))
+		result, err := mcpRunReply(ctx, "codex", input)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -230,7 +413,7 @@ Parameters:
 	})
 
 	// ===== Claude Tools =====
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name: "claude",
 		Description: `Run a Claude Code session. Returns structuredContent.threadId for continuation.
 
@@ -250,7 +433,8 @@ Parameters:
 - debug: Enable debug mode
 - memory_key: Shared memory key to inject
 - memory_mode: "prepend" (default) or "append"`,
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPClaudeInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPClaudeInput) (*mcp.CallToolResult, *SessionResult, error) {
+		ctx = withProgressReporter(ctx, progressReporterForRequest(ctx, req))
 		if err := ValidatePrompt(input.Prompt); err != nil {
 			return nil, nil, err
 		}
@@ -264,20 +448,34 @@ Parameters:
 		}
 		prompt = applySharedMemory(prompt)
 		config := MCPSessionConfig{
-			PermissionMode:     input.PermissionMode,
+			Cwd:                input.Cwd,
+			PermissionMode:     mcpClaudePermissionMode(input.PermissionMode),
 			AllowedTools:       input.AllowedTools,
 			DisallowedTools:    input.DisallowedTools,
 			SystemPrompt:       input.SystemPrompt,
 			AppendSystemPrompt: input.AppendSystemPrompt,
+			MaxTurns:           input.MaxTurns,
+			AddDir:             input.AddDir,
+			McpConfig:          input.McpConfig,
+			Agents:             input.Agents,
+			TimeoutMs:          input.TimeoutMs,
+		}
+		if err := enforceSessionPolicy("claude", "", &config); err != nil {
+			return nil, nil, err
 		}
-		result, err := mcpRunSessionWithConfig(ctx, "claude", "", input.Model, prompt, mcpBuildClaudeArgs(input), input.IdleTimeoutMs, config)
+		input.PermissionMode = config.PermissionMode
+		args, err := mcpBuildClaudeArgs(input)
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := mcpRunSessionWithConfig(ctx, "claude", "", input.Model, input.Prompt, prompt, args, input.IdleTimeoutMs, config)
 		if err != nil {
 			return nil, nil, err
 		}
 		return nil, result, nil
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name: "claude-reply",
 		Description: `Continue a Claude session.
 
@@ -286,8 +484,9 @@ Parameters:
 - threadId (required): Thread ID from previous response
 - memory_key: Shared memory key to inject
 - memory_mode: "prepend" (default) or "append"`,
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPReplyInput) (*mcp.CallToolResult, map[string]interface{}, error) {
-		result, err := mcpRunReply(ctx, input)
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPReplyInput) (*mcp.CallToolResult, *SessionResult, error) {
+		ctx = withProgressReporter(ctx, progressReporterForRequest(ctx, req))
+		result, err := mcpRunReply(ctx, "claude", input)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -295,7 +494,7 @@ Parameters:
 	})
 
 	// ===== Gemini Tools =====
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name: "gemini",
 		Description: `Run a Gemini session. Returns structuredContent.threadId for continuation.
 
@@ -310,7 +509,8 @@ Parameters:
 - debug: Enable debug mode
 - memory_key: Shared memory key to inject
 - memory_mode: "prepend" (default) or "append"`,
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPGeminiInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPGeminiInput) (*mcp.CallToolResult, *SessionResult, error) {
+		ctx = withProgressReporter(ctx, progressReporterForRequest(ctx, req))
 		if err := ValidatePrompt(input.Prompt); err != nil {
 			return nil, nil, err
 		}
@@ -329,15 +529,24 @@ Parameters:
 			ApprovalMode:       input.ApprovalMode,
 			IncludeDirectories: input.IncludeDirectories,
 			Cwd:                input.Cwd,
+			TimeoutMs:          input.TimeoutMs,
+		}
+		if err := enforceSessionPolicy("gemini", "", &config); err != nil {
+			return nil, nil, err
+		}
+		input.Yolo, input.ApprovalMode = config.Yolo, config.ApprovalMode
+		args, err := mcpBuildGeminiArgs(input)
+		if err != nil {
+			return nil, nil, err
 		}
-		result, err := mcpRunSessionWithConfig(ctx, "gemini", "", input.Model, prompt, mcpBuildGeminiArgs(input), input.IdleTimeoutMs, config)
+		result, err := mcpRunSessionWithConfig(ctx, "gemini", "", input.Model, input.Prompt, prompt, args, input.IdleTimeoutMs, config)
 		if err != nil {
 			return nil, nil, err
 		}
 		return nil, result, nil
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name: "gemini-reply",
 		Description: `Continue a Gemini session.
 
@@ -346,8 +555,9 @@ Parameters:
 - threadId (required): Thread ID from previous response
 - memory_key: Shared memory key to inject
 - memory_mode: "prepend" (default) or "append"`,
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPReplyInput) (*mcp.CallToolResult, map[string]interface{}, error) {
-		result, err := mcpRunReply(ctx, input)
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPReplyInput) (*mcp.CallToolResult, *SessionResult, error) {
+		ctx = withProgressReporter(ctx, progressReporterForRequest(ctx, req))
+		result, err := mcpRunReply(ctx, "gemini", input)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -355,7 +565,7 @@ Parameters:
 	})
 
 	// ===== Conductor Role-based Routing =====
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name: "conductor",
 		Description: `Run a session with role-based CLI routing. Uses conductor.json to map roles to CLIs.
 
@@ -365,21 +575,15 @@ Parameters:
 - memory_key: Shared memory key to inject
 - memory_mode: "prepend" (default) or "append"
 
-Available roles are defined in ~/.conductor-kit/conductor.json`,
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPConductorInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+Available roles are defined in ~/.conductor-kit/conductor.json. With mcp.sampling_fallback, a role whose CLI is missing or not ready is answered by the host model (structuredContent.backend: "host-sampling", no threadId to reply to).`,
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPConductorInput) (*mcp.CallToolResult, *SessionResult, error) {
+		ctx = withProgressReporter(ctx, progressReporterForRequest(ctx, req))
 		if err := ValidatePrompt(input.Prompt); err != nil {
 			return nil, nil, err
 		}
 		if input.Role == "" {
 			return nil, nil, fmt.Errorf("role is required")
 		}
-		if input.MemoryKey != "" {
-			prompt, err := applyMemoryToPrompt(input.Prompt, input.MemoryKey, input.MemoryMode)
-			if err != nil {
-				return nil, nil, err
-			}
-			input.Prompt = prompt
-		}
 		result, err := mcpRunRoleSession(ctx, input)
 		if err != nil {
 			return nil, nil, err
@@ -387,25 +591,89 @@ Available roles are defined in ~/.conductor-kit/conductor.json`,
 		return nil, result, nil
 	})
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name: "conductor-reply",
 		Description: `Continue a conductor session.
 
 Parameters:
 - prompt (required): The next user prompt
-- threadId (required): Thread ID from previous response
+- threadId: Thread ID from previous response
+- role: Continue the most recent session for this role when threadId is omitted
 - memory_key: Shared memory key to inject
 - memory_mode: "prepend" (default) or "append"`,
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPReplyInput) (*mcp.CallToolResult, map[string]interface{}, error) {
-		result, err := mcpRunReply(ctx, input)
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPReplyInput) (*mcp.CallToolResult, *SessionResult, error) {
+		ctx = withProgressReporter(ctx, progressReporterForRequest(ctx, req))
+		result, err := mcpRunReply(ctx, "", input)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, result, nil
+	})
+
+	// ===== Session Management Tool =====
+	addTool(server, &mcp.Tool{
+		Name: "sessions",
+		Description: `List, inspect and clean up stored sessions (threadIds).
+
+Actions:
+- list (default): list sessions, newest first; filter with cli and/or role
+- inspect: show one session (native thread ID, config, timestamps) by threadId
+- last: most recent session for role and/or cli (use its threadId with *-reply)
+- delete: remove a session by threadId
+- expire: expire threadId, or every session past its TTL when threadId is omitted`,
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPSessionsInput) (*mcp.CallToolResult, *SessionsResult, error) {
+		payload, err := mcpHandleSessions(input)
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := toolResult[SessionsResult](payload)
+		return nil, result, err
+	})
+
+	// ===== Handoff Tool =====
+	addTool(server, &mcp.Tool{
+		Name: "handoff",
+		Description: `Continue a thread on another CLI or role.
+
+Builds a condensed context from the thread's transcript (or the role's shared memory),
+starts a new native session on the target and links both threads (handoff_from/handoff_to).
+Returns the new structuredContent.threadId.
+
+Parameters:
+- threadId (required): Thread to hand off
+- cli: Target CLI ("codex", "claude", "gemini")
+- role: Target role from conductor.json (use instead of cli); refused if the role is more permissive than the thread
+- prompt: Instruction for the target (default: continue the conversation)`,
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPHandoffInput) (*mcp.CallToolResult, *SessionResult, error) {
+		ctx = withProgressReporter(ctx, progressReporterForRequest(ctx, req))
+		result, err := mcpRunHandoff(ctx, input)
 		if err != nil {
 			return nil, nil, err
 		}
 		return nil, result, nil
 	})
 
+	// ===== Transcript Tool =====
+	addTool(server, &mcp.Tool{
+		Name: "transcript",
+		Description: `Export the recorded transcript of a thread (requires mcp.transcripts in conductor.json).
+
+Each turn has the prompt, injected shared memory, extracted response text and timestamps.
+
+Parameters:
+- threadId (required): Thread ID to export
+- format: "markdown" (default) or "json"`,
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPTranscriptInput) (*mcp.CallToolResult, *TranscriptResult, error) {
+		payload, err := mcpExportTranscript(input)
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := toolResult[TranscriptResult](payload)
+		return nil, result, err
+	})
+
 	// ===== Shared Memory Tool =====
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name: "memory",
 		Description: `Manage shared memory for this MCP server.
 
@@ -418,86 +686,29 @@ Actions:
 - list: list keys
 - clear: delete a key
 - clear_all: clear everything`,
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPMemoryInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input MCPMemoryInput) (*mcp.CallToolResult, *MemoryResult, error) {
 		payload, err := mcpHandleMemory(input)
 		if err != nil {
 			return nil, nil, err
 		}
-		return nil, payload, nil
+		result, err := toolResult[MemoryResult](payload)
+		return nil, result, err
 	})
 
 	// ===== Status Tool =====
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name: "status",
 		Description: `Check CLI availability and session status.
 
 Returns:
 - cli: availability status for codex, claude, gemini
+- allowlist: tools and roles this server exposes ("*" when unrestricted)
+- bundles: proxied bundle servers with their status, tools and restarts
 - sessions: active session count and info`,
-	}, func(ctx context.Context, req *mcp.CallToolRequest, input struct{}) (*mcp.CallToolResult, map[string]interface{}, error) {
-		return nil, mcpGetStatus(), nil
+	}, func(ctx context.Context, req *mcp.CallToolRequest, input struct{}) (*mcp.CallToolResult, *StatusResult, error) {
+		result, err := toolResult[StatusResult](mcpGetStatus())
+		return nil, result, err
 	})
-
-	transport := mcp.NewStdioTransport()
-	session, err := server.Connect(context.Background(), transport, nil)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		return 1
-	}
-	if err := session.Wait(); err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		return 1
-	}
-	return 0
-}
-
-// mcpSessionCleanupLoop periodically removes expired sessions
-func mcpSessionCleanupLoop(ctx context.Context) {
-	ticker := time.NewTicker(mcpSessionCleanupInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			mcpCleanupExpiredSessions()
-		}
-	}
-}
-
-// mcpCleanupExpiredSessions removes sessions that have exceeded TTL
-func mcpCleanupExpiredSessions() {
-	now := time.Now()
-	mcpSessionStoreMu.Lock()
-	defer mcpSessionStoreMu.Unlock()
-
-	for id, sess := range mcpSessionStore {
-		if now.Sub(sess.UpdatedAt) > mcpSessionTTL {
-			delete(mcpSessionStore, id)
-		}
-	}
-}
-
-// mcpEvictOldestSession removes the oldest session if at capacity
-func mcpEvictOldestSession() {
-	if len(mcpSessionStore) < mcpMaxSessions {
-		return
-	}
-
-	var oldestID string
-	var oldestTime time.Time
-
-	for id, sess := range mcpSessionStore {
-		if oldestID == "" || sess.UpdatedAt.Before(oldestTime) {
-			oldestID = id
-			oldestTime = sess.UpdatedAt
-		}
-	}
-
-	if oldestID != "" {
-		delete(mcpSessionStore, oldestID)
-	}
 }
 
 // mcpRunQuickCommand runs a simple command with timeout and returns output
@@ -680,49 +891,46 @@ func mcpGetStatus() map[string]interface{} {
 	}
 
 	mcpSessionStoreMu.RLock()
-	sessionCount := len(mcpSessionStore)
-	sessions := make([]map[string]interface{}, 0, sessionCount)
-	for _, sess := range mcpSessionStore {
-		sessions = append(sessions, map[string]interface{}{
-			"threadId":       sess.ID,
-			"nativeThreadId": sess.NativeThreadID,
-			"cli":            sess.CLI,
-			"role":           sess.Role,
-			"model":          sess.Model,
-			"createdAt":      sess.CreatedAt.Format(time.RFC3339),
-			"updatedAt":      sess.UpdatedAt.Format(time.RFC3339),
-		})
-	}
+	maxSessions, sessionTTL := mcpMaxSessions, mcpSessionTTL
 	mcpSessionStoreMu.RUnlock()
+	sessions := mcpSessionListPayload(listMCPSessions("", ""))
+	sessionCount := len(sessions)
 
 	return map[string]interface{}{
-		"cli": clis,
+		"cli":       clis,
+		"allowlist": allowlistStatus(),
+		"bundles":   bundleProxyStatus(),
 		"sessions": map[string]interface{}{
 			"count":  sessionCount,
-			"max":    mcpMaxSessions,
-			"ttl":    mcpSessionTTL.String(),
+			"max":    maxSessions,
+			"ttl":    sessionTTL.String(),
 			"active": sessions,
 		},
 	}
 }
 
 // mcpRunSession runs a new CLI session and creates a thread
-func mcpRunSession(ctx context.Context, cli, prompt string, args []string, idleTimeoutMs int) (map[string]interface{}, error) {
-	return mcpRunSessionWithConfig(ctx, cli, "", "", prompt, args, idleTimeoutMs, MCPSessionConfig{})
+func mcpRunSession(ctx context.Context, cli, prompt string, args []string, idleTimeoutMs int) (*SessionResult, error) {
+	return mcpRunSessionWithConfig(ctx, cli, "", "", prompt, prompt, args, idleTimeoutMs, MCPSessionConfig{})
 }
 
 // mcpRunSessionWithConfig runs a new CLI session with full configuration
 // Uses native session/resume support - no history re-transmission needed
-func mcpRunSessionWithConfig(ctx context.Context, cli, role, model, prompt string, args []string, idleTimeoutMs int, config MCPSessionConfig) (map[string]interface{}, error) {
+// userPrompt is the prompt as the caller wrote it; prompt is what was sent
+// after memory injection (already embedded in args).
+func mcpRunSessionWithConfig(ctx context.Context, cli, role, model, userPrompt, prompt string, args []string, idleTimeoutMs int, config MCPSessionConfig) (*SessionResult, error) {
 	adapter := mcpGetAdapter(cli)
 	if adapter == nil {
 		return nil, fmt.Errorf("unknown CLI: %s", cli)
 	}
 
-	output, err := adapter.Run(ctx, CLIRunOptions{
-		Args:          args,
-		IdleTimeoutMs: idleTimeoutMs,
-	})
+	if config.IdleTimeoutMs == 0 {
+		config.IdleTimeoutMs = idleTimeoutMs
+	}
+	spec := mcpSessionSpec(adapter, role, model, prompt, args, idleTimeoutMs)
+	applySessionProcessConfig(&spec, config)
+	startedAt := time.Now()
+	_, output, err := mcpRuntimeRunSession(ctx, spec, adapter)
 	if err != nil {
 		return nil, err
 	}
@@ -744,24 +952,25 @@ func mcpRunSessionWithConfig(ctx context.Context, cli, role, model, prompt strin
 		Role:           role,
 		Model:          model,
 		Config:         config,
+		LastStatus:     "ok",
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
 
-	mcpSessionStoreMu.Lock()
-	mcpEvictOldestSession()
-	mcpSessionStore[threadID] = sess
-	mcpSessionStoreMu.Unlock()
+	mcpStoreSession(sess)
 
 	// Extract text content for response
 	textContent := mcpExtractTextContent(cli, output)
+	recordTranscriptTurn(sess, userPrompt, prompt, textContent, startedAt)
 	rememberSharedMemory(cli, role, textContent)
 	return mcpBuildResponseWithMeta(textContent, threadID, cli, role, model), nil
 }
 
 // mcpRunReply continues an existing session using native CLI resume
 // NO HISTORY RE-TRANSMISSION - uses native session/resume support
-func mcpRunReply(ctx context.Context, input MCPReplyInput) (map[string]interface{}, error) {
+// cli is the CLI of the reply tool, or "" for conductor-reply, which
+// continues any session.
+func mcpRunReply(ctx context.Context, cli string, input MCPReplyInput) (*SessionResult, error) {
 	if err := ValidatePrompt(input.Prompt); err != nil {
 		return nil, err
 	}
@@ -780,16 +989,34 @@ func mcpRunReply(ctx context.Context, input MCPReplyInput) (map[string]interface
 	if threadID == "" {
 		threadID = input.ConversationID // deprecated fallback
 	}
+	var sess *MCPSession
 	if threadID == "" {
-		return nil, fmt.Errorf("threadId is required")
+		if input.Role == "" {
+			return nil, fmt.Errorf("threadId is required")
+		}
+		last, ok := mcpLastSession("", input.Role)
+		if !ok {
+			return nil, fmt.Errorf("no session found for role %s", input.Role)
+		}
+		sess, threadID = last, last.ID
+	} else {
+		found, exists := mcpLookupSession(threadID)
+		if !exists {
+			return nil, fmt.Errorf("thread not found: %s", threadID)
+		}
+		sess = found
 	}
-
-	mcpSessionStoreMu.RLock()
-	sess, exists := mcpSessionStore[threadID]
-	mcpSessionStoreMu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("thread not found: %s", threadID)
+	if cli != "" && sess.CLI != cli {
+		return nil, fmt.Errorf("thread %s is a %s session; continue it with %s-reply", threadID, sess.CLI, sess.CLI)
+	}
+	// A role session is governed by the role allowlist, a direct one by the
+	// tool that started it.
+	if sess.Role != "" {
+		if err := checkRoleAllowed(sess.Role); err != nil {
+			return nil, err
+		}
+	} else if err := checkToolAllowed(sess.CLI); err != nil {
+		return nil, err
 	}
 
 	adapter := mcpGetAdapter(sess.CLI)
@@ -798,28 +1025,38 @@ func mcpRunReply(ctx context.Context, input MCPReplyInput) (map[string]interface
 	}
 
 	// Build args using native resume - NO history re-transmission
-	args := mcpBuildResumeArgs(sess.CLI, sess.NativeThreadID, prompt, sess.Config)
+	args, err := mcpBuildResumeArgsWithModel(sess.CLI, sess.Role, sess.NativeThreadID, prompt, sess.Model, sess.Config)
+	if err != nil {
+		return nil, err
+	}
 
-	output, err := adapter.Run(ctx, CLIRunOptions{
-		Args:          args,
-		IdleTimeoutMs: defaultCLIIdleTimeoutMs,
-	})
+	spec := mcpSessionSpec(adapter, sess.Role, sess.Model, prompt, args, effectiveInt(sess.Config.IdleTimeoutMs, defaultCLIIdleTimeoutMs))
+	applySessionProcessConfig(&spec, sess.Config)
+	startedAt := time.Now()
+	_, output, err := mcpRuntimeRunSession(ctx, spec, adapter)
 	if err != nil {
+		status := "error"
+		if ctx.Err() != nil {
+			status = "canceled"
+		}
+		mcpTouchSession(sess, status)
 		return nil, err
 	}
 
 	// Update session timestamp only (no message storage)
-	mcpSessionStoreMu.Lock()
-	sess.UpdatedAt = time.Now()
-	mcpSessionStoreMu.Unlock()
+	mcpTouchSession(sess, "ok")
 
 	textContent := mcpExtractTextContent(sess.CLI, output)
+	recordTranscriptTurn(sess, input.Prompt, prompt, textContent, startedAt)
 	rememberSharedMemory(sess.CLI, sess.Role, textContent)
 	return mcpBuildResponseWithMeta(textContent, threadID, sess.CLI, sess.Role, sess.Model), nil
 }
 
 // mcpRunRoleSession runs a role-based session
-func mcpRunRoleSession(ctx context.Context, input MCPConductorInput) (map[string]interface{}, error) {
+func mcpRunRoleSession(ctx context.Context, input MCPConductorInput) (*SessionResult, error) {
+	if err := checkRoleAllowed(input.Role); err != nil {
+		return nil, err
+	}
 	configPath := resolveConfigPath("")
 	cfg, err := loadConfig(configPath)
 	if err != nil {
@@ -834,21 +1071,99 @@ func mcpRunRoleSession(ctx context.Context, input MCPConductorInput) (map[string
 		return nil, fmt.Errorf("unknown role: %s", input.Role)
 	}
 
+	prompt := input.Prompt
+	if input.MemoryKey != "" {
+		prompt, err = applyMemoryToPrompt(prompt, input.MemoryKey, input.MemoryMode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	chain, err := fallbackChain(cfg, input.Role)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		chain = []fallbackCandidate{{Role: input.Role, Cfg: role}}
+	}
+	var hops []FallbackHop
+	for i, hop := range chain {
+		res, runID, err := mcpRunRoleHop(ctx, cfg, input, hop, prompt, hops)
+		if err == nil {
+			res.StructuredContent.Hops = hops
+			return res, nil
+		}
+		class := errorCode(err)
+		if !isFallbackErrorCode(class) || i == len(chain)-1 {
+			return nil, withHops(err, hops)
+		}
+		hops = append(hops, FallbackHop{
+			RunID:        runID,
+			Role:         hop.Role,
+			Model:        firstNonEmpty(hop.Model.Name, hop.Cfg.Model),
+			Status:       "error",
+			FailureClass: class,
+			Error:        err.Error(),
+		})
+	}
+	return nil, fmt.Errorf("role %s has no models to run", input.Role)
+}
+
+// mcpRunRoleHop starts a session for one hop of a role's fallback chain (the
+// role itself when it has no chain) and returns its run ID along with the
+// result. hops are the failed hops before it.
+func mcpRunRoleHop(ctx context.Context, cfg Config, input MCPConductorInput, hop fallbackCandidate, prompt string, hops []FallbackHop) (*SessionResult, string, error) {
+	role := hop.Cfg
 	cli := role.CLI
 	adapter := mcpGetAdapter(cli)
 	if adapter == nil {
-		return nil, fmt.Errorf("unknown CLI for role %s: %s", input.Role, cli)
+		return nil, "", fmt.Errorf("unknown CLI for role %s: %s", hop.Role, cli)
 	}
 
-	prompt := applySharedMemory(input.Prompt)
-	args := mcpBuildRoleArgs(cli, prompt, role.Model, role.Reasoning)
-
-	output, err := adapter.Run(ctx, CLIRunOptions{
-		Args:          args,
-		IdleTimeoutMs: input.IdleTimeoutMs,
-	})
+	// Same spec as conductor.run_batch, except that roles without custom args
+	// get a JSON-emitting template so the native thread ID can be captured.
+	if len(role.Args) == 0 {
+		role.Args = mcpRoleSessionArgs(cli)
+	}
+	source := input.handoffFrom
+	if source != nil && role.Cwd == "" {
+		role.Cwd = source.Config.Cwd
+	}
+	// Police the template here so the session records the permissions the
+	// first turn ran with; buildSpecFromRoleConfig then has nothing to clamp.
+	policed, err := enforceArgsPolicy(cli, hop.Role, role.Args, role.Cwd)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	role.Args = policyCapArgs(cli, hop.Role, policed)
+	if source != nil {
+		var granted MCPSessionConfig
+		mcpRoleArgsConfig(cli, role.Args, &granted)
+		if handoffPermissionTier(MCPSession{CLI: cli, Config: granted}) > handoffPermissionTier(*source) {
+			return nil, "", fmt.Errorf("role %s runs with broader permissions than thread %s; hand off to a CLI instead", hop.Role, source.ID)
+		}
+	}
+	logPrompt := normalizeDefaults(cfg.Defaults).LogPrompt
+	spec, err := buildSpecFromRoleConfig(cfg, hop.Role, role, prompt, hop.Model.Name, hop.Model.ReasoningEffort, logPrompt)
+	if err != nil {
+		return nil, "", err
+	}
+	if input.IdleTimeoutMs > 0 {
+		spec.IdleTimeoutMs = input.IdleTimeoutMs
+	}
+	applyTimeout(&spec, input.TimeoutMs)
+	spec.Hops = hops
+	sentPrompt := applySharedMemory(prompt)
+	startedAt := time.Now()
+	runID, output, err := mcpRuntimeRunSession(ctx, spec, adapter)
+	if err != nil {
+		if cfg.MCP.SamplingFallback {
+			if reason := delegateUnavailable(cli, spec, err); reason != "" {
+				res, err := mcpSampleRole(ctx, hop.Role, role, spec.Model, sentPrompt, reason)
+				return res, runID, err
+			}
+		}
+		return nil, runID, err
 	}
 
 	// Extract native thread ID
@@ -861,29 +1176,90 @@ func mcpRunRoleSession(ctx context.Context, input MCPConductorInput) (map[string
 		threadID = uuid.New().String()
 	}
 
+	config := sessionProcessConfig(spec)
+	config.Reasoning = spec.Reasoning
+	config.ModelFlag = role.ModelFlag
+	config.ReasoningFlag = role.ReasoningFlag
+	config.ReasoningKey = role.ReasoningKey
+	mcpRoleArgsConfig(cli, role.Args, &config)
+
 	sess := &MCPSession{
 		ID:             threadID,
 		NativeThreadID: nativeThreadID,
 		CLI:            cli,
-		Role:           input.Role,
-		Model:          role.Model,
-		Config:         MCPSessionConfig{},
+		Role:           hop.Role,
+		Model:          spec.Model,
+		Config:         config,
+		LastStatus:     "ok",
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
 
-	mcpSessionStoreMu.Lock()
-	mcpEvictOldestSession()
-	mcpSessionStore[threadID] = sess
-	mcpSessionStoreMu.Unlock()
+	mcpStoreSession(sess)
 
 	textContent := mcpExtractTextContent(cli, output)
-	rememberSharedMemory(cli, input.Role, textContent)
-	return mcpBuildResponseWithMeta(textContent, threadID, cli, input.Role, role.Model), nil
+	recordTranscriptTurn(sess, input.Prompt, sentPrompt, textContent, startedAt)
+	rememberSharedMemory(cli, hop.Role, textContent)
+	return mcpBuildResponseWithMeta(textContent, threadID, cli, hop.Role, spec.Model), runID, nil
 }
 
 // Helper functions
 
+// mcpSessionSpec describes a session tool call as a CmdSpec so the runtime
+// queue, approval rules and run history see it like any other run.
+func mcpSessionSpec(adapter *CLIAdapter, role, model, prompt string, args []string, idleTimeoutMs int) CmdSpec {
+	cfg, _ := loadConfigOrEmpty(resolveConfigPath(""))
+	spec := CmdSpec{
+		Agent:         adapter.Cmd,
+		Role:          role,
+		Model:         model,
+		Cmd:           adapter.Cmd,
+		Args:          args,
+		IdleTimeoutMs: idleTimeoutMs,
+	}
+	spec.PromptHash, spec.PromptLen = promptMeta(prompt)
+	spec.PromptPreview = promptPreview(prompt)
+	if cfg.Defaults.LogPrompt {
+		spec.Prompt = prompt
+		spec.LogPrompt = true
+	}
+	return spec
+}
+
+// sessionProcessConfig captures the process settings of a role spec so
+// replies run with the same environment, directory, readiness and retries.
+func sessionProcessConfig(spec CmdSpec) MCPSessionConfig {
+	return MCPSessionConfig{
+		Cwd:               spec.Cwd,
+		Env:               spec.Env,
+		IdleTimeoutMs:     spec.IdleTimeoutMs,
+		TimeoutMs:         spec.TimeoutMs,
+		KillGraceMs:       spec.KillGraceMs,
+		Retry:             spec.Retry,
+		RetryBackoffMs:    spec.RetryBackoffMs,
+		RetryOn:           spec.RetryOn,
+		RetryMaxBackoffMs: spec.RetryMaxBackoffMs,
+		ReadyCmd:          spec.ReadyCmd,
+		ReadyArgs:         spec.ReadyArgs,
+		ReadyTimeoutMs:    spec.ReadyTimeoutMs,
+	}
+}
+
+// applySessionProcessConfig restores the process settings saved by sessionProcessConfig.
+func applySessionProcessConfig(spec *CmdSpec, config MCPSessionConfig) {
+	spec.Cwd = config.Cwd
+	spec.Env = config.Env
+	spec.TimeoutMs = config.TimeoutMs
+	spec.KillGraceMs = config.KillGraceMs
+	spec.Retry = config.Retry
+	spec.RetryBackoffMs = config.RetryBackoffMs
+	spec.RetryOn = config.RetryOn
+	spec.RetryMaxBackoffMs = config.RetryMaxBackoffMs
+	spec.ReadyCmd = config.ReadyCmd
+	spec.ReadyArgs = config.ReadyArgs
+	spec.ReadyTimeoutMs = config.ReadyTimeoutMs
+}
+
 func mcpGetAdapter(cli string) *CLIAdapter {
 	switch cli {
 	case "codex":
@@ -896,7 +1272,14 @@ func mcpGetAdapter(cli string) *CLIAdapter {
 	return nil
 }
 
-func mcpBuildCodexArgs(input MCPCodexInput) []string {
+// mcpBuildCodexArgs builds a codex exec argv after applying the policy to the
+// requested sandbox, approval policy, profile and cwd.
+func mcpBuildCodexArgs(input MCPCodexInput) ([]string, error) {
+	limited := MCPSessionConfig{Sandbox: input.Sandbox, ApprovalPolicy: input.ApprovalPolicy, Cwd: input.Cwd, Profile: input.Profile}
+	if err := enforceSessionPolicy("codex", "", &limited); err != nil {
+		return nil, err
+	}
+	input.Sandbox, input.ApprovalPolicy = limited.Sandbox, limited.ApprovalPolicy
 	args := []string{"exec", "--json"}
 
 	if input.ApprovalPolicy != "" {
@@ -914,16 +1297,12 @@ func mcpBuildCodexArgs(input MCPCodexInput) []string {
 	if input.Profile != "" {
 		args = append(args, "-p", input.Profile)
 	}
-	if input.Config != nil {
-		for key, value := range input.Config {
-			args = append(args, "-c", fmt.Sprintf("%s=%v", key, value))
+	for _, override := range mcpCodexConfigOverrides(input) {
+		override, err := enforceCodexOverride("", override)
+		if err != nil {
+			return nil, err
 		}
-	}
-	if input.BaseInstructions != "" {
-		args = append(args, "-c", fmt.Sprintf("base_instructions=%q", input.BaseInstructions))
-	}
-	if input.IncludePlanTool != nil && *input.IncludePlanTool {
-		args = append(args, "-c", "include_plan_tool=true")
+		args = append(args, "-c", override)
 	}
 	// Add reasoning effort for o-series models (o3, o4-mini, etc.)
 	if input.ReasoningEffort != "" {
@@ -931,14 +1310,48 @@ func mcpBuildCodexArgs(input MCPCodexInput) []string {
 	}
 
 	args = append(args, input.Prompt)
-	return args
+	return args, nil
+}
+
+// mcpCodexConfigOverrides collects the -c overrides for a codex session so
+// replies can replay them. Keys are sorted to keep argv stable.
+func mcpCodexConfigOverrides(input MCPCodexInput) []string {
+	keys := make([]string, 0, len(input.Config))
+	for key := range input.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	overrides := make([]string, 0, len(keys)+2)
+	for _, key := range keys {
+		overrides = append(overrides, fmt.Sprintf("%s=%v", key, input.Config[key]))
+	}
+	if input.BaseInstructions != "" {
+		overrides = append(overrides, fmt.Sprintf("base_instructions=%q", input.BaseInstructions))
+	}
+	if input.IncludePlanTool != nil && *input.IncludePlanTool {
+		overrides = append(overrides, "include_plan_tool=true")
+	}
+	return overrides
 }
 
-func mcpBuildClaudeArgs(input MCPClaudeInput) []string {
-	permissionMode := strings.TrimSpace(input.PermissionMode)
-	if permissionMode == "" {
-		permissionMode = "bypassPermissions"
+// mcpClaudePermissionMode returns the permission mode a claude session runs
+// with; it is stored on the session so replies keep the same mode.
+func mcpClaudePermissionMode(mode string) string {
+	mode = strings.TrimSpace(mode)
+	if mode == "" {
+		return "bypassPermissions"
+	}
+	return mode
+}
+
+// mcpBuildClaudeArgs builds a claude argv after applying the policy to the
+// permission mode (bypassPermissions when unset) and directories.
+func mcpBuildClaudeArgs(input MCPClaudeInput) ([]string, error) {
+	limited := MCPSessionConfig{PermissionMode: mcpClaudePermissionMode(input.PermissionMode), Cwd: input.Cwd, AddDir: input.AddDir}
+	if err := enforceSessionPolicy("claude", "", &limited); err != nil {
+		return nil, err
 	}
+	permissionMode := limited.PermissionMode
 
 	args := []string{"-p", input.Prompt, "--output-format", "stream-json", "--permission-mode", permissionMode, "--verbose"}
 
@@ -976,10 +1389,17 @@ func mcpBuildClaudeArgs(input MCPClaudeInput) []string {
 		args = append(args, "--debug")
 	}
 
-	return args
+	return args, nil
 }
 
-func mcpBuildGeminiArgs(input MCPGeminiInput) []string {
+// mcpBuildGeminiArgs builds a gemini argv after applying the policy to yolo,
+// the approval mode and directories.
+func mcpBuildGeminiArgs(input MCPGeminiInput) ([]string, error) {
+	limited := MCPSessionConfig{Yolo: input.Yolo, ApprovalMode: input.ApprovalMode, Cwd: input.Cwd, IncludeDirectories: input.IncludeDirectories}
+	if err := enforceSessionPolicy("gemini", "", &limited); err != nil {
+		return nil, err
+	}
+	input.Yolo, input.ApprovalMode = limited.Yolo, limited.ApprovalMode
 	args := []string{"-p", input.Prompt, "--output-format", "stream-json"}
 
 	if input.Model != "" {
@@ -1005,7 +1425,7 @@ func mcpBuildGeminiArgs(input MCPGeminiInput) []string {
 		args = append(args, "--debug")
 	}
 
-	return args
+	return args, nil
 }
 
 func mcpBuildReplyArgs(cli, contextPrompt string) []string {
@@ -1069,38 +1489,103 @@ func mcpBuildReplyArgsWithConfig(cli, contextPrompt string, config MCPSessionCon
 	return []string{contextPrompt}
 }
 
-func mcpBuildRoleArgs(cli, prompt, model, reasoning string) []string {
+// mcpRoleSessionArgs returns the argv template used for role sessions when
+// the role does not define args. Model and reasoning flags are inserted before
+// {prompt} by buildSpecFromRoleConfig.
+func mcpRoleSessionArgs(cli string) []string {
 	switch cli {
 	case "codex":
-		args := []string{"exec", "--json"}
-		if model != "" {
-			args = append(args, "-m", model)
+		return []string{"exec", "--json", "{prompt}"}
+	case "claude":
+		return []string{"-p", "{prompt}", "--output-format", "stream-json", "--permission-mode", "bypassPermissions", "--verbose"}
+	case "gemini":
+		return []string{"--output-format", "stream-json", "--sandbox", "{prompt}"}
+	}
+	return nil
+}
+
+// mcpRoleReplyFlags are the flags of a role template that replies write
+// themselves, mapped to whether they take a value.
+var mcpRoleReplyFlags = map[string]map[string]bool{
+	"codex":  {"--json": false},
+	"claude": {"-p": false, "--print": false, "--output-format": true, "--verbose": false},
+	"gemini": {"-p": true, "--prompt": true, "--output-format": true},
+}
+
+// mcpRoleArgsConfig records a role's (policed) argv template in config so
+// replies run with it: permission flags go to the fields a direct session
+// uses (a bare gemini --sandbox is stored as "true"), and the rest of the
+// template, minus the prompt, the codex exec subcommand and the flags replies
+// write themselves, is kept in RoleArgs.
+func mcpRoleArgsConfig(cli string, args []string, config *MCPSessionConfig) {
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		name, value, inline := strings.Cut(args[i], "=")
+		next := func() string {
+			if inline {
+				return value
+			}
+			if i+1 < len(args) && args[i+1] != "{prompt}" {
+				i++
+				return args[i]
+			}
+			return ""
 		}
-		// Reasoning effort for o-series models - no quotes needed
-		if reasoning != "" {
-			args = append(args, "-c", fmt.Sprintf("model_reasoning_effort=%s", reasoning))
+		if takesValue, ok := mcpRoleReplyFlags[cli][name]; ok {
+			if takesValue {
+				next()
+			}
+			continue
 		}
-		args = append(args, prompt)
-		return args
-	case "claude":
-		args := []string{"-p", prompt, "--output-format", "stream-json", "--permission-mode", "bypassPermissions", "--verbose"}
-		if model != "" {
-			args = append(args, "--model", model)
+		if args[i] == "{prompt}" || (cli == "codex" && i == 0 && args[i] == "exec") {
+			continue
 		}
-		return args
-	case "gemini":
-		args := []string{"--output-format", "stream-json", "--sandbox"}
-		if model != "" {
-			args = append(args, "-m", model)
+		switch {
+		case cli == "codex" && name == "--full-auto":
+			config.Sandbox, config.ApprovalPolicy = codexFullAuto.Sandbox, codexFullAuto.ApprovalPolicy
+		case cli == "codex" && policyArgFlags[cli][name] == "sandbox":
+			config.Sandbox = next()
+		case cli == "codex" && policyArgFlags[cli][name] == "approval-policy":
+			config.ApprovalPolicy = next()
+		case cli == "claude" && name == "--permission-mode":
+			config.PermissionMode = next()
+		case cli == "gemini" && (name == "--sandbox" || name == "-s"):
+			config.Sandbox = "true"
+			if inline {
+				config.Sandbox = value
+			} else if i+1 < len(args) && (args[i+1] == "true" || args[i+1] == "false") {
+				i++
+				config.Sandbox = args[i]
+			}
+		case cli == "gemini" && (name == "--yolo" || name == "-y"):
+			config.Yolo = true
+		case cli == "gemini" && name == "--approval-mode":
+			config.ApprovalMode = next()
+		default:
+			rest = append(rest, args[i])
 		}
-		args = append(args, prompt)
-		return args
 	}
-	return []string{prompt}
+	config.RoleArgs = rest
 }
 
 // mcpBuildResumeArgs builds arguments for native CLI resume (no history re-transmission)
-func mcpBuildResumeArgs(cli, nativeThreadID, prompt string, config MCPSessionConfig) []string {
+func mcpBuildResumeArgs(cli, nativeThreadID, prompt string, config MCPSessionConfig) ([]string, error) {
+	return mcpBuildResumeArgsWithModel(cli, "", nativeThreadID, prompt, "", config)
+}
+
+// mcpBuildResumeArgsWithModel replays every option captured when the session
+// was created, so a reply runs with the same model, permissions and
+// directories as the first turn. The policy is re-applied on every turn, so
+// a stored permission (such as claude's default bypassPermissions) is capped
+// by the current limits for the CLI and role.
+func mcpBuildResumeArgsWithModel(cli, role, nativeThreadID, prompt, model string, config MCPSessionConfig) ([]string, error) {
+	if err := enforceSessionPolicy(cli, role, &config); err != nil {
+		return nil, err
+	}
+	roleArgs, err := enforceArgsPolicy(cli, role, config.RoleArgs, config.Cwd)
+	if err != nil {
+		return nil, err
+	}
 	switch cli {
 	case "codex":
 		// Codex: codex exec resume <session-id> [prompt]
@@ -1117,8 +1602,23 @@ func mcpBuildResumeArgs(cli, nativeThreadID, prompt string, config MCPSessionCon
 		if config.Sandbox != "" {
 			args = append(args, "--sandbox", config.Sandbox)
 		}
+		if config.Cwd != "" {
+			args = append(args, "--cwd", config.Cwd)
+		}
+		if config.Profile != "" {
+			args = append(args, "-p", config.Profile)
+		}
+		for _, override := range config.ConfigOverrides {
+			override, err := enforceCodexOverride(role, override)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "-c", override)
+		}
+		args = append(args, mcpResumeModelArgs(cli, model, config)...)
+		args = append(args, roleArgs...)
 		args = append(args, prompt)
-		return args
+		return args, nil
 
 	case "claude":
 		// Claude: claude --resume <session-id> -p <prompt>
@@ -1128,13 +1628,38 @@ func mcpBuildResumeArgs(cli, nativeThreadID, prompt string, config MCPSessionCon
 		} else {
 			args = append(args, "--continue")
 		}
-		permissionMode := config.PermissionMode
-		if permissionMode == "" {
-			permissionMode = "bypassPermissions"
+		if config.PermissionMode != "" {
+			args = append(args, "--permission-mode", config.PermissionMode)
+		}
+		args = append(args, "--verbose")
+		args = append(args, mcpResumeModelArgs(cli, model, config)...)
+		if config.AllowedTools != "" {
+			args = append(args, "--allowedTools", config.AllowedTools)
+		}
+		if config.DisallowedTools != "" {
+			args = append(args, "--disallowedTools", config.DisallowedTools)
+		}
+		if config.SystemPrompt != "" {
+			args = append(args, "--system-prompt", config.SystemPrompt)
+		}
+		if config.AppendSystemPrompt != "" {
+			args = append(args, "--append-system-prompt", config.AppendSystemPrompt)
 		}
-		args = append(args, "--permission-mode", permissionMode, "--verbose")
+		if config.MaxTurns > 0 {
+			args = append(args, "--max-turns", fmt.Sprintf("%d", config.MaxTurns))
+		}
+		if config.AddDir != "" {
+			args = append(args, "--add-dir", config.AddDir)
+		}
+		if config.McpConfig != "" {
+			args = append(args, "--mcp-config", config.McpConfig)
+		}
+		if config.Agents != "" {
+			args = append(args, "--agents", config.Agents)
+		}
+		args = append(args, roleArgs...)
 		args = append(args, "-p", prompt)
-		return args
+		return args, nil
 
 	case "gemini":
 		// Gemini: supports --resume with session UUID or index
@@ -1146,16 +1671,50 @@ func mcpBuildResumeArgs(cli, nativeThreadID, prompt string, config MCPSessionCon
 			// Fall back to latest session if no ID available
 			args = append(args, "--resume")
 		}
+		args = append(args, mcpResumeModelArgs(cli, model, config)...)
+		switch config.Sandbox {
+		case "", "false":
+		case "true":
+			args = append(args, "--sandbox")
+		default:
+			args = append(args, "--sandbox", config.Sandbox)
+		}
 		if config.Yolo {
 			args = append(args, "--yolo")
 		}
 		if config.ApprovalMode != "" {
 			args = append(args, "--approval-mode", config.ApprovalMode)
 		}
+		if config.IncludeDirectories != "" {
+			args = append(args, "--include-directories", config.IncludeDirectories)
+		}
+		args = append(args, roleArgs...)
 		args = append(args, prompt)
-		return args
+		return args, nil
+	}
+	return []string{prompt}, nil
+}
+
+// mcpResumeModelArgs renders the model and reasoning flags for a reply, using
+// the role's flags when the session came from a role and CLI defaults otherwise.
+func mcpResumeModelArgs(cli, model string, config MCPSessionConfig) []string {
+	defaults, _ := resolveRoleDefaults(cli)
+	modelFlag := config.ModelFlag
+	if modelFlag == "" {
+		modelFlag = defaults.modelFlag
+	}
+	reasoningFlag, reasoningKey := config.ReasoningFlag, config.ReasoningKey
+	if reasoningFlag == "" && reasoningKey == "" {
+		reasoningFlag, reasoningKey = defaults.reasoningFlag, defaults.reasoningKey
 	}
-	return []string{prompt}
+	args := []string{}
+	if config.Reasoning != "" && reasoningFlag != "" && reasoningKey != "" {
+		args = append(args, reasoningFlag, fmt.Sprintf("%s=%s", reasoningKey, config.Reasoning))
+	}
+	if model != "" && modelFlag != "" {
+		args = append(args, modelFlag, model)
+	}
+	return args
 }
 
 // mcpExtractNativeThreadID extracts the native thread/session ID from CLI output
@@ -1220,31 +1779,19 @@ func mcpExtractTextContent(cli, output string) string {
 	return mcpExtractText(output)
 }
 
-func mcpBuildResponse(output, threadID string) map[string]interface{} {
+func mcpBuildResponse(output, threadID string) *SessionResult {
 	return mcpBuildResponseWithMeta(output, threadID, "", "", "")
 }
 
-func mcpBuildResponseWithMeta(output, threadID, cli, role, model string) map[string]interface{} {
-	textContent := mcpExtractText(output)
-
-	structured := map[string]interface{}{
-		"threadId": threadID,
-	}
-	if cli != "" {
-		structured["cli"] = cli
-	}
-	if role != "" {
-		structured["role"] = role
-	}
-	if model != "" {
-		structured["model"] = model
-	}
-
-	return map[string]interface{}{
-		"content": []map[string]interface{}{
-			{"type": "text", "text": textContent},
+func mcpBuildResponseWithMeta(output, threadID, cli, role, model string) *SessionResult {
+	return &SessionResult{
+		Content: []TextBlock{{Type: "text", Text: mcpExtractText(output)}},
+		StructuredContent: SessionStructured{
+			ThreadID: threadID,
+			CLI:      cli,
+			Role:     role,
+			Model:    model,
 		},
-		"structuredContent": structured,
 	}
 }
 
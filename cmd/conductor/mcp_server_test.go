@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 func TestValidatePrompt(t *testing.T) {
@@ -38,7 +43,7 @@ func TestMcpBuildCodexArgs(t *testing.T) {
 		Profile:        "test",
 	}
 
-	args := mcpBuildCodexArgs(input)
+	args := mustArgs(t)(mcpBuildCodexArgs(input))
 
 	// Check that exec is present and prompt is at the end
 	foundExec := false
@@ -73,7 +78,7 @@ func TestMcpBuildClaudeArgs(t *testing.T) {
 		MaxTurns:       5,
 	}
 
-	args := mcpBuildClaudeArgs(input)
+	args := mustArgs(t)(mcpBuildClaudeArgs(input))
 
 	// Check that -p and prompt are present
 	foundPrint := false
@@ -102,7 +107,7 @@ func TestMcpBuildGeminiArgs(t *testing.T) {
 		Yolo:   true,
 	}
 
-	args := mcpBuildGeminiArgs(input)
+	args := mustArgs(t)(mcpBuildGeminiArgs(input))
 
 	// Check that prompt is present and yolo flag
 	foundPrompt := false
@@ -175,7 +180,7 @@ func TestMcpBuildResumeArgs(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		args := mcpBuildResumeArgs(tt.cli, tt.nativeThreadID, tt.prompt, MCPSessionConfig{})
+		args := mustArgs(t)(mcpBuildResumeArgs(tt.cli, tt.nativeThreadID, tt.prompt, MCPSessionConfig{}))
 		argsStr := strings.Join(args, " ")
 		for _, want := range tt.wantContains {
 			if !strings.Contains(argsStr, want) {
@@ -189,34 +194,26 @@ func TestMcpBuildResponseWithMeta(t *testing.T) {
 	result := mcpBuildResponseWithMeta("output text", "thread-123", "codex", "oracle", "gpt-4")
 
 	// Check structuredContent
-	structured, ok := result["structuredContent"].(map[string]interface{})
-	if !ok {
-		t.Fatal("expected structuredContent to be a map")
-	}
-
-	if structured["threadId"] != "thread-123" {
-		t.Errorf("expected threadId 'thread-123', got %v", structured["threadId"])
+	structured := result.StructuredContent
+	if structured.ThreadID != "thread-123" {
+		t.Errorf("expected threadId 'thread-123', got %v", structured.ThreadID)
 	}
-	if structured["cli"] != "codex" {
-		t.Errorf("expected cli 'codex', got %v", structured["cli"])
+	if structured.CLI != "codex" {
+		t.Errorf("expected cli 'codex', got %v", structured.CLI)
 	}
-	if structured["role"] != "oracle" {
-		t.Errorf("expected role 'oracle', got %v", structured["role"])
+	if structured.Role != "oracle" {
+		t.Errorf("expected role 'oracle', got %v", structured.Role)
 	}
-	if structured["model"] != "gpt-4" {
-		t.Errorf("expected model 'gpt-4', got %v", structured["model"])
+	if structured.Model != "gpt-4" {
+		t.Errorf("expected model 'gpt-4', got %v", structured.Model)
 	}
 
 	// Check content array
-	content, ok := result["content"].([]map[string]interface{})
-	if !ok {
-		t.Fatal("expected content to be an array of maps")
-	}
-	if len(content) == 0 {
+	if len(result.Content) == 0 {
 		t.Fatal("expected non-empty content")
 	}
-	if content[0]["type"] != "text" {
-		t.Errorf("expected content type 'text', got %v", content[0]["type"])
+	if result.Content[0].Type != "text" {
+		t.Errorf("expected content type 'text', got %v", result.Content[0].Type)
 	}
 }
 
@@ -331,10 +328,341 @@ func TestMcpBuildResumeArgsNoThreadID(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		args := mcpBuildResumeArgs(tt.cli, "", "prompt", MCPSessionConfig{})
+		args := mustArgs(t)(mcpBuildResumeArgs(tt.cli, "", "prompt", MCPSessionConfig{}))
 		argsStr := strings.Join(args, " ")
 		if !strings.Contains(argsStr, tt.wantContains) {
 			t.Errorf("mcpBuildResumeArgs(%s, empty): expected %q, got %v", tt.cli, tt.wantContains, args)
 		}
 	}
 }
+
+func TestResolveMCPGroups(t *testing.T) {
+	groups, err := resolveMCPGroups("", MCPConfig{})
+	if err != nil {
+		t.Fatalf("resolveMCPGroups default: %v", err)
+	}
+	if !groups[mcpGroupSession] || !groups[mcpGroupRuntime] {
+		t.Fatalf("expected all groups by default, got %v", groups)
+	}
+
+	groups, err = resolveMCPGroups("", MCPConfig{Groups: []string{"runtime"}})
+	if err != nil {
+		t.Fatalf("resolveMCPGroups config: %v", err)
+	}
+	if groups[mcpGroupSession] || !groups[mcpGroupRuntime] {
+		t.Fatalf("expected runtime only from config, got %v", groups)
+	}
+
+	groups, err = resolveMCPGroups("Session", MCPConfig{Groups: []string{"runtime"}})
+	if err != nil {
+		t.Fatalf("resolveMCPGroups flag: %v", err)
+	}
+	if !groups[mcpGroupSession] || groups[mcpGroupRuntime] {
+		t.Fatalf("expected flag to override config, got %v", groups)
+	}
+
+	if _, err := resolveMCPGroups("session,bogus", MCPConfig{}); err == nil {
+		t.Fatal("expected error for unknown group")
+	}
+}
+
+func listServerTools(t *testing.T, server *mcp.Server) map[string]bool {
+	t.Helper()
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	defer serverSession.Close()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "0.0.0"}, nil)
+	clientSession, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	defer clientSession.Close()
+	res, err := clientSession.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	names := map[string]bool{}
+	for _, tool := range res.Tools {
+		names[tool.Name] = true
+	}
+	return names
+}
+
+func TestNewMCPServerRegistersAllGroups(t *testing.T) {
+	names := listServerTools(t, newMCPServer(map[string]bool{mcpGroupSession: true, mcpGroupRuntime: true}))
+	for _, want := range []string{"codex", "claude-reply", "conductor", "memory", "status", "conductor.run", "conductor.run_batch", "conductor.run_status", "conductor.queue_list", "conductor.approval_approve"} {
+		if !names[want] {
+			t.Errorf("expected tool %q to be registered", want)
+		}
+	}
+}
+
+func TestNewMCPServerSessionOnly(t *testing.T) {
+	names := listServerTools(t, newMCPServer(map[string]bool{mcpGroupSession: true}))
+	if !names["codex"] {
+		t.Error("expected session tools")
+	}
+	if names["conductor.run"] {
+		t.Error("expected runtime tools to be hidden")
+	}
+}
+
+func writeRoleSessionConfig(t *testing.T, dir, roleExtra string) {
+	t.Helper()
+	content := fmt.Sprintf(`{
+  "roles": {
+    "oracle": {
+      "cli": "codex",
+      "model": "gpt-5",
+      "env": { "ROLE_VAR": "from-role", "FAKE_DIR": %q },
+      "cwd": %q,
+      "retry": 1,
+      "retry_backoff_ms": 1,
+      "retry_on": ["cli_exit"]%s
+    }
+  }
+}`, dir, dir, roleExtra)
+	writeTestConfig(t, content)
+}
+
+// fakeFlakyCodex fails its first invocation, then echoes env, cwd and args.
+const fakeFlakyCodex = `n=$(cat "$FAKE_DIR/count" 2>/dev/null || echo 0)
+n=$((n+1))
+echo $n > "$FAKE_DIR/count"
+if [ "$n" = 1 ]; then echo "boom" >&2; exit 1; fi
+echo '{"type":"thread.started","thread_id":"role-thread"}'
+echo "{\"type\":\"item.completed\",\"item\":{\"type\":\"agent_message\",\"text\":\"env=$ROLE_VAR pwd=$(pwd) args=$*\"}}"
+`
+
+func TestRoleSessionHonorsRoleConfig(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	withIsolatedMemoryStore(t, func() {})
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("eval symlinks: %v", err)
+	}
+	writeRoleSessionConfig(t, dir, "")
+	installFakeCLI(t, "codex", fakeFlakyCodex)
+
+	result, err := mcpRunRoleSession(context.Background(), MCPConductorInput{Prompt: "plan it", Role: "oracle"})
+	if err != nil {
+		t.Fatalf("mcpRunRoleSession: %v", err)
+	}
+	text := result.Content[0].Text
+	for _, want := range []string{"env=from-role", "pwd=" + dir, "--json", "-m gpt-5", "plan it"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected role session output to contain %q, got %q", want, text)
+		}
+	}
+
+	mcpSessionStoreMu.RLock()
+	sess := mcpSessionStore["role-thread"]
+	mcpSessionStoreMu.RUnlock()
+	if sess == nil {
+		t.Fatal("expected role session to be stored")
+	}
+	if sess.Config.Cwd != dir || sess.Config.Env["ROLE_VAR"] != "from-role" || sess.Config.Retry != 1 {
+		t.Fatalf("expected role process settings in session config, got %+v", sess.Config)
+	}
+
+	reply, err := mcpRunReply(context.Background(), "", MCPReplyInput{Prompt: "next", ThreadID: "role-thread"})
+	if err != nil {
+		t.Fatalf("mcpRunReply: %v", err)
+	}
+	text = reply.Content[0].Text
+	if !strings.Contains(text, "env=from-role") || !strings.Contains(text, "pwd="+dir) {
+		t.Errorf("expected reply to reuse role env and cwd, got %q", text)
+	}
+	if !strings.Contains(text, "-m gpt-5") {
+		t.Errorf("expected reply to keep the role model, got %q", text)
+	}
+}
+
+func TestRoleReplyKeepsCustomArgs(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	resetMCPSessions(t)
+	withIsolatedMemoryStore(t, func() {})
+	dir := t.TempDir()
+	writeRoleSessionConfig(t, dir, `,
+      "args": ["exec", "--json", "--skip-git-repo-check", "-c", "hide_agent_reasoning=true", "{prompt}"]`)
+	installFakeCLI(t, "codex", fakeFlakyCodex)
+
+	if _, err := mcpRunRoleSession(context.Background(), MCPConductorInput{Prompt: "plan it", Role: "oracle"}); err != nil {
+		t.Fatalf("mcpRunRoleSession: %v", err)
+	}
+	sess, _ := mcpLookupSession("role-thread")
+	if sess == nil || strings.Join(sess.Config.RoleArgs, " ") != "--skip-git-repo-check -c hide_agent_reasoning=true" {
+		t.Fatalf("expected the custom args without the prompt in the session, got %+v", sess)
+	}
+
+	reply, err := mcpRunReply(context.Background(), "", MCPReplyInput{Prompt: "next", ThreadID: "role-thread"})
+	if err != nil {
+		t.Fatalf("mcpRunReply: %v", err)
+	}
+	if text := reply.Content[0].Text; !strings.Contains(text, "args=exec resume role-thread --json") || !strings.Contains(text, "-m gpt-5 --skip-git-repo-check -c hide_agent_reasoning=true ") {
+		t.Errorf("expected the reply to re-apply the role's custom args, got %q", text)
+	}
+}
+
+func TestRoleSessionReadyCheck(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	dir := t.TempDir()
+	writeRoleSessionConfig(t, dir, `,
+      "ready_cmd": "false"`)
+	installFakeCLI(t, "codex", fakeCodexSession)
+
+	_, err := mcpRunRoleSession(context.Background(), MCPConductorInput{Prompt: "plan it", Role: "oracle"})
+	if err == nil || !strings.Contains(err.Error(), "ready check failed") {
+		t.Fatalf("expected ready check error, got %v", err)
+	}
+}
+
+func TestGeminiRoleReplyKeepsSandbox(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	resetMCPSessions(t)
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, `{"roles": {"scout": {"cli": "gemini"}}}`)
+	installFakeCLI(t, "gemini", `echo '{"session_id":"gem-thread"}'
+echo "args=$*"
+`)
+
+	if _, err := mcpRunRoleSession(context.Background(), MCPConductorInput{Prompt: "map it", Role: "scout"}); err != nil {
+		t.Fatalf("mcpRunRoleSession: %v", err)
+	}
+	reply, err := mcpRunReply(context.Background(), "", MCPReplyInput{Prompt: "next", ThreadID: "gem-thread"})
+	if err != nil {
+		t.Fatalf("mcpRunReply: %v", err)
+	}
+	if text := reply.Content[0].Text; !strings.HasPrefix(text, "args=--output-format stream-json --resume gem-thread --sandbox ") {
+		t.Errorf("expected the reply to keep the role's --sandbox, got %q", text)
+	}
+}
+
+func TestRoleSessionStoresPolicedPermissions(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	resetMCPSessions(t)
+	withIsolatedMemoryStore(t, func() {})
+	writeTestConfig(t, `{"roles": {"oracle": {"cli": "codex", "args": ["exec", "--json", "--full-auto", "{prompt}"]}}}`)
+	installFakeCLI(t, "codex", fakeCodexSession)
+	withPolicy(t, PolicyConfig{Mode: policyModeClamp, CLI: map[string]PolicyLimits{"codex": {Sandbox: "read-only"}}})
+
+	if _, err := mcpRunRoleSession(context.Background(), MCPConductorInput{Prompt: "plan it", Role: "oracle"}); err != nil {
+		t.Fatalf("mcpRunRoleSession: %v", err)
+	}
+	sess, ok := mcpLastSession("", "oracle")
+	if !ok || sess.Config.Sandbox != "read-only" || sess.Config.ApprovalPolicy != "on-request" {
+		t.Fatalf("expected the clamped --full-auto settings in the session, got %+v", sess)
+	}
+}
+
+func assertArgPair(t *testing.T, args []string, flag, value string) {
+	t.Helper()
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag && args[i+1] == value {
+			return
+		}
+	}
+	t.Errorf("expected %s %s in %v", flag, value, args)
+}
+
+func TestMcpBuildResumeArgsCodexFullConfig(t *testing.T) {
+	config := MCPSessionConfig{
+		Reasoning:       "high",
+		ApprovalPolicy:  "on-request",
+		Sandbox:         "workspace-write",
+		Cwd:             "/work",
+		Profile:         "team",
+		ConfigOverrides: []string{"foo=bar"},
+	}
+	args := mustArgs(t)(mcpBuildResumeArgsWithModel("codex", "", "t-1", "next", "o3", config))
+	assertArgPair(t, args, "resume", "t-1")
+	assertArgPair(t, args, "--approval-policy", "on-request")
+	assertArgPair(t, args, "--sandbox", "workspace-write")
+	assertArgPair(t, args, "--cwd", "/work")
+	assertArgPair(t, args, "-p", "team")
+	assertArgPair(t, args, "-c", "foo=bar")
+	assertArgPair(t, args, "-c", "model_reasoning_effort=high")
+	assertArgPair(t, args, "-m", "o3")
+	if args[len(args)-1] != "next" {
+		t.Errorf("expected prompt last, got %v", args)
+	}
+}
+
+func TestMcpBuildResumeArgsClaudeFullConfig(t *testing.T) {
+	config := MCPSessionConfig{
+		PermissionMode:     "acceptEdits",
+		AllowedTools:       "Read,Grep",
+		DisallowedTools:    "Bash",
+		SystemPrompt:       "be brief",
+		AppendSystemPrompt: "cite files",
+		MaxTurns:           3,
+		AddDir:             "/extra",
+	}
+	args := mustArgs(t)(mcpBuildResumeArgsWithModel("claude", "", "s-1", "next", "sonnet", config))
+	assertArgPair(t, args, "--resume", "s-1")
+	assertArgPair(t, args, "--permission-mode", "acceptEdits")
+	assertArgPair(t, args, "--allowedTools", "Read,Grep")
+	assertArgPair(t, args, "--disallowedTools", "Bash")
+	assertArgPair(t, args, "--system-prompt", "be brief")
+	assertArgPair(t, args, "--append-system-prompt", "cite files")
+	assertArgPair(t, args, "--max-turns", "3")
+	assertArgPair(t, args, "--add-dir", "/extra")
+	assertArgPair(t, args, "--model", "sonnet")
+	assertArgPair(t, args, "-p", "next")
+}
+
+func TestMcpBuildResumeArgsClaudeNoForcedBypass(t *testing.T) {
+	args := mustArgs(t)(mcpBuildResumeArgs("claude", "s-1", "next", MCPSessionConfig{}))
+	if indexOf(args, "bypassPermissions") >= 0 || indexOf(args, "--permission-mode") >= 0 {
+		t.Errorf("expected no permission mode without session config, got %v", args)
+	}
+
+	input := MCPClaudeInput{Prompt: "hi"}
+	assertArgPair(t, mustArgs(t)(mcpBuildClaudeArgs(input)), "--permission-mode", mcpClaudePermissionMode(input.PermissionMode))
+}
+
+func TestMcpBuildResumeArgsGeminiFullConfig(t *testing.T) {
+	config := MCPSessionConfig{
+		Sandbox:            "docker",
+		Yolo:               true,
+		ApprovalMode:       "auto_edit",
+		IncludeDirectories: "/a,/b",
+	}
+	args := mustArgs(t)(mcpBuildResumeArgsWithModel("gemini", "", "g-1", "next", "gemini-2.5-pro", config))
+	assertArgPair(t, args, "--resume", "g-1")
+	assertArgPair(t, args, "--sandbox", "docker")
+	assertArgPair(t, args, "--approval-mode", "auto_edit")
+	assertArgPair(t, args, "--include-directories", "/a,/b")
+	assertArgPair(t, args, "--model", "gemini-2.5-pro")
+	if indexOf(args, "--yolo") < 0 {
+		t.Errorf("expected --yolo in %v", args)
+	}
+}
+
+func TestMcpResumeModelArgsUsesRoleFlags(t *testing.T) {
+	config := MCPSessionConfig{Reasoning: "low", ModelFlag: "--model-name", ReasoningFlag: "--set", ReasoningKey: "effort"}
+	args := mcpResumeModelArgs("codex", "m1", config)
+	assertArgPair(t, args, "--set", "effort=low")
+	assertArgPair(t, args, "--model-name", "m1")
+}
+
+func TestMcpCodexConfigOverridesSorted(t *testing.T) {
+	plan := true
+	got := mcpCodexConfigOverrides(MCPCodexInput{
+		Config:          map[string]interface{}{"b": 2, "a": "x"},
+		IncludePlanTool: &plan,
+	})
+	want := []string{"a=x", "b=2", "include_plan_tool=true"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Session TTL - sessions expire after 1 hour of inactivity
+	defaultMCPSessionTTL = 1 * time.Hour
+	// Cleanup interval - check for expired sessions every 10 minutes
+	mcpSessionCleanupInterval = 10 * time.Minute
+	// Max sessions to prevent memory exhaustion
+	defaultMCPMaxSessions = 100
+)
+
+// Session management for multi-turn conversations (matches OpenAI Codex MCP pattern).
+// The map caches up to max_sessions; every session is also persisted as
+// CONDUCTOR_HOME/sessions/<id>.json so threadIds survive server restarts and
+// eviction, and the files are what listing reads.
+var (
+	mcpSessionStore   = make(map[string]*MCPSession)
+	mcpSessionStoreMu sync.RWMutex
+	mcpSessionTTL     = defaultMCPSessionTTL
+	mcpMaxSessions    = defaultMCPMaxSessions
+)
+
+var safeSessionFileName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// configureMCPSessions applies mcp.session_ttl_ms, mcp.max_sessions and
+// mcp.transcripts.
+func configureMCPSessions(cfg MCPConfig) {
+	mcpSessionStoreMu.Lock()
+	defer mcpSessionStoreMu.Unlock()
+	mcpSessionTTL = defaultMCPSessionTTL
+	if cfg.SessionTTLMs > 0 {
+		mcpSessionTTL = time.Duration(cfg.SessionTTLMs) * time.Millisecond
+	}
+	mcpMaxSessions = effectiveInt(cfg.MaxSessions, defaultMCPMaxSessions)
+	mcpTranscriptsEnabled = cfg.Transcripts
+}
+
+func mcpSessionDir() string {
+	baseDir := getenv("CONDUCTOR_HOME", filepath.Join(os.Getenv("HOME"), ".conductor-kit"))
+	return filepath.Join(baseDir, "sessions")
+}
+
+func mcpSessionPath(id string) string {
+	name := id
+	if !safeSessionFileName.MatchString(id) {
+		sum := sha256.Sum256([]byte(id))
+		name = hex.EncodeToString(sum[:])
+	}
+	return filepath.Join(mcpSessionDir(), name+".json")
+}
+
+// saveMCPSession writes a session file atomically (temp file + rename).
+func saveMCPSession(sess MCPSession) error {
+	path := mcpSessionPath(sess.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".session-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func readMCPSession(path string) (*MCPSession, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sess MCPSession
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+func removeMCPSessionFile(id string) {
+	_ = os.Remove(mcpSessionPath(id))
+}
+
+// readMCPSessionFiles reads every persisted session, skipping files that do
+// not parse.
+func readMCPSessionFiles() []*MCPSession {
+	entries, err := os.ReadDir(mcpSessionDir())
+	if err != nil {
+		return nil
+	}
+	sessions := make([]*MCPSession, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		sess, err := readMCPSession(filepath.Join(mcpSessionDir(), name))
+		if err != nil || sess.ID == "" {
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
+
+// loadMCPSessions reloads persisted sessions into the store, skipping expired
+// files (mcpCleanupExpiredSessions removes them) and evicting the oldest
+// beyond the configured cap.
+func loadMCPSessions() int {
+	files := readMCPSessionFiles()
+	now := time.Now()
+	mcpSessionStoreMu.Lock()
+	defer mcpSessionStoreMu.Unlock()
+	loaded := 0
+	for _, sess := range files {
+		if now.Sub(sess.UpdatedAt) > mcpSessionTTL {
+			continue
+		}
+		if current, ok := mcpSessionStore[sess.ID]; ok && !current.UpdatedAt.Before(sess.UpdatedAt) {
+			continue
+		}
+		mcpSessionStore[sess.ID] = sess
+		loaded++
+	}
+	for len(mcpSessionStore) > mcpMaxSessions {
+		if !mcpEvictOldestSessionLocked() {
+			break
+		}
+	}
+	return loaded
+}
+
+// mcpStoreSession adds a new session, evicting the oldest one at capacity.
+func mcpStoreSession(sess *MCPSession) {
+	mcpSessionStoreMu.Lock()
+	if len(mcpSessionStore) >= mcpMaxSessions {
+		mcpEvictOldestSessionLocked()
+	}
+	mcpSessionStore[sess.ID] = sess
+	snapshot := *sess
+	mcpSessionStoreMu.Unlock()
+	_ = saveMCPSession(snapshot)
+}
+
+// mcpTouchSession bumps UpdatedAt, records the turn outcome and persists the
+// session.
+func mcpTouchSession(sess *MCPSession, status string) {
+	mcpSessionStoreMu.Lock()
+	sess.UpdatedAt = time.Now()
+	sess.LastStatus = status
+	snapshot := *sess
+	mcpSessionStoreMu.Unlock()
+	_ = saveMCPSession(snapshot)
+}
+
+// mcpLookupSession finds a session in memory, falling back to its file so a
+// thread written by another conductor process (or before a restart) resolves.
+func mcpLookupSession(id string) (*MCPSession, bool) {
+	mcpSessionStoreMu.RLock()
+	sess, ok := mcpSessionStore[id]
+	ttl := mcpSessionTTL
+	mcpSessionStoreMu.RUnlock()
+	if ok {
+		return sess, true
+	}
+	sess, err := readMCPSession(mcpSessionPath(id))
+	if err != nil || sess.ID != id {
+		return nil, false
+	}
+	if time.Since(sess.UpdatedAt) > ttl {
+		removeMCPSessionFile(id)
+		return nil, false
+	}
+	mcpStoreSession(sess)
+	return sess, true
+}
+
+// mcpSessionCleanupLoop periodically removes expired sessions
+func mcpSessionCleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(mcpSessionCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mcpCleanupExpiredSessions()
+		}
+	}
+}
+
+// mcpCleanupExpiredSessions removes sessions that have exceeded TTL, in
+// memory and on disk, and returns their ids.
+func mcpCleanupExpiredSessions() []string {
+	files := readMCPSessionFiles()
+	now := time.Now()
+	mcpSessionStoreMu.Lock()
+	defer mcpSessionStoreMu.Unlock()
+
+	removed := []string{}
+	for id, sess := range mcpSessionStore {
+		if now.Sub(sess.UpdatedAt) > mcpSessionTTL {
+			delete(mcpSessionStore, id)
+			removeMCPSessionFile(id)
+			removed = append(removed, id)
+		}
+	}
+	for _, sess := range files {
+		if _, live := mcpSessionStore[sess.ID]; live || now.Sub(sess.UpdatedAt) <= mcpSessionTTL {
+			continue
+		}
+		removeMCPSessionFile(sess.ID)
+		if !contains(removed, sess.ID) {
+			removed = append(removed, sess.ID)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// mcpEvictOldestSessionLocked drops the least recently updated session from
+// the cache; its file stays, so mcpLookupSession can reload it. Callers must
+// hold mcpSessionStoreMu.
+func mcpEvictOldestSessionLocked() bool {
+	var oldestID string
+	var oldestTime time.Time
+
+	for id, sess := range mcpSessionStore {
+		if oldestID == "" || sess.UpdatedAt.Before(oldestTime) {
+			oldestID = id
+			oldestTime = sess.UpdatedAt
+		}
+	}
+
+	if oldestID == "" {
+		return false
+	}
+	delete(mcpSessionStore, oldestID)
+	return true
+}
+
+// listMCPSessions returns snapshots of the unexpired sessions on disk, so
+// threads written by other conductor processes appear, merged with the cache
+// (the newer copy wins), most recently updated first. Empty cli/role match
+// everything.
+func listMCPSessions(cli, role string) []MCPSession {
+	files := readMCPSessionFiles()
+	mcpSessionStoreMu.RLock()
+	ttl := mcpSessionTTL
+	byID := make(map[string]MCPSession, len(mcpSessionStore)+len(files))
+	for id, sess := range mcpSessionStore {
+		byID[id] = *sess
+	}
+	mcpSessionStoreMu.RUnlock()
+	now := time.Now()
+	for _, sess := range files {
+		if now.Sub(sess.UpdatedAt) > ttl {
+			continue
+		}
+		if current, ok := byID[sess.ID]; ok && !current.UpdatedAt.Before(sess.UpdatedAt) {
+			continue
+		}
+		byID[sess.ID] = *sess
+	}
+
+	sessions := make([]MCPSession, 0, len(byID))
+	for _, sess := range byID {
+		if cli != "" && !strings.EqualFold(sess.CLI, cli) {
+			continue
+		}
+		if role != "" && sess.Role != role {
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].UpdatedAt.Equal(sessions[j].UpdatedAt) {
+			return sessions[i].ID < sessions[j].ID
+		}
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions
+}
+
+// mcpLastSession finds the most recently updated session for a CLI and/or role.
+func mcpLastSession(cli, role string) (*MCPSession, bool) {
+	sessions := listMCPSessions(cli, role)
+	if len(sessions) == 0 {
+		return nil, false
+	}
+	return mcpLookupSession(sessions[0].ID)
+}
+
+// mcpDeleteSession drops a session from memory and disk, along with its
+// transcript.
+func mcpDeleteSession(id string) bool {
+	mcpSessionStoreMu.Lock()
+	_, ok := mcpSessionStore[id]
+	delete(mcpSessionStore, id)
+	mcpSessionStoreMu.Unlock()
+	if _, err := os.Stat(mcpSessionPath(id)); err == nil {
+		ok = true
+	}
+	removeMCPSessionFile(id)
+	mcpTranscriptMu.Lock()
+	_ = os.Remove(mcpTranscriptPath(id))
+	mcpTranscriptMu.Unlock()
+	return ok
+}
+
+func mcpSessionView(sess MCPSession, includeConfig bool) map[string]interface{} {
+	view := map[string]interface{}{
+		"threadId":       sess.ID,
+		"nativeThreadId": sess.NativeThreadID,
+		"cli":            sess.CLI,
+		"role":           sess.Role,
+		"model":          sess.Model,
+		"createdAt":      sess.CreatedAt.Format(time.RFC3339),
+		"updatedAt":      sess.UpdatedAt.Format(time.RFC3339),
+	}
+	if sess.LastStatus != "" {
+		view["lastStatus"] = sess.LastStatus
+	}
+	if sess.HandoffFrom != "" {
+		view["handoffFrom"] = sess.HandoffFrom
+	}
+	if len(sess.HandoffTo) > 0 {
+		view["handoffTo"] = sess.HandoffTo
+	}
+	if includeConfig {
+		mcpSessionStoreMu.RLock()
+		ttl := mcpSessionTTL
+		mcpSessionStoreMu.RUnlock()
+		view["config"] = sess.Config
+		view["expiresAt"] = sess.UpdatedAt.Add(ttl).Format(time.RFC3339)
+	}
+	return view
+}
+
+func mcpSessionListPayload(sessions []MCPSession) []map[string]interface{} {
+	items := make([]map[string]interface{}, 0, len(sessions))
+	for _, sess := range sessions {
+		items = append(items, mcpSessionView(sess, false))
+	}
+	return items
+}
+
+// mcpHandleSessions implements the sessions tool and `conductor sessions`.
+func mcpHandleSessions(input MCPSessionsInput) (map[string]interface{}, error) {
+	action := strings.ToLower(strings.TrimSpace(input.Action))
+	switch action {
+	case "", "list":
+		sessions := listMCPSessions(input.CLI, input.Role)
+		return map[string]interface{}{
+			"status":   "ok",
+			"count":    len(sessions),
+			"sessions": mcpSessionListPayload(sessions),
+		}, nil
+	case "inspect", "get":
+		if input.ThreadID == "" {
+			return nil, fmt.Errorf("threadId is required")
+		}
+		sess, ok := mcpLookupSession(input.ThreadID)
+		if !ok {
+			return nil, fmt.Errorf("thread not found: %s", input.ThreadID)
+		}
+		return mcpSessionView(*sess, true), nil
+	case "last":
+		if input.CLI == "" && input.Role == "" {
+			return nil, fmt.Errorf("role or cli is required")
+		}
+		sess, ok := mcpLastSession(input.CLI, input.Role)
+		if !ok {
+			return nil, fmt.Errorf("no session found for %s", sessionFilterLabel(input.CLI, input.Role))
+		}
+		return mcpSessionView(*sess, true), nil
+	case "delete":
+		if input.ThreadID == "" {
+			return nil, fmt.Errorf("threadId is required")
+		}
+		if !mcpDeleteSession(input.ThreadID) {
+			return nil, fmt.Errorf("thread not found: %s", input.ThreadID)
+		}
+		return map[string]interface{}{
+			"status":   "deleted",
+			"threadId": input.ThreadID,
+		}, nil
+	case "expire":
+		var removed []string
+		if input.ThreadID != "" {
+			if !mcpDeleteSession(input.ThreadID) {
+				return nil, fmt.Errorf("thread not found: %s", input.ThreadID)
+			}
+			removed = []string{input.ThreadID}
+		} else {
+			removed = mcpCleanupExpiredSessions()
+		}
+		return map[string]interface{}{
+			"status":  "expired",
+			"count":   len(removed),
+			"removed": removed,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown sessions action: %s", input.Action)
+	}
+}
+
+func sessionFilterLabel(cli, role string) string {
+	parts := []string{}
+	if role != "" {
+		parts = append(parts, "role "+role)
+	}
+	if cli != "" {
+		parts = append(parts, "cli "+cli)
+	}
+	return strings.Join(parts, ", ")
+}
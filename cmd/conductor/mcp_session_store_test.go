@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func resetMCPSessions(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	clear := func() {
+		mcpSessionStoreMu.Lock()
+		mcpSessionStore = make(map[string]*MCPSession)
+		mcpSessionStoreMu.Unlock()
+		configureMCPSessions(MCPConfig{})
+	}
+	clear()
+	t.Cleanup(clear)
+}
+
+func TestMCPSessionsSurviveRestart(t *testing.T) {
+	resetMCPSessions(t)
+	now := time.Now()
+	mcpStoreSession(&MCPSession{
+		ID:             "thread-1",
+		NativeThreadID: "native-1",
+		CLI:            "codex",
+		Config:         MCPSessionConfig{Sandbox: "read-only", Env: map[string]string{"A": "1"}},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	})
+
+	// Simulate a restart: drop the in-memory index and reload from disk.
+	mcpSessionStoreMu.Lock()
+	mcpSessionStore = make(map[string]*MCPSession)
+	mcpSessionStoreMu.Unlock()
+
+	if got := loadMCPSessions(); got != 1 {
+		t.Fatalf("expected 1 session loaded, got %d", got)
+	}
+	sess, ok := mcpLookupSession("thread-1")
+	if !ok {
+		t.Fatal("expected session after reload")
+	}
+	if sess.NativeThreadID != "native-1" || sess.Config.Sandbox != "read-only" || sess.Config.Env["A"] != "1" {
+		t.Errorf("session not restored: %+v", sess)
+	}
+}
+
+func TestMCPSessionsLoadDropsExpired(t *testing.T) {
+	resetMCPSessions(t)
+	configureMCPSessions(MCPConfig{SessionTTLMs: 1000})
+	old := time.Now().Add(-time.Hour)
+	stale := MCPSession{ID: "stale", CLI: "claude", CreatedAt: old, UpdatedAt: old}
+	if err := saveMCPSession(stale); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	if got := loadMCPSessions(); got != 0 {
+		t.Fatalf("expected expired session to be skipped, loaded %d", got)
+	}
+	if _, err := os.Stat(mcpSessionPath("stale")); err != nil {
+		t.Fatalf("expected loading to leave the expired file for expire, stat err=%v", err)
+	}
+
+	expired, err := mcpHandleSessions(MCPSessionsInput{Action: "expire"})
+	if err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if removed, _ := expired["removed"].([]string); len(removed) != 1 || removed[0] != "stale" {
+		t.Fatalf("expected expire to report stale, got %v", expired["removed"])
+	}
+	if _, err := os.Stat(mcpSessionPath("stale")); !os.IsNotExist(err) {
+		t.Errorf("expected expired session file removed, stat err=%v", err)
+	}
+	if _, ok := mcpLookupSession("stale"); ok {
+		t.Error("expected expired session lookup to fail")
+	}
+}
+
+func TestMCPSessionsMaxEvictsOldest(t *testing.T) {
+	resetMCPSessions(t)
+	configureMCPSessions(MCPConfig{MaxSessions: 2})
+	base := time.Now()
+	for i, id := range []string{"a", "b", "c"} {
+		ts := base.Add(time.Duration(i) * time.Second)
+		mcpStoreSession(&MCPSession{ID: id, CLI: "gemini", CreatedAt: ts, UpdatedAt: ts})
+	}
+
+	mcpSessionStoreMu.RLock()
+	_, cached := mcpSessionStore["a"]
+	size := len(mcpSessionStore)
+	mcpSessionStoreMu.RUnlock()
+	if cached || size != 2 {
+		t.Fatalf("expected oldest session evicted from the cache, cached=%v size=%d", cached, size)
+	}
+	if got := len(listMCPSessions("", "")); got != 3 {
+		t.Errorf("expected all 3 sessions listed from disk, got %d", got)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, ok := mcpLookupSession(id); !ok {
+			t.Errorf("expected session %s to resolve", id)
+		}
+	}
+}
+
+func TestMCPSessionsListIncludesOtherProcesses(t *testing.T) {
+	resetMCPSessions(t)
+	now := time.Now()
+	mcpStoreSession(&MCPSession{ID: "mine", CLI: "codex", CreatedAt: now, UpdatedAt: now})
+	// Written by another conductor process: on disk but never in this cache.
+	other := MCPSession{ID: "theirs", CLI: "claude", CreatedAt: now, UpdatedAt: now.Add(time.Second)}
+	if err := saveMCPSession(other); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	sessions := listMCPSessions("", "")
+	if len(sessions) != 2 || sessions[0].ID != "theirs" {
+		t.Fatalf("expected both sessions, newest first, got %+v", sessions)
+	}
+}
+
+func TestMCPDeleteSessionRemovesTranscript(t *testing.T) {
+	resetMCPSessions(t)
+	configureMCPSessions(MCPConfig{Transcripts: true})
+	now := time.Now()
+	sess := &MCPSession{ID: "t-1", CLI: "codex", CreatedAt: now, UpdatedAt: now}
+	mcpStoreSession(sess)
+	recordTranscriptTurn(sess, "hi", "hi", "hello", now)
+	if _, err := os.Stat(mcpTranscriptPath("t-1")); err != nil {
+		t.Fatalf("expected transcript, stat err=%v", err)
+	}
+
+	if !mcpDeleteSession("t-1") {
+		t.Fatal("expected delete to find the session")
+	}
+	if _, err := os.Stat(mcpTranscriptPath("t-1")); !os.IsNotExist(err) {
+		t.Errorf("expected transcript removed with the session, stat err=%v", err)
+	}
+}
+
+func TestMCPSessionPathHashesUnsafeIDs(t *testing.T) {
+	resetMCPSessions(t)
+	path := mcpSessionPath("../escape")
+	if dir := mcpSessionDir(); len(path) <= len(dir) || path[:len(dir)] != dir {
+		t.Fatalf("expected path under %s, got %s", dir, path)
+	}
+	if path == mcpSessionPath("..-escape") {
+		t.Error("expected distinct paths for distinct ids")
+	}
+}
+
+func TestMCPHandleSessions(t *testing.T) {
+	resetMCPSessions(t)
+	base := time.Now().Add(-time.Minute)
+	seed := []MCPSession{
+		{ID: "t-1", CLI: "codex", Role: "oracle", CreatedAt: base, UpdatedAt: base},
+		{ID: "t-2", CLI: "claude", Role: "explore", CreatedAt: base, UpdatedAt: base.Add(time.Second)},
+		{ID: "t-3", CLI: "codex", Role: "oracle", CreatedAt: base, UpdatedAt: base.Add(2 * time.Second)},
+	}
+	for i := range seed {
+		mcpStoreSession(&seed[i])
+	}
+
+	payload, err := mcpHandleSessions(MCPSessionsInput{CLI: "codex"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if payload["count"] != 2 {
+		t.Fatalf("expected 2 codex sessions, got %v", payload["count"])
+	}
+	items := payload["sessions"].([]map[string]interface{})
+	if items[0]["threadId"] != "t-3" {
+		t.Errorf("expected newest first, got %v", items[0]["threadId"])
+	}
+
+	last, err := mcpHandleSessions(MCPSessionsInput{Action: "last", Role: "oracle"})
+	if err != nil || last["threadId"] != "t-3" {
+		t.Fatalf("expected last oracle session t-3, got %v (%v)", last["threadId"], err)
+	}
+	if _, ok := last["config"]; !ok {
+		t.Error("expected inspect view to include config")
+	}
+
+	if _, err := mcpHandleSessions(MCPSessionsInput{Action: "delete", ThreadID: "t-3"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := mcpHandleSessions(MCPSessionsInput{Action: "inspect", ThreadID: "t-3"}); err == nil {
+		t.Error("expected deleted session to be gone")
+	}
+	if _, err := mcpHandleSessions(MCPSessionsInput{Action: "delete", ThreadID: "t-3"}); err == nil {
+		t.Error("expected error deleting unknown session")
+	}
+
+	configureMCPSessions(MCPConfig{SessionTTLMs: 1})
+	expired, err := mcpHandleSessions(MCPSessionsInput{Action: "expire"})
+	if err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if expired["count"] != 2 {
+		t.Errorf("expected 2 expired sessions, got %v", expired["count"])
+	}
+
+	if _, err := mcpHandleSessions(MCPSessionsInput{Action: "bogus"}); err == nil {
+		t.Error("expected unknown action error")
+	}
+}
+
+func TestReplyResumesLastSessionForRole(t *testing.T) {
+	resetMCPSessions(t)
+	if _, err := mcpRunReply(context.Background(), "", MCPReplyInput{Prompt: "hi", Role: "missing"}); err == nil ||
+		!strings.Contains(err.Error(), "no session found for role missing") {
+		t.Fatalf("expected missing role error, got %v", err)
+	}
+	if _, err := mcpRunReply(context.Background(), "", MCPReplyInput{Prompt: "hi"}); err == nil ||
+		!strings.Contains(err.Error(), "threadId is required") {
+		t.Fatalf("expected threadId required error, got %v", err)
+	}
+}
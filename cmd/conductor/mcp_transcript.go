@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transcripts are opt-in (mcp.transcripts in conductor.json). Each thread gets
+// CONDUCTOR_HOME/transcripts/<threadId>.jsonl with one line per turn; sessions
+// themselves still carry no message history.
+var (
+	mcpTranscriptsEnabled bool
+	mcpTranscriptMu       sync.Mutex
+)
+
+// TranscriptTurn is one recorded prompt/response pair.
+type TranscriptTurn struct {
+	Turn       int       `json:"turn"`
+	ThreadID   string    `json:"thread_id"`
+	CLI        string    `json:"cli"`
+	Role       string    `json:"role,omitempty"`
+	Model      string    `json:"model,omitempty"`
+	Prompt     string    `json:"prompt"`
+	Memory     string    `json:"memory,omitempty"`
+	Response   string    `json:"response"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// MCPTranscriptInput for the transcript tool
+type MCPTranscriptInput struct {
+	ThreadID string `json:"threadId"`
+	Format   string `json:"format,omitempty"`
+}
+
+func mcpTranscriptDir() string {
+	baseDir := getenv("CONDUCTOR_HOME", filepath.Join(os.Getenv("HOME"), ".conductor-kit"))
+	return filepath.Join(baseDir, "transcripts")
+}
+
+func mcpTranscriptPath(threadID string) string {
+	name := strings.TrimSuffix(filepath.Base(mcpSessionPath(threadID)), ".json")
+	return filepath.Join(mcpTranscriptDir(), name+".jsonl")
+}
+
+// injectedMemory returns what applyMemoryToPrompt/applySharedMemory added
+// around the caller's prompt. Memory is prepended or appended, so the last
+// occurrence of the prompt marks where it sits in the sent text.
+func injectedMemory(prompt, sent string) string {
+	i := strings.LastIndex(sent, prompt)
+	if prompt == "" || i < 0 {
+		return ""
+	}
+	parts := []string{}
+	for _, part := range []string{sent[:i], sent[i+len(prompt):]} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// recordTranscriptTurn appends a turn when transcripts are enabled, numbering
+// it from the session's turn counter. Failures are ignored so recording never
+// breaks a session.
+func recordTranscriptTurn(sess *MCPSession, prompt, sentPrompt, response string, startedAt time.Time) {
+	mcpSessionStoreMu.RLock()
+	enabled := mcpTranscriptsEnabled
+	mcpSessionStoreMu.RUnlock()
+	if !enabled {
+		return
+	}
+	memory := injectedMemory(prompt, sentPrompt)
+
+	mcpTranscriptMu.Lock()
+	defer mcpTranscriptMu.Unlock()
+	mcpSessionStoreMu.Lock()
+	sess.Turns++
+	snapshot := *sess
+	mcpSessionStoreMu.Unlock()
+	_ = saveMCPSession(snapshot)
+
+	turn := TranscriptTurn{
+		Turn:       snapshot.Turns,
+		ThreadID:   snapshot.ID,
+		CLI:        snapshot.CLI,
+		Role:       snapshot.Role,
+		Model:      snapshot.Model,
+		Prompt:     prompt,
+		Memory:     memory,
+		Response:   response,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+	data, err := json.Marshal(turn)
+	if err != nil {
+		return
+	}
+	path := mcpTranscriptPath(snapshot.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	_, _ = file.Write(append(data, '\n'))
+}
+
+func readTranscript(threadID string) ([]TranscriptTurn, error) {
+	file, err := os.Open(mcpTranscriptPath(threadID))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	turns := []TranscriptTurn{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var turn TranscriptTurn
+		if err := json.Unmarshal([]byte(line), &turn); err != nil {
+			continue
+		}
+		turns = append(turns, turn)
+	}
+	return turns, scanner.Err()
+}
+
+func renderTranscriptMarkdown(threadID string, turns []TranscriptTurn) string {
+	var sb strings.Builder
+	sb.WriteString("# Transcript " + threadID + "\n")
+	if len(turns) > 0 {
+		first := turns[0]
+		meta := []string{"CLI: " + first.CLI}
+		if first.Role != "" {
+			meta = append(meta, "Role: "+first.Role)
+		}
+		if first.Model != "" {
+			meta = append(meta, "Model: "+first.Model)
+		}
+		sb.WriteString("\n" + strings.Join(meta, " · ") + "\n")
+	}
+	for _, turn := range turns {
+		sb.WriteString(fmt.Sprintf("\n## Turn %d — %s\n", turn.Turn, turn.StartedAt.Format(time.RFC3339)))
+		sb.WriteString("\n### Prompt\n\n" + turn.Prompt + "\n")
+		if turn.Memory != "" {
+			sb.WriteString("\n### Injected memory\n\n```\n" + turn.Memory + "\n```\n")
+		}
+		sb.WriteString(fmt.Sprintf("\n### Response (%s)\n\n%s\n", turn.FinishedAt.Format(time.RFC3339), turn.Response))
+	}
+	return sb.String()
+}
+
+// mcpExportTranscript implements the transcript tool and `conductor transcript`.
+func mcpExportTranscript(input MCPTranscriptInput) (map[string]interface{}, error) {
+	if input.ThreadID == "" {
+		return nil, fmt.Errorf("threadId is required")
+	}
+	turns, err := readTranscript(input.ThreadID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no transcript for thread: %s (enable mcp.transcripts in conductor.json)", input.ThreadID)
+		}
+		return nil, err
+	}
+
+	format := strings.ToLower(strings.TrimSpace(input.Format))
+	payload := map[string]interface{}{
+		"threadId": input.ThreadID,
+		"turns":    len(turns),
+		"path":     mcpTranscriptPath(input.ThreadID),
+	}
+	switch format {
+	case "", "markdown", "md":
+		payload["format"] = "markdown"
+		payload["content"] = renderTranscriptMarkdown(input.ThreadID, turns)
+	case "json":
+		data, err := json.MarshalIndent(turns, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		payload["format"] = "json"
+		payload["content"] = string(data)
+	default:
+		return nil, fmt.Errorf("unknown transcript format: %s (use markdown or json)", input.Format)
+	}
+	return payload, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInjectedMemory(t *testing.T) {
+	global := memoryBlock("global", "earlier answer: what next?")
+	notes := memoryBlock("notes", "a\nb")
+	memory := injectedMemory("what next?", global+"\n\nwhat next?\n\n"+notes)
+	if memory != global+"\n\n"+notes {
+		t.Errorf("expected both memory blocks, got %q", memory)
+	}
+	if memory := injectedMemory("plain", "plain"); memory != "" {
+		t.Errorf("expected no memory for plain prompt, got %q", memory)
+	}
+}
+
+func TestTranscriptDisabledByDefault(t *testing.T) {
+	resetMCPSessions(t)
+	recordTranscriptTurn(&MCPSession{ID: "t-1", CLI: "codex"}, "hi", "hi", "hello", time.Now())
+	if _, err := os.Stat(mcpTranscriptPath("t-1")); !os.IsNotExist(err) {
+		t.Fatalf("expected no transcript when disabled, stat err=%v", err)
+	}
+	if _, err := mcpExportTranscript(MCPTranscriptInput{ThreadID: "t-1"}); err == nil {
+		t.Error("expected export error for missing transcript")
+	}
+}
+
+func TestTranscriptRecordsSessionTurns(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeSessionRuntimeConfig(t, "")
+	resetMCPSessions(t)
+	configureMCPSessions(MCPConfig{Transcripts: true})
+	installFakeCLI(t, "codex", fakeCodexSession)
+
+	sent := memoryBlock("review", "focus on errors") + "\n\nfirst question"
+	if _, err := mcpRunSessionWithConfig(context.Background(), "codex", "", "", "first question", sent, []string{"exec", "--json", sent}, 0, MCPSessionConfig{}); err != nil {
+		t.Fatalf("mcpRunSessionWithConfig: %v", err)
+	}
+	if _, err := mcpRunReply(context.Background(), "", MCPReplyInput{Prompt: "second question", ThreadID: "native-1"}); err != nil {
+		t.Fatalf("mcpRunReply: %v", err)
+	}
+
+	payload, err := mcpExportTranscript(MCPTranscriptInput{ThreadID: "native-1", Format: "json"})
+	if err != nil {
+		t.Fatalf("export json: %v", err)
+	}
+	var turns []TranscriptTurn
+	if err := json.Unmarshal([]byte(payload["content"].(string)), &turns); err != nil {
+		t.Fatalf("decode turns: %v", err)
+	}
+	if len(turns) != 2 {
+		t.Fatalf("expected 2 turns, got %d", len(turns))
+	}
+	first := turns[0]
+	if first.Turn != 1 || first.Prompt != "first question" || first.Response != "done" || first.CLI != "codex" {
+		t.Errorf("unexpected first turn: %+v", first)
+	}
+	if !strings.Contains(first.Memory, "focus on errors") {
+		t.Errorf("expected injected memory recorded, got %q", first.Memory)
+	}
+	if first.StartedAt.IsZero() || first.FinishedAt.Before(first.StartedAt) {
+		t.Errorf("expected ordered timestamps, got %v..%v", first.StartedAt, first.FinishedAt)
+	}
+	if turns[1].Turn != 2 || turns[1].Prompt != "second question" {
+		t.Errorf("unexpected second turn: %+v", turns[1])
+	}
+	if sess, ok := mcpLookupSession("native-1"); !ok || sess.Turns != 2 {
+		t.Errorf("expected session turn counter 2, got %+v", sess)
+	}
+	for path, want := range map[string]os.FileMode{mcpTranscriptPath("native-1"): 0o600, mcpTranscriptDir(): 0o700} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("expected %s to have mode %o, got %o", path, want, info.Mode().Perm())
+		}
+	}
+
+	md, err := mcpExportTranscript(MCPTranscriptInput{ThreadID: "native-1"})
+	if err != nil {
+		t.Fatalf("export markdown: %v", err)
+	}
+	content := md["content"].(string)
+	for _, want := range []string{"# Transcript native-1", "## Turn 1", "## Turn 2", "### Injected memory", "first question", "done"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", want, content)
+		}
+	}
+
+	if _, err := mcpExportTranscript(MCPTranscriptInput{ThreadID: "native-1", Format: "xml"}); err == nil {
+		t.Error("expected unknown format error")
+	}
+}
@@ -7,6 +7,8 @@ type BatchInput struct {
 	Reasoning       string `json:"reasoning,omitempty"`
 	Config          string `json:"config,omitempty"`
 	IdleTimeoutMs   int    `json:"idle_timeout_ms,omitempty"`
+	TimeoutMs       int    `json:"timeout_ms,omitempty"`
+	DeadlineMs      int    `json:"deadline_ms,omitempty"` // whole batch, queueing included
 	SummaryOnly     *bool  `json:"summary_only,omitempty"`
 	RequireApproval bool   `json:"require_approval,omitempty"`
 	Mode            string `json:"mode,omitempty"`
@@ -20,6 +22,7 @@ type RunInput struct {
 	Reasoning       string `json:"reasoning,omitempty"`
 	Config          string `json:"config,omitempty"`
 	IdleTimeoutMs   int    `json:"idle_timeout_ms,omitempty"`
+	TimeoutMs       int    `json:"timeout_ms,omitempty"`
 	SummaryOnly     *bool  `json:"summary_only,omitempty"`
 	RequireApproval bool   `json:"require_approval,omitempty"`
 	Mode            string `json:"mode,omitempty"`
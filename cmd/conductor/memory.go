@@ -116,6 +116,7 @@ func (m *memoryStore) setInternal(key, value string, truncate bool) (memoryEntry
 	snapshot := cloneMemoryEntries(m.items)
 	m.mu.Unlock()
 	sharedMemoryCache.persist(snapshot, false)
+	notifyResourceUpdated(memoryResourceURI(key))
 	return entry, nil
 }
 
@@ -147,6 +148,7 @@ func (m *memoryStore) clear(key string) bool {
 		snapshot := cloneMemoryEntries(m.items)
 		m.mu.Unlock()
 		sharedMemoryCache.persist(snapshot, false)
+		notifyResourceUpdated(memoryResourceURI(key))
 		return true
 	}
 	m.mu.Unlock()
@@ -156,10 +158,17 @@ func (m *memoryStore) clear(key string) bool {
 func (m *memoryStore) clearAll() int {
 	m.mu.Lock()
 	count := len(m.items)
+	cleared := make([]string, 0, count)
+	for key := range m.items {
+		cleared = append(cleared, key)
+	}
 	m.items = make(map[string]memoryEntry)
 	snapshot := cloneMemoryEntries(m.items)
 	m.mu.Unlock()
 	sharedMemoryCache.persist(snapshot, true)
+	for _, key := range cleared {
+		notifyResourceUpdated(memoryResourceURI(key))
+	}
 	return count
 }
 
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultKillGraceMs = 2000
+
+// killGrace is how long a process group gets between SIGTERM and SIGKILL.
+func killGrace(graceMs int) time.Duration {
+	if graceMs <= 0 {
+		graceMs = defaultKillGraceMs
+	}
+	return time.Duration(graceMs) * time.Millisecond
+}
+
+// startInProcessGroup puts cmd in its own process group and makes context
+// cancellation terminate the whole group, so grandchildren spawned by a CLI
+// (shells, language servers, test runners) stop with it. Wait also stops
+// reading output grace after the CLI exits, so a grandchild that inherited
+// stdout cannot hold the run open; see waitInProcessGroup.
+func startInProcessGroup(cmd *exec.Cmd, grace time.Duration) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return terminateProcessGroup(cmd.Process.Pid, grace)
+	}
+	cmd.WaitDelay = grace
+}
+
+// waitInProcessGroup waits for a command started with startInProcessGroup.
+// A CLI that exited cleanly while a grandchild still held its output open is
+// not a failure; releasing the group afterwards stops the grandchild.
+func waitInProcessGroup(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		return nil
+	}
+	return err
+}
+
+// killProcessGroup signals the group led by pid, falling back to the single
+// process when it was not started with startInProcessGroup.
+func killProcessGroup(pid int, sig syscall.Signal) error {
+	if pid <= 0 {
+		return syscall.ESRCH
+	}
+	if err := syscall.Kill(-pid, sig); err == nil {
+		return nil
+	}
+	return syscall.Kill(pid, sig)
+}
+
+// terminateProcessGroup asks the group to exit with SIGTERM and sends SIGKILL
+// to whatever is left after grace, without waiting for it.
+func terminateProcessGroup(pid int, grace time.Duration) error {
+	if err := killProcessGroup(pid, syscall.SIGTERM); err != nil {
+		return err
+	}
+	time.AfterFunc(grace, func() {
+		if processGroupAlive(pid) {
+			_ = syscall.Kill(-pid, syscall.SIGKILL)
+		}
+	})
+	return nil
+}
+
+// stopProcessGroup is terminateProcessGroup for callers that must not return
+// before the group is gone, such as doctor reaping orphans before it exits.
+func stopProcessGroup(pid int, grace time.Duration) error {
+	if err := killProcessGroup(pid, syscall.SIGTERM); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		if !processGroupAlive(pid) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	_ = syscall.Kill(-pid, syscall.SIGKILL)
+	return nil
+}
+
+func processGroupAlive(pgid int) bool {
+	if pgid <= 0 {
+		return false
+	}
+	err := syscall.Kill(-pgid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// ProcessGroupRecord marks a CLI process group owned by a conductor process.
+// It is removed when the CLI exits; one whose owner is gone while the group
+// still runs is an orphan that `conductor doctor` offers to reap.
+type ProcessGroupRecord struct {
+	PGID        int    `json:"pgid"`
+	OwnerPID    int    `json:"owner_pid"`
+	RunID       string `json:"run_id,omitempty"`
+	Cmd         string `json:"cmd"`
+	Comm        string `json:"comm,omitempty"`       // leader's command name as the OS reported it
+	ProcStart   string `json:"proc_start,omitempty"` // leader's start time as the OS reported it
+	StartedAt   string `json:"started_at"`
+	KillGraceMs int    `json:"kill_grace_ms"`
+}
+
+func processGroupDir() string {
+	baseDir := getenv("CONDUCTOR_HOME", filepath.Join(os.Getenv("HOME"), ".conductor-kit"))
+	return filepath.Join(baseDir, "procs")
+}
+
+func processGroupPath(pgid int) string {
+	return filepath.Join(processGroupDir(), strconv.Itoa(pgid)+".json")
+}
+
+// processStart returns when pid started and its command name, read from
+// /proc/<pid>/stat or, without procfs, from ps. ok is false once pid is gone.
+func processStart(pid int) (start, comm string, ok bool) {
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid)); err == nil {
+		// The command name is parenthesized and may contain spaces; the
+		// start time is the 20th field after it.
+		text := string(data)
+		open, end := strings.IndexByte(text, '('), strings.LastIndexByte(text, ')')
+		if open < 0 || end < open {
+			return "", "", false
+		}
+		fields := strings.Fields(text[end+1:])
+		if len(fields) < 20 {
+			return "", "", false
+		}
+		return fields[19], text[open+1 : end], true
+	}
+	if _, err := os.Stat("/proc/self/stat"); err == nil {
+		return "", "", false
+	}
+	out, err := exec.Command("ps", "-o", "lstart=,comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", "", false
+	}
+	// lstart is five words, such as "Sat Oct 17 10:00:00 2026".
+	fields := strings.Fields(string(out))
+	if len(fields) < 6 {
+		return "", "", false
+	}
+	return strings.Join(fields[:5], " "), strings.Join(fields[5:], " "), true
+}
+
+// ownsProcessGroup reports whether the group of record still runs and is the
+// one conductor started. A running leader must have the recorded start time,
+// or its PID was reused by an unrelated process; the command name is not
+// compared because CLI wrappers exec into node or python right after start.
+// Once the leader exited, its ID cannot be reused while the group has members.
+func ownsProcessGroup(record ProcessGroupRecord) bool {
+	if !processGroupAlive(record.PGID) {
+		return false
+	}
+	start, _, ok := processStart(record.PGID)
+	if !ok {
+		return true
+	}
+	return record.ProcStart != "" && start == record.ProcStart
+}
+
+// watchProcessGroup records the group of a started cmd. The returned func,
+// called once cmd has been waited for, terminates processes that outlived
+// the CLI in its group and drops the record.
+func watchProcessGroup(cmd *exec.Cmd, runID string, grace time.Duration) func() {
+	pgid := cmd.Process.Pid
+	record := ProcessGroupRecord{
+		PGID:        pgid,
+		OwnerPID:    os.Getpid(),
+		RunID:       runID,
+		Cmd:         cmd.Path,
+		StartedAt:   time.Now().UTC().Format(time.RFC3339),
+		KillGraceMs: int(grace / time.Millisecond),
+	}
+	record.ProcStart, record.Comm, _ = processStart(pgid)
+	path := processGroupPath(pgid)
+	if data, err := json.Marshal(record); err == nil && os.MkdirAll(filepath.Dir(path), 0o700) == nil {
+		_ = os.WriteFile(path, data, 0o600)
+	}
+	return func() {
+		if processGroupAlive(pgid) {
+			_ = terminateProcessGroup(pgid, grace)
+		}
+		_ = os.Remove(path)
+	}
+}
+
+// findOrphanedProcessGroups lists recorded groups whose conductor process is
+// gone but which still have running processes. Records of groups that have
+// exited, or whose PID now belongs to another process, are removed.
+func findOrphanedProcessGroups() ([]ProcessGroupRecord, error) {
+	entries, err := os.ReadDir(processGroupDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	orphans := []ProcessGroupRecord{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(processGroupDir(), entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var record ProcessGroupRecord
+		if err := json.Unmarshal(data, &record); err != nil || record.PGID <= 0 {
+			_ = os.Remove(path)
+			continue
+		}
+		if isRunning(record.OwnerPID) {
+			continue
+		}
+		if !ownsProcessGroup(record) {
+			_ = os.Remove(path)
+			continue
+		}
+		orphans = append(orphans, record)
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].StartedAt < orphans[j].StartedAt })
+	return orphans, nil
+}
+
+// reapProcessGroup stops an orphaned group and drops its record. A group that
+// exited or was replaced since it was listed is left alone.
+func reapProcessGroup(record ProcessGroupRecord) error {
+	if !ownsProcessGroup(record) {
+		_ = os.Remove(processGroupPath(record.PGID))
+		return nil
+	}
+	if err := stopProcessGroup(record.PGID, killGrace(record.KillGraceMs)); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	_ = os.Remove(processGroupPath(record.PGID))
+	return nil
+}
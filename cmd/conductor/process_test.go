@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeSpawningCLI leaves a grandchild behind (holding stdout open) and records
+// its PID, like codex or gemini running a shell command.
+const fakeSpawningCLI = `sleep 30 &
+echo $! > "$GRANDCHILD_PID_FILE"
+echo '{"type":"thread.started","thread_id":"native-1"}'
+sleep 30
+`
+
+func waitForPIDFile(t *testing.T, path string) int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(path); err == nil {
+			if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+				return pid
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("grandchild pid not written to %s", path)
+	return 0
+}
+
+func assertProcessGone(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if !isRunning(pid) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	_ = killProcessGroup(pid, 9)
+	t.Fatalf("grandchild %d still running after cancel", pid)
+}
+
+func TestCLIAdapterCancelKillsProcessGroup(t *testing.T) {
+	installFakeCLI(t, "codex", fakeSpawningCLI)
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	t.Setenv("GRANDCHILD_PID_FILE", pidFile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := (&CLIAdapter{Name: "Codex", Cmd: "codex"}).Run(ctx, CLIRunOptions{Args: []string{"exec"}})
+		errCh <- err
+	}()
+	pid := waitForPIDFile(t, pidFile)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("expected error after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return promptly after cancel")
+	}
+	assertProcessGone(t, pid)
+}
+
+func TestSessionCancelRecordsCanceled(t *testing.T) {
+	resetRuntime()
+	defer resetRuntime()
+	writeSessionRuntimeConfig(t, "")
+	resetMCPSessions(t)
+	installFakeCLI(t, "codex", fakeSpawningCLI)
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	t.Setenv("GRANDCHILD_PID_FILE", pidFile)
+
+	now := time.Now()
+	mcpStoreSession(&MCPSession{ID: "native-1", NativeThreadID: "native-1", CLI: "codex", CreatedAt: now, UpdatedAt: now})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := mcpRunReply(ctx, "", MCPReplyInput{Prompt: "keep going", ThreadID: "native-1"})
+		errCh <- err
+	}()
+	pid := waitForPIDFile(t, pidFile)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("expected canceled reply to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reply did not return promptly after cancel")
+	}
+	assertProcessGone(t, pid)
+
+	sess, _ := mcpLookupSession("native-1")
+	if sess == nil || sess.LastStatus != "canceled" {
+		t.Errorf("expected session last status canceled, got %+v", sess)
+	}
+	records, err := readRunHistory(0, "canceled", "", "codex")
+	if err != nil {
+		t.Fatalf("readRunHistory: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected one canceled run record, got %v", records)
+	}
+}
+
+func TestCancelTerminatesGracefullyThenKills(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	installFakeCLI(t, "codex", `trap 'echo bye > "`+marker+`"; exit 0' TERM
+sleep 30 &
+echo $! > "$GRANDCHILD_PID_FILE"
+wait
+`)
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	t.Setenv("GRANDCHILD_PID_FILE", pidFile)
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := mcpCodexAdapter.Run(ctx, CLIRunOptions{KillGraceMs: 5000})
+		errCh <- err
+	}()
+	pid := waitForPIDFile(t, pidFile)
+	cancel()
+	select {
+	case <-errCh:
+	case <-time.After(3 * time.Second):
+		t.Fatal("SIGTERM did not stop the CLI before the grace period ended")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("expected the CLI to handle SIGTERM: %v", err)
+	}
+	assertProcessGone(t, pid)
+
+	installFakeCLI(t, "codex", `trap '' TERM
+echo $$ > "$GRANDCHILD_PID_FILE"
+while true; do sleep 0.05; done
+`)
+	_ = os.Remove(pidFile)
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		_, err := mcpCodexAdapter.Run(ctx, CLIRunOptions{KillGraceMs: 300})
+		errCh <- err
+	}()
+	pid = waitForPIDFile(t, pidFile)
+	cancel()
+	select {
+	case <-errCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGKILL did not follow the grace period")
+	}
+	assertProcessGone(t, pid)
+}
+
+func TestRunReapsLeftoverProcesses(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	installFakeCLI(t, "gemini", `sleep 30 >/dev/null 2>&1 &
+echo $! > "$GRANDCHILD_PID_FILE"
+echo done
+`)
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	t.Setenv("GRANDCHILD_PID_FILE", pidFile)
+
+	res, err := runCommand(CmdSpec{Agent: "gemini", Cmd: "gemini", KillGraceMs: 300})
+	if err != nil || res["status"] != "ok" {
+		t.Fatalf("expected ok, got %v (%v)", res, err)
+	}
+	assertProcessGone(t, waitForPIDFile(t, pidFile))
+	if entries, _ := os.ReadDir(processGroupDir()); len(entries) != 0 {
+		t.Fatalf("expected no process group records after the run, got %d", len(entries))
+	}
+}
+
+func TestRunFinishesWhenGrandchildHoldsStdout(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	installFakeCLI(t, "gemini", `sleep 30 &
+echo $! > "$GRANDCHILD_PID_FILE"
+echo done
+`)
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	t.Setenv("GRANDCHILD_PID_FILE", pidFile)
+
+	start := time.Now()
+	res, err := runCommand(CmdSpec{Agent: "gemini", Cmd: "gemini", IdleTimeoutMs: 10000, KillGraceMs: 300})
+	if err != nil || res["status"] != "ok" || res["stdout"] != "done" {
+		t.Fatalf("expected ok with output, got %v (%v)", res, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("run waited %v on the grandchild's stdout", elapsed)
+	}
+	assertProcessGone(t, waitForPIDFile(t, pidFile))
+
+	out, err := (&CLIAdapter{Name: "Gemini", Cmd: "gemini"}).Run(context.Background(), CLIRunOptions{IdleTimeoutMs: 10000, KillGraceMs: 300})
+	if err != nil || out != "done" {
+		t.Fatalf("expected adapter output done, got %q (%v)", out, err)
+	}
+	assertProcessGone(t, waitForPIDFile(t, pidFile))
+}
+
+func TestWatchProcessGroupRecordsLeader(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	cmd := exec.Command("sleep", "30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	release := watchProcessGroup(cmd, "run-1", 100*time.Millisecond)
+	defer func() {
+		release()
+		_ = cmd.Wait()
+	}()
+
+	path := processGroupPath(cmd.Process.Pid)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read record: %v", err)
+	}
+	var record ProcessGroupRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	if record.ProcStart == "" || record.Comm != "sleep" || !ownsProcessGroup(record) {
+		t.Fatalf("expected the leader's start time and command, got %+v", record)
+	}
+	for _, check := range []struct {
+		path string
+		mode os.FileMode
+	}{{path, 0o600}, {processGroupDir(), 0o700}} {
+		info, err := os.Stat(check.path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", check.path, err)
+		}
+		if info.Mode().Perm() != check.mode {
+			t.Fatalf("expected %s to be %o, got %o", check.path, check.mode, info.Mode().Perm())
+		}
+	}
+}
+
+func TestFindAndReapOrphanedProcessGroups(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	dead := exec.Command("true")
+	if err := dead.Run(); err != nil {
+		t.Fatalf("run true: %v", err)
+	}
+	orphan := exec.Command("sleep", "30")
+	orphan.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := orphan.Start(); err != nil {
+		t.Fatalf("start orphan: %v", err)
+	}
+	go func() { _ = orphan.Wait() }()
+	defer func() { _ = killProcessGroup(orphan.Process.Pid, syscall.SIGKILL) }()
+	// reused stands for an unrelated group that got the PID of a recorded one.
+	reused := exec.Command("sleep", "30")
+	reused.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := reused.Start(); err != nil {
+		t.Fatalf("start reused: %v", err)
+	}
+	go func() { _ = reused.Wait() }()
+	defer func() { _ = killProcessGroup(reused.Process.Pid, syscall.SIGKILL) }()
+	start, _, ok := processStart(orphan.Process.Pid)
+	if !ok {
+		t.Fatal("expected the start time of a running process")
+	}
+
+	records := []ProcessGroupRecord{
+		{PGID: orphan.Process.Pid, OwnerPID: dead.Process.Pid, RunID: "run-orphan", Cmd: "sleep", ProcStart: start},
+		{PGID: orphan.Process.Pid + 100000, OwnerPID: dead.Process.Pid, Cmd: "gone"},
+		{PGID: os.Getpid(), OwnerPID: os.Getpid(), Cmd: "owned"},
+		{PGID: reused.Process.Pid, OwnerPID: dead.Process.Pid, Cmd: "sleep", ProcStart: "1"},
+	}
+	if err := os.MkdirAll(processGroupDir(), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, record := range records {
+		data, _ := json.Marshal(record)
+		if err := os.WriteFile(processGroupPath(record.PGID), data, 0o644); err != nil {
+			t.Fatalf("write record: %v", err)
+		}
+	}
+
+	orphans, err := findOrphanedProcessGroups()
+	if err != nil || len(orphans) != 1 || orphans[0].RunID != "run-orphan" {
+		t.Fatalf("expected one orphan, got %+v (%v)", orphans, err)
+	}
+	if _, err := os.Stat(processGroupPath(records[1].PGID)); !os.IsNotExist(err) {
+		t.Fatalf("expected the record of an exited group to be removed, got %v", err)
+	}
+	if _, err := os.Stat(processGroupPath(records[3].PGID)); !os.IsNotExist(err) {
+		t.Fatalf("expected the record of a reused PID to be removed, got %v", err)
+	}
+	if err := reapProcessGroup(records[3]); err != nil || !processGroupAlive(reused.Process.Pid) {
+		t.Fatalf("expected a reused PID to be left alone, got %v", err)
+	}
+	if err := reapProcessGroup(orphans[0]); err != nil {
+		t.Fatalf("reap: %v", err)
+	}
+	assertProcessGone(t, orphan.Process.Pid)
+	if orphans, _ := findOrphanedProcessGroups(); len(orphans) != 0 {
+		t.Fatalf("expected no orphans after reaping, got %+v", orphans)
+	}
+}
@@ -1,3 +1,241 @@
 package main
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
 type progressReporter func(message string, progress float64, total float64)
+
+type progressContextKey struct{}
+
+// withProgressReporter attaches report to ctx so session tools can stream
+// delegate events down to the CLI adapter.
+func withProgressReporter(ctx context.Context, report progressReporter) context.Context {
+	if report == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressContextKey{}, report)
+}
+
+func progressReporterFromContext(ctx context.Context) progressReporter {
+	report, _ := ctx.Value(progressContextKey{}).(progressReporter)
+	return report
+}
+
+// Progress messages are one line; long text is cut here.
+const progressMessageMaxChars = 160
+
+// cliEventReporter turns CLI output lines into progress notifications. The
+// counter is shared across retries so progress keeps increasing.
+type cliEventReporter struct {
+	mu     sync.Mutex
+	cli    string
+	report progressReporter
+	count  int
+}
+
+func newCLIEventReporter(cli string, report progressReporter) *cliEventReporter {
+	if report == nil {
+		return nil
+	}
+	return &cliEventReporter{cli: cli, report: report}
+}
+
+func (r *cliEventReporter) line(line string) {
+	message, ok := describeCLIEvent(r.cli, line)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	r.count++
+	count := r.count
+	r.mu.Unlock()
+	r.report(message, float64(count), 0)
+}
+
+// lineWriter calls fn for every complete line written to it.
+type lineWriter struct {
+	buf []byte
+	fn  func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush emits a trailing line that had no newline.
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.fn(string(w.buf))
+		w.buf = nil
+	}
+}
+
+// describeCLIEvent renders a --json (codex) or stream-json (claude, gemini)
+// event as a short human-readable message. Bookkeeping events are skipped.
+func describeCLIEvent(cli, line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return "", false
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return "", false
+	}
+	var message string
+	switch cli {
+	case "codex":
+		message = describeCodexEvent(event)
+	case "claude":
+		message = describeClaudeEvent(event)
+	case "gemini":
+		message = describeGeminiEvent(event)
+	}
+	if message == "" {
+		return "", false
+	}
+	return cli + ": " + truncateProgressMessage(message), true
+}
+
+func describeCodexEvent(event map[string]interface{}) string {
+	eventType := stringField(event, "type")
+	item, _ := event["item"].(map[string]interface{})
+	switch eventType {
+	case "thread.started":
+		return "session started"
+	case "turn.failed", "error":
+		return "error: " + firstNonEmpty(stringField(event, "message"), nestedString(event, "error", "message"))
+	case "item.started", "item.completed":
+	default:
+		return ""
+	}
+	if item == nil {
+		return ""
+	}
+	completed := eventType == "item.completed"
+	switch stringField(item, "type") {
+	case "command_execution":
+		if completed {
+			return "ran " + stringField(item, "command")
+		}
+		return "running " + stringField(item, "command")
+	case "file_change":
+		if !completed {
+			return ""
+		}
+		changes, _ := item["changes"].([]interface{})
+		paths := []string{}
+		for _, change := range changes {
+			if entry, ok := change.(map[string]interface{}); ok {
+				paths = append(paths, stringField(entry, "path"))
+			}
+		}
+		return "edited " + strings.Join(paths, ", ")
+	case "mcp_tool_call":
+		if completed {
+			return ""
+		}
+		return fmt.Sprintf("calling %s.%s", stringField(item, "server"), stringField(item, "tool"))
+	case "web_search":
+		if completed {
+			return ""
+		}
+		return "searching " + stringField(item, "query")
+	case "agent_message":
+		if completed {
+			return stringField(item, "text")
+		}
+	case "reasoning":
+		if completed {
+			return "thinking: " + stringField(item, "text")
+		}
+	}
+	return ""
+}
+
+func describeClaudeEvent(event map[string]interface{}) string {
+	switch stringField(event, "type") {
+	case "system":
+		if stringField(event, "subtype") == "init" {
+			return "session started"
+		}
+	case "assistant":
+		message, _ := event["message"].(map[string]interface{})
+		content, _ := message["content"].([]interface{})
+		parts := []string{}
+		for _, block := range content {
+			entry, ok := block.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch stringField(entry, "type") {
+			case "text":
+				parts = append(parts, stringField(entry, "text"))
+			case "tool_use":
+				input, _ := entry["input"].(map[string]interface{})
+				parts = append(parts, describeToolUse(stringField(entry, "name"), input))
+			}
+		}
+		return strings.Join(parts, "; ")
+	case "result":
+		if stringField(event, "subtype") != "success" {
+			return "error: " + stringField(event, "subtype")
+		}
+	}
+	return ""
+}
+
+func describeGeminiEvent(event map[string]interface{}) string {
+	switch stringField(event, "type") {
+	case "init":
+		return "session started"
+	case "message":
+		if stringField(event, "role") == "assistant" {
+			return stringField(event, "content")
+		}
+	case "tool_use":
+		parameters, _ := event["parameters"].(map[string]interface{})
+		return describeToolUse(stringField(event, "tool_name"), parameters)
+	case "error":
+		return "error: " + stringField(event, "message")
+	}
+	return ""
+}
+
+// describeToolUse names a tool call plus its most telling argument.
+func describeToolUse(name string, input map[string]interface{}) string {
+	for _, key := range []string{"file_path", "path", "command", "pattern", "query", "url"} {
+		if value := stringField(input, key); value != "" {
+			return fmt.Sprintf("%s %s", name, value)
+		}
+	}
+	return name
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	value, _ := m[key].(string)
+	return value
+}
+
+func nestedString(m map[string]interface{}, key, field string) string {
+	inner, _ := m[key].(map[string]interface{})
+	return stringField(inner, field)
+}
+
+func truncateProgressMessage(message string) string {
+	return truncateRunes(strings.Join(strings.Fields(message), " "), progressMessageMaxChars)
+}
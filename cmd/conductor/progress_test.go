@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDescribeCLIEvent(t *testing.T) {
+	tests := []struct {
+		cli  string
+		line string
+		want string
+	}{
+		{"codex", `{"type":"thread.started","thread_id":"t"}`, "codex: session started"},
+		{"codex", `{"type":"item.started","item":{"type":"command_execution","command":"go test ./..."}}`, "codex: running go test ./..."},
+		{"codex", `{"type":"item.completed","item":{"type":"file_change","changes":[{"path":"a.go","kind":"update"},{"path":"b.go","kind":"add"}]}}`, "codex: edited a.go, b.go"},
+		{"codex", `{"type":"item.started","item":{"type":"mcp_tool_call","server":"gh","tool":"search"}}`, "codex: calling gh.search"},
+		{"codex", `{"type":"item.completed","item":{"type":"agent_message","text":"all\ndone"}}`, "codex: all done"},
+		{"claude", `{"type":"system","subtype":"init","session_id":"s"}`, "claude: session started"},
+		{"claude", `{"type":"assistant","message":{"content":[{"type":"text","text":"Looking"},{"type":"tool_use","name":"Edit","input":{"file_path":"main.go"}}]}}`, "claude: Looking; Edit main.go"},
+		{"gemini", `{"type":"tool_use","tool_name":"run_shell_command","parameters":{"command":"ls"}}`, "gemini: run_shell_command ls"},
+		{"gemini", `{"type":"message","role":"assistant","content":"chunk","delta":true}`, "gemini: chunk"},
+	}
+	for _, tt := range tests {
+		got, ok := describeCLIEvent(tt.cli, tt.line)
+		if !ok || got != tt.want {
+			t.Errorf("describeCLIEvent(%s, %s) = %q, %v; want %q", tt.cli, tt.line, got, ok, tt.want)
+		}
+	}
+
+	for _, line := range []string{"plain text", `{"type":"turn.started"}`, `{"type":"message","role":"user","content":"x"}`, "{broken"} {
+		if got, ok := describeCLIEvent("codex", line); ok {
+			t.Errorf("expected %q to be skipped, got %q", line, got)
+		}
+	}
+
+	long, _ := describeCLIEvent("codex", `{"type":"item.completed","item":{"type":"agent_message","text":"`+strings.Repeat("x", 500)+`"}}`)
+	if len(long) > progressMessageMaxChars+len("codex: ")+len("…") {
+		t.Errorf("expected long message to be truncated, got %d chars", len(long))
+	}
+	wide, _ := describeCLIEvent("codex", `{"type":"item.completed","item":{"type":"agent_message","text":"`+strings.Repeat("日", 500)+`"}}`)
+	if !utf8.ValidString(wide) || !strings.HasSuffix(wide, "…") {
+		t.Errorf("expected multi-byte message cut on a rune boundary, got %q", wide)
+	}
+}
+
+func TestLineWriterSplitsPartialWrites(t *testing.T) {
+	var lines []string
+	w := &lineWriter{fn: func(line string) { lines = append(lines, line) }}
+	_, _ = w.Write([]byte("one\ntw"))
+	_, _ = w.Write([]byte("o\nthree"))
+	w.Flush()
+	if strings.Join(lines, "|") != "one|two|three" {
+		t.Errorf("unexpected lines: %v", lines)
+	}
+}
+
+func TestCLIAdapterReportsProgress(t *testing.T) {
+	installFakeCLI(t, "codex", `echo '{"type":"thread.started","thread_id":"t"}'
+echo '{"type":"item.started","item":{"type":"command_execution","command":"make"}}'
+echo '{"type":"item.completed","item":{"type":"agent_message","text":"done"}}'
+`)
+	var mu sync.Mutex
+	var messages []string
+	var last float64
+	report := func(message string, progress, total float64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if progress <= last {
+			t.Errorf("expected increasing progress, got %v after %v", progress, last)
+		}
+		last = progress
+		messages = append(messages, message)
+	}
+
+	ctx := withProgressReporter(context.Background(), report)
+	adapter := &CLIAdapter{Name: "Codex", Cmd: "codex"}
+	if _, err := adapter.Run(ctx, CLIRunOptions{Progress: progressReporterFromContext(ctx)}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := "codex: session started|codex: running make|codex: done"
+	if got := strings.Join(messages, "|"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultRetryMaxBackoffMs = 30000
+
+// defaultRetryOn lists the error codes retried when retry_on is not set:
+// transient failures only, never auth errors or bad flags (cli_exit).
+var defaultRetryOn = []string{errCodeRateLimit, errCodeQuota, errCodeIdleTimeout}
+
+// retryAfterPattern matches hints such as "Retry-After: 30",
+// "retryDelay": "37s" or "try again in 1.5s".
+var retryAfterPattern = regexp.MustCompile(`(?i)(?:retry[-_ ]?after|retry[-_ ]?delay|retry in|try again in)["']?\s*[:=]?\s*["']?(\d+(?:\.\d+)?)\s*(ms|milliseconds?|s|secs?|seconds?|m|mins?|minutes?)?\b`)
+
+// retryPolicy decides whether a failed attempt is retried and how long to
+// wait before the next one.
+type retryPolicy struct {
+	attempts   int
+	on         []string
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func newRetryPolicy(retry, backoffMs, maxBackoffMs int, on []string) retryPolicy {
+	if retry < 0 {
+		retry = 0
+	}
+	if len(on) == 0 {
+		on = defaultRetryOn
+	}
+	if maxBackoffMs <= 0 {
+		maxBackoffMs = defaultRetryMaxBackoffMs
+	}
+	return retryPolicy{
+		attempts:   retry + 1,
+		on:         on,
+		backoff:    time.Duration(backoffMs) * time.Millisecond,
+		maxBackoff: time.Duration(maxBackoffMs) * time.Millisecond,
+	}
+}
+
+func (spec CmdSpec) retryPolicy() retryPolicy {
+	return newRetryPolicy(spec.Retry, spec.RetryBackoffMs, spec.RetryMaxBackoffMs, spec.RetryOn)
+}
+
+func (opts CLIRunOptions) retryPolicy() retryPolicy {
+	return newRetryPolicy(opts.Retry, opts.RetryBackoffMs, opts.RetryMaxBackoffMs, opts.RetryOn)
+}
+
+// retries reports whether a failure with this error code is retried.
+func (p retryPolicy) retries(code string) bool {
+	return code != "" && indexOf(p.on, code) >= 0
+}
+
+// delay is the wait after the given failed attempt (1-based): the backoff
+// doubles per attempt up to maxBackoff and up to half of it is jittered
+// away. A retry-after hint in output replaces it, still capped at maxBackoff.
+func (p retryPolicy) delay(attempt int, output string) time.Duration {
+	if hint, ok := retryAfterHint(output); ok {
+		return min(hint, p.maxBackoff)
+	}
+	if p.backoff <= 0 {
+		return 0
+	}
+	d := p.backoff
+	for i := 1; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, p.maxBackoff)
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// retryAfterHint finds the first retry-after hint in CLI output. Bare numbers
+// are seconds.
+func retryAfterHint(output string) (time.Duration, bool) {
+	m := retryAfterPattern.FindStringSubmatch(output)
+	if m == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	switch unit := strings.ToLower(m[2]); {
+	case unit == "ms" || strings.HasPrefix(unit, "milli"):
+		return time.Duration(value * float64(time.Millisecond)), true
+	case strings.HasPrefix(unit, "m"):
+		return time.Duration(value * float64(time.Minute)), true
+	default:
+		return time.Duration(value * float64(time.Second)), true
+	}
+}
+
+// validateRetryOn reports retry_on entries that are not error codes.
+func validateRetryOn(on []string) error {
+	for _, code := range on {
+		if indexOf(retryableErrorCodes, code) < 0 {
+			return fmt.Errorf("unknown error code %q (one of %s)", code, strings.Join(retryableErrorCodes, ", "))
+		}
+	}
+	return nil
+}
+
+// retryableErrorCodes are the codes retry_on may list; the others are raised
+// before a CLI starts or by the caller.
+var retryableErrorCodes = []string{
+	errCodeAuth, errCodeQuota, errCodeRateLimit, errCodeIdleTimeout, errCodeDeadline, errCodeCLIExit,
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterHint(t *testing.T) {
+	cases := map[string]time.Duration{
+		"HTTP 429\nRetry-After: 30":                   30 * time.Second,
+		`"retryDelay": "37s"`:                         37 * time.Second,
+		"Rate limited, please try again in 1.5s":      1500 * time.Millisecond,
+		"retry after 250ms":                           250 * time.Millisecond,
+		"quota exhausted; retry in 2 minutes":         2 * time.Minute,
+		"retry_after=4 seconds":                       4 * time.Second,
+		"error: something broke (retry not possible)": 0,
+	}
+	for text, want := range cases {
+		got, ok := retryAfterHint(text)
+		if ok != (want > 0) || got != want {
+			t.Errorf("retryAfterHint(%q) = %v, %t; want %v", text, got, ok, want)
+		}
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := newRetryPolicy(3, 100, 300, nil)
+	if !policy.retries(errCodeRateLimit) || policy.retries(errCodeAuth) || policy.retries(errCodeCLIExit) {
+		t.Fatalf("unexpected default retry_on %v", policy.on)
+	}
+	for i := 0; i < 20; i++ {
+		if d := policy.delay(1, ""); d < 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("first delay out of range: %v", d)
+		}
+		if d := policy.delay(3, ""); d < 150*time.Millisecond || d > 300*time.Millisecond {
+			t.Fatalf("capped delay out of range: %v", d)
+		}
+	}
+	if d := policy.delay(1, "try again in 0.2s"); d != 200*time.Millisecond {
+		t.Fatalf("expected retry-after hint, got %v", d)
+	}
+	if d := policy.delay(1, "Retry-After: 60"); d != 300*time.Millisecond {
+		t.Fatalf("expected hint capped at max backoff, got %v", d)
+	}
+	if err := validateRetryOn([]string{"rate_limit", "flaky"}); err == nil || !strings.Contains(err.Error(), `"flaky"`) {
+		t.Fatalf("expected unknown code error, got %v", err)
+	}
+}
+
+// installCountingCLI installs a fake CLI that counts its invocations and
+// prints message to stderr before failing.
+func installCountingCLI(t *testing.T, name, message string) string {
+	t.Helper()
+	counter := filepath.Join(t.TempDir(), "count")
+	installFakeCLI(t, name, `echo x >> "`+counter+`"
+echo "`+message+`" >&2
+exit 1
+`)
+	return counter
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read counter: %v", err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestRunCommandRetriesByErrorCode(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	spec := CmdSpec{Agent: "gemini", Cmd: "gemini", Retry: 2, RetryBackoffMs: 1}
+
+	counter := installCountingCLI(t, "gemini", "401 Unauthorized")
+	res, err := runCommand(spec)
+	if err != nil || res["error_code"] != errCodeAuth || countLines(t, counter) != 1 {
+		t.Fatalf("expected auth failure without retries, got %v (%v)", res, err)
+	}
+
+	counter = installCountingCLI(t, "gemini", "429 Too Many Requests, retry after 10ms")
+	res, err = runCommand(spec)
+	if err != nil || res["attempt"] != 3 || countLines(t, counter) != 3 {
+		t.Fatalf("expected rate limit to be retried twice, got %v (%v)", res, err)
+	}
+
+	counter = installCountingCLI(t, "gemini", "unknown flag --bogus")
+	spec.RetryOn = []string{errCodeCLIExit}
+	if _, err := runCommand(spec); err != nil || countLines(t, counter) != 3 {
+		t.Fatalf("expected retry_on cli_exit to retry, got %d runs (%v)", countLines(t, counter), err)
+	}
+}
+
+func TestAsyncRunRetriesByErrorCode(t *testing.T) {
+	t.Setenv("CONDUCTOR_HOME", t.TempDir())
+	counter := installCountingCLI(t, "gemini", "invalid api key")
+	started, err := startAsync(CmdSpec{Agent: "gemini", Cmd: "gemini", Retry: 2, RetryBackoffMs: 1})
+	if err != nil {
+		t.Fatalf("start async: %v", err)
+	}
+	status, err := waitRun(started["run_id"].(string), 10*time.Second, 0)
+	if err != nil || status["error_code"] != errCodeAuth || status["attempt"] != 1 || countLines(t, counter) != 1 {
+		t.Fatalf("expected one auth attempt, got %v (%v)", status, err)
+	}
+}
+
+func TestCLIAdapterRetriesByErrorCode(t *testing.T) {
+	counter := installCountingCLI(t, "codex", "RESOURCE_EXHAUSTED retryDelay: 10ms")
+	_, err := mcpCodexAdapter.Run(context.Background(), CLIRunOptions{Retry: 2, RetryBackoffMs: 1})
+	if errorCode(err) != errCodeQuota || countLines(t, counter) != 3 {
+		t.Fatalf("expected quota to be retried, got %d runs (%v)", countLines(t, counter), err)
+	}
+
+	counter = installCountingCLI(t, "codex", "boom")
+	_, err = mcpCodexAdapter.Run(context.Background(), CLIRunOptions{Retry: 2, RetryBackoffMs: 1})
+	if errorCode(err) != errCodeCLIExit || countLines(t, counter) != 1 {
+		t.Fatalf("expected cli_exit not to be retried by default, got %d runs (%v)", countLines(t, counter), err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// runSessions manages the MCP sessions persisted under CONDUCTOR_HOME/sessions.
+//
+//	conductor sessions [list] [--cli codex] [--role oracle]
+//	conductor sessions inspect <threadId>
+//	conductor sessions last --role oracle
+//	conductor sessions delete <threadId>
+//	conductor sessions expire [threadId]
+func runSessions(args []string) int {
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("sessions", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configPath := fs.String("config", resolveConfigPath(""), "config path")
+	cliFilter := fs.String("cli", "", "filter by CLI")
+	roleFilter := fs.String("role", "", "filter by role")
+	jsonOut := fs.Bool("json", false, "output JSON")
+	positional, err := parseInterspersedFlags(fs, args)
+	if err != nil {
+		fmt.Println("Invalid flags.")
+		return 1
+	}
+	if len(positional) > 1 {
+		fmt.Println("Unexpected arguments:", strings.Join(positional[1:], " "))
+		return 1
+	}
+	threadID := ""
+	if len(positional) == 1 {
+		threadID = positional[0]
+	}
+
+	cfg, err := loadConfigOrEmpty(*configPath)
+	if err != nil {
+		fmt.Println("Config error:", err.Error())
+		return 1
+	}
+	configureMCPSessions(cfg.MCP)
+	loadMCPSessions()
+
+	payload, err := mcpHandleSessions(MCPSessionsInput{
+		Action:   action,
+		ThreadID: threadID,
+		CLI:      *cliFilter,
+		Role:     *roleFilter,
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	if *jsonOut || !isTerminal(os.Stdout) || (action != "list" && action != "") {
+		printJSON(payload)
+		return 0
+	}
+
+	renderSessionsPretty(payload)
+	return 0
+}
+
+// parseInterspersedFlags parses fs from flags on either side of the
+// positional arguments, so `inspect <threadId> --json` works like
+// `inspect --json <threadId>`, and returns the positional arguments.
+func parseInterspersedFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func renderSessionsPretty(payload map[string]interface{}) {
+	var sb strings.Builder
+
+	title := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("99")).Render("🧵 Conductor Sessions")
+	sb.WriteString(title + "\n\n")
+	sb.WriteString(labelStyle.Render("Store: ") + pathStyle.Render(mcpSessionDir()) + "\n")
+	sb.WriteString(renderDivider(50) + "\n\n")
+
+	sessions, _ := payload["sessions"].([]map[string]interface{})
+	if len(sessions) == 0 {
+		sb.WriteString(labelStyle.Render("No sessions.") + "\n")
+	}
+	for _, sess := range sessions {
+		id, _ := sess["threadId"].(string)
+		line := fmt.Sprintf("• %s", roleNameStyle.Render(id))
+
+		var details []string
+		for _, key := range []string{"cli", "role", "model", "updatedAt"} {
+			if value, _ := sess[key].(string); value != "" {
+				details = append(details, labelStyle.Render(key+"=")+valueStyle.Render(value))
+			}
+		}
+		if len(details) > 0 {
+			line += "  " + strings.Join(details, " ")
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	fmt.Print(sb.String())
+}
+
+// runTranscript exports a recorded thread transcript.
+//
+//	conductor transcript <threadId> [--format markdown|json] [--out file]
+func runTranscript(args []string) int {
+	threadID := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		threadID, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("transcript", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "markdown", "markdown or json")
+	outPath := fs.String("out", "", "write to file instead of stdout")
+	jsonOut := fs.Bool("json", false, "output JSON payload")
+	positional, err := parseInterspersedFlags(fs, args)
+	if err != nil {
+		fmt.Println("Invalid flags.")
+		return 1
+	}
+	if threadID == "" && len(positional) > 0 {
+		threadID, positional = positional[0], positional[1:]
+	}
+	if len(positional) > 0 {
+		fmt.Println("Unexpected arguments:", strings.Join(positional, " "))
+		return 1
+	}
+
+	payload, err := mcpExportTranscript(MCPTranscriptInput{ThreadID: threadID, Format: *format})
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	if *jsonOut {
+		printJSON(payload)
+		return 0
+	}
+	content, _ := payload["content"].(string)
+	if *outPath != "" {
+		if err := os.WriteFile(*outPath, []byte(content), 0o644); err != nil {
+			fmt.Println("Write error:", err.Error())
+			return 1
+		}
+		fmt.Println("Transcript written to", *outPath)
+		return 0
+	}
+	fmt.Print(content)
+	return 0
+}
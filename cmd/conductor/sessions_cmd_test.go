@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParseInterspersedFlags(t *testing.T) {
+	fs := flag.NewFlagSet("sessions", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	jsonOut := fs.Bool("json", false, "")
+	cli := fs.String("cli", "", "")
+
+	positional, err := parseInterspersedFlags(fs, []string{"--cli", "codex", "thread-1", "--json", "extra"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !*jsonOut || *cli != "codex" {
+		t.Errorf("expected flags on both sides parsed, got json=%v cli=%q", *jsonOut, *cli)
+	}
+	if !reflect.DeepEqual(positional, []string{"thread-1", "extra"}) {
+		t.Errorf("unexpected positional args: %v", positional)
+	}
+}
+
+func TestSessionsCommandRejectsExtraArguments(t *testing.T) {
+	resetMCPSessions(t)
+	if code := runSessions([]string{"inspect", "thread-1", "--json", "thread-2"}); code != 1 {
+		t.Fatalf("expected exit 1 for a second thread id, got %d", code)
+	}
+}
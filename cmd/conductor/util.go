@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 )
 
 func getenv(key, fallback string) string {
@@ -49,6 +50,34 @@ func splitList(val string) []string {
 	return out
 }
 
+// truncateRunes cuts value to at most max characters, marking the cut with
+// "…". It counts runes so multi-byte characters are never split.
+func truncateRunes(value string, max int) string {
+	count := 0
+	for i := range value {
+		if count == max {
+			return value[:i] + "…"
+		}
+		count++
+	}
+	return value
+}
+
+// truncateRunesTail is truncateRunes keeping the last max characters.
+func truncateRunesTail(value string, max int) string {
+	skip := utf8.RuneCountInString(value) - max
+	if skip <= 0 {
+		return value
+	}
+	for i := range value {
+		if skip == 0 {
+			return "…" + value[i:]
+		}
+		skip--
+	}
+	return value
+}
+
 func expandPath(path string) string {
 	if path == "" {
 		return path
@@ -94,6 +123,7 @@ func conductorBinAliases() []string {
 		"conductor-doctor",
 		"conductor-mcp-bundle",
 		"conductor-mcp",
+		"conductor-sessions",
 
 		"conductor-status",
 		"conductor-settings",
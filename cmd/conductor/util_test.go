@@ -146,3 +146,21 @@ func TestConductorBinAliases(t *testing.T) {
 		}
 	}
 }
+
+func TestTruncateRunes(t *testing.T) {
+	cases := []struct {
+		value, head, tail string
+	}{
+		{"short", "short", "short"},
+		{"héllo wörld", "héllo…", "…wörld"},
+		{"日本語のテキスト", "日本語のテ…", "…のテキスト"},
+	}
+	for _, tc := range cases {
+		if got := truncateRunes(tc.value, 5); got != tc.head {
+			t.Errorf("truncateRunes(%q) = %q, want %q", tc.value, got, tc.head)
+		}
+		if got := truncateRunesTail(tc.value, 5); got != tc.tail {
+			t.Errorf("truncateRunesTail(%q) = %q, want %q", tc.value, got, tc.tail)
+		}
+	}
+}